@@ -0,0 +1,43 @@
+// pulumi-analyzer-policy-xbeam runs the policy pack in policypack/. The Pulumi engine starts it from the pack
+// directory with its own address and the pack path as arguments, and reads the port the plugin listens on from stdout.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/util/rpcutil"
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+	"google.golang.org/grpc"
+
+	"infra/policy"
+)
+
+func main() {
+	// The engine passes --tracing to every plugin, and the runtime options of PulumiPolicy.yaml as flags
+	flag.String("tracing", "", "Emit tracing to a Zipkin-compatible tracing endpoint")
+	flag.Parse()
+	if flag.NArg() < 1 {
+		fmt.Fprintln(os.Stderr, "usage: pulumi-analyzer-policy-xbeam <engine address> [policy pack path]")
+		os.Exit(1)
+	}
+	if err := serve(); err != nil {
+		fmt.Fprintf(os.Stderr, "pulumi-analyzer-policy-xbeam: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func serve() error {
+	handle, err := rpcutil.ServeWithOptions(rpcutil.ServeOptions{
+		Init: func(srv *grpc.Server) error {
+			pulumirpc.RegisterAnalyzerServer(srv, policy.NewAnalyzer())
+			return nil
+		},
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d\n", handle.Port)
+	return <-handle.Done
+}
@@ -0,0 +1,49 @@
+// pulumi-resource-xbeam is the provider plugin of the xbeam package. The Pulumi engine starts it with its own
+// address as the only argument and reads the port the plugin listens on from stdout.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/util/rpcutil"
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"infra/provider"
+)
+
+func main() {
+	// The engine passes --tracing to every plugin
+	flag.String("tracing", "", "Emit tracing to a Zipkin-compatible tracing endpoint")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: pulumi-resource-xbeam <engine address>")
+		os.Exit(1)
+	}
+	if err := serve(flag.Arg(0)); err != nil {
+		fmt.Fprintf(os.Stderr, "pulumi-resource-xbeam: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func serve(engineAddress string) error {
+	engine, err := grpc.Dial(engineAddress, grpc.WithTransportCredentials(insecure.NewCredentials()), rpcutil.GrpcChannelOptions())
+	if err != nil {
+		return fmt.Errorf("connecting to the engine: %w", err)
+	}
+	defer engine.Close()
+	handle, err := rpcutil.ServeWithOptions(rpcutil.ServeOptions{
+		Init: func(srv *grpc.Server) error {
+			pulumirpc.RegisterResourceProviderServer(srv, provider.New(engine))
+			return nil
+		},
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d\n", handle.Port)
+	return <-handle.Done
+}
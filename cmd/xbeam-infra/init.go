@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"github.com/spf13/cobra"
+
+	"infra/workload"
+)
+
+const (
+	DEFAULT_REGION        = "us-east-1"
+	DEFAULT_INSTANCE_TYPE = "g4dn.2xlarge"
+	WINDOWS_AMI_SEARCH    = "XBeamWindows"
+	PASSWORD_LENGTH       = 24
+)
+
+// GPU instance types accepted when the region offerings cannot be queried
+var gpuInstanceCatalog = []string{
+	"g4dn.xlarge", "g4dn.2xlarge", "g4dn.4xlarge", "g4dn.8xlarge", "g4dn.12xlarge", "g4dn.16xlarge", "g4dn.metal",
+	"g5.xlarge", "g5.2xlarge", "g5.4xlarge", "g5.8xlarge", "g5.12xlarge", "g5.16xlarge", "g5.24xlarge", "g5.48xlarge",
+	"g6.xlarge", "g6.2xlarge", "g6.4xlarge", "g6.8xlarge", "g6.12xlarge", "g6.16xlarge", "g6.24xlarge", "g6.48xlarge",
+	"p3.2xlarge", "p3.8xlarge", "p3.16xlarge",
+}
+
+var (
+	regionPattern   = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-\d$`)
+	accountPattern  = regexp.MustCompile(`^\d{12}$`)
+	iamUserPattern  = regexp.MustCompile(`^[\w+=,.@-]{1,64}$`)
+	iamUserArnRegex = regexp.MustCompile(`^arn:[\w-]+:iam::\d{12}:user/(?:.*/)?([\w+=,.@-]+)$`)
+)
+
+// The worker user data quotes the Windows password, so any symbol is safe there. Quotes, the backslash, the backtick
+// and the space are still left out, they are easy to get wrong when the password is typed into an RDP client or a shell.
+const (
+	passwordLower   = "abcdefghijkmnopqrstuvwxyz"
+	passwordUpper   = "ABCDEFGHJKLMNPQRSTUVWXYZ"
+	passwordDigits  = "23456789"
+	passwordSymbols = "!#$%&()*+,-.:;<=>?@[]^_{|}~"
+)
+
+var poolLabels = map[string]string{
+	workload.POOL_LINUX:   "Linux",
+	workload.POOL_WINDOWS: "Windows",
+}
+
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// ask repeats the question until the answer is valid, an empty answer selects the default.
+func (p *prompter) ask(question string, fallback string, validate func(string) error) (string, error) {
+	for {
+		if fallback != "" {
+			fmt.Fprintf(p.out, "%s [%s]: ", question, fallback)
+		} else {
+			fmt.Fprintf(p.out, "%s: ", question)
+		}
+		line, err := p.in.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return "", errors.New("input ended before the configuration was complete")
+		}
+		answer := strings.TrimSpace(line)
+		if answer == "" {
+			answer = fallback
+		}
+		if problem := validate(answer); problem != nil {
+			fmt.Fprintf(p.out, "  %v\n", problem)
+			continue
+		}
+		return answer, nil
+	}
+}
+
+func (p *prompter) askInt(question string, fallback int, min int) (int, error) {
+	answer, err := p.ask(question, strconv.Itoa(fallback), func(answer string) error {
+		value, err := strconv.Atoi(answer)
+		if err != nil || value < min {
+			return fmt.Errorf("enter a number of at least %d", min)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(answer)
+}
+
+// wizard asks for the stack config. Without AWS credentials the account has to be typed in and instance types
+// are checked against gpuInstanceCatalog.
+type wizard struct {
+	prompter *prompter
+	// connect returns the AWS clients for a region, or an error when no credentials are available
+	connect func(ctx context.Context, region string) (*awsClients, error)
+}
+
+func (w *wizard) run(ctx context.Context) (auto.ConfigMap, error) {
+	p := w.prompter
+	fallbackRegion := os.Getenv("AWS_REGION")
+	if fallbackRegion == "" {
+		fallbackRegion = DEFAULT_REGION
+	}
+	region, err := p.ask("AWS region", fallbackRegion, func(answer string) error {
+		if !regionPattern.MatchString(answer) {
+			return errors.New("enter a region name such as us-east-1")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	account, adminUser := "", ""
+	instanceTypes := map[string]bool{}
+	for _, instanceType := range gpuInstanceCatalog {
+		instanceTypes[instanceType] = true
+	}
+	clients, err := w.connect(ctx, region)
+	if err == nil {
+		var arn string
+		account, arn, err = callerIdentity(ctx, clients.sts)
+		if err == nil {
+			if match := iamUserArnRegex.FindStringSubmatch(arn); match != nil {
+				adminUser = match[1]
+			}
+			var offered map[string]bool
+			offered, err = offeredInstanceTypes(ctx, clients.ec2)
+			if err == nil {
+				instanceTypes = offered
+			}
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(p.out, "AWS is not reachable (%v), instance types are checked against the built-in GPU catalog\n", err)
+	}
+
+	account, err = p.ask("AWS account ID", account, func(answer string) error {
+		if !accountPattern.MatchString(answer) {
+			return errors.New("an account ID has 12 digits")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	adminUser, err = p.ask("IAM user with cluster admin access", adminUser, func(answer string) error {
+		if !iamUserPattern.MatchString(answer) {
+			return errors.New("enter an IAM user name")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	validInstanceType := func(answer string) error {
+		if !instanceTypes[answer] {
+			return fmt.Errorf("%s is not available in %s", answer, region)
+		}
+		return nil
+	}
+
+	config := auto.ConfigMap{
+		"aws:region":        {Value: region},
+		"eks:accountId":     {Value: account},
+		"eks:adminUsername": {Value: adminUser},
+		"eks:version":       {Value: workload.K8S_VERSION},
+		"worker:windowsAmi": {Value: WINDOWS_AMI_SEARCH},
+	}
+	for _, pool := range []string{workload.POOL_LINUX, workload.POOL_WINDOWS} {
+		label := poolLabels[pool]
+		instanceType, err := p.ask(fmt.Sprintf("%s GPU instance type", label), DEFAULT_INSTANCE_TYPE, validInstanceType)
+		if err != nil {
+			return nil, err
+		}
+		minSize, err := p.askInt(fmt.Sprintf("%s pool minimum size", label), 1, 0)
+		if err != nil {
+			return nil, err
+		}
+		maxSize, err := p.askInt(fmt.Sprintf("%s pool maximum size", label), max(minSize, 1), max(minSize, 1))
+		if err != nil {
+			return nil, err
+		}
+		desired, err := p.ask(fmt.Sprintf("%s pool desired size", label), strconv.Itoa(minSize), func(answer string) error {
+			value, err := strconv.Atoi(answer)
+			if err != nil || value < minSize || value > maxSize {
+				return fmt.Errorf("enter a number between %d and %d", minSize, maxSize)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		// The program reads worker:linuxInstance and worker:windowsInstance
+		config["worker:"+pool+"Instance"] = auto.ConfigValue{Value: instanceType}
+		config["worker:"+pool+"MinSize"] = auto.ConfigValue{Value: strconv.Itoa(minSize)}
+		config["worker:"+pool+"MaxSize"] = auto.ConfigValue{Value: strconv.Itoa(maxSize)}
+		config["worker:"+pool+"DesiredCapacity"] = auto.ConfigValue{Value: desired}
+	}
+
+	mode, err := p.ask("API endpoint access (public, private or both)", workload.ENDPOINT_ACCESS_PUBLIC, func(answer string) error {
+		_, err := workload.NewEndpointAccess(answer, nil, 0)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	config["cluster:endpointAccess"] = auto.ConfigValue{Value: mode}
+	if mode != workload.ENDPOINT_ACCESS_PRIVATE {
+		cidrs, err := p.ask("CIDRs allowed to reach the public endpoint, comma separated (empty for any)", "", func(answer string) error {
+			_, err := workload.NewEndpointAccess(mode, splitList(answer), 0)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		if list := splitList(cidrs); len(list) > 0 {
+			encoded, err := json.Marshal(list)
+			if err != nil {
+				return nil, err
+			}
+			config["cluster:publicAccessCidrs"] = auto.ConfigValue{Value: string(encoded)}
+		}
+	}
+
+	password, err := generatePassword(PASSWORD_LENGTH)
+	if err != nil {
+		return nil, err
+	}
+	config["worker:windowsPassword"] = auto.ConfigValue{Value: password, Secret: true}
+	return config, nil
+}
+
+func splitList(raw string) []string {
+	list := []string{}
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// generatePassword returns a random password with every character class, as Windows requires.
+// It starts with a letter so it is never read as a command line option.
+func generatePassword(length int) (string, error) {
+	classes := []string{passwordLower, passwordUpper, passwordDigits, passwordSymbols}
+	all := strings.Join(classes, "")
+	for {
+		password := make([]byte, length)
+		for i := range password {
+			alphabet := all
+			if i == 0 {
+				alphabet = passwordLower + passwordUpper
+			}
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+			if err != nil {
+				return "", err
+			}
+			password[i] = alphabet[n.Int64()]
+		}
+		complete := true
+		for _, class := range classes {
+			complete = complete && strings.ContainsAny(string(password), class)
+		}
+		if complete {
+			return string(password), nil
+		}
+	}
+}
+
+func newInitCommand(options *stackOptions) *cobra.Command {
+	var output string
+	var force bool
+	command := &cobra.Command{
+		Use:   "init",
+		Short: "Create the stack config interactively",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			if output == "" {
+				output = "Pulumi." + options.Stack + ".yaml"
+			}
+			if _, err := os.Stat(output); err == nil && !force {
+				return fmt.Errorf("%s exists, pass --force to overwrite it", output)
+			}
+			w := &wizard{
+				prompter: &prompter{in: bufio.NewReader(cmd.InOrStdin()), out: cmd.OutOrStdout()},
+				connect:  newAWSClients,
+			}
+			config, err := w.run(ctx)
+			if err != nil {
+				return err
+			}
+			stack, err := selectStack(ctx, options)
+			if err != nil {
+				return err
+			}
+			if err := stack.SetAllConfig(ctx, config); err != nil {
+				return err
+			}
+			// The stack settings hold the encrypted password and the salt it was encrypted with
+			settings, err := stack.Workspace().StackSettings(ctx, stack.Name())
+			if err != nil {
+				return err
+			}
+			if err := settings.Save(output); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Wrote %s and configured stack %s, deploy it with xbeam-infra up --stack %s\n", output, options.Stack, options.Stack)
+			return nil
+		},
+	}
+	command.Flags().StringVarP(&output, "output", "o", "", "config file to write, defaults to Pulumi.<stack>.yaml")
+	command.Flags().BoolVar(&force, "force", false, "overwrite an existing config file")
+	return command
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func runWizard(t *testing.T, answers []string, connect func(context.Context, string) (*awsClients, error)) (map[string]string, string, error) {
+	t.Setenv("AWS_REGION", "")
+	out := &bytes.Buffer{}
+	w := &wizard{
+		prompter: &prompter{in: bufio.NewReader(strings.NewReader(strings.Join(answers, "\n") + "\n")), out: out},
+		connect:  connect,
+	}
+	config, err := w.run(context.Background())
+	values := map[string]string{}
+	for key, value := range config {
+		values[key] = value.Value
+		if value.Secret != isSecretConfigKey(key) {
+			t.Errorf("%s: expected secret %v", key, isSecretConfigKey(key))
+		}
+	}
+	return values, out.String(), err
+}
+
+func TestWizardOnline(t *testing.T) {
+	connect := func(ctx context.Context, region string) (*awsClients, error) {
+		return &awsClients{
+			sts: &fakeSTS{arn: "arn:aws:iam::210987654321:user/ops/alice"},
+			ec2: &fakeEC2{offered: []string{"g4dn.2xlarge", "g5.xlarge"}},
+		}, nil
+	}
+	config, output, err := runWizard(t, []string{
+		"us-west-2",
+		"",            // detected account
+		"",            // detected user
+		"p3.2xlarge",  // not offered, asked again
+		"g5.xlarge",   // Linux instance type
+		"1", "3", "2", // Linux sizes
+		"",           // Windows instance type
+		"0", "0", "", // Windows max must be at least 1
+		"",
+		"both",
+		"203.0.113.10/32, 198.51.100.0/24",
+	}, connect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"aws:region":                    "us-west-2",
+		"eks:accountId":                 "210987654321",
+		"eks:adminUsername":             "alice",
+		"worker:linuxInstance":          "g5.xlarge",
+		"worker:linuxMinSize":           "1",
+		"worker:linuxMaxSize":           "3",
+		"worker:linuxDesiredCapacity":   "2",
+		"worker:windowsInstance":        "g4dn.2xlarge",
+		"worker:windowsMinSize":         "0",
+		"worker:windowsMaxSize":         "1",
+		"worker:windowsDesiredCapacity": "0",
+		"cluster:endpointAccess":        "both",
+		"cluster:publicAccessCidrs":     `["203.0.113.10/32","198.51.100.0/24"]`,
+	}
+	for key, value := range expected {
+		if config[key] != value {
+			t.Errorf("%s: expected %q, got %q", key, value, config[key])
+		}
+	}
+	if !strings.Contains(output, "p3.2xlarge is not available in us-west-2") {
+		t.Errorf("expected the unavailable instance type to be rejected:\n%s", output)
+	}
+	if len(config["worker:windowsPassword"]) != PASSWORD_LENGTH {
+		t.Errorf("expected a generated password, got %q", config["worker:windowsPassword"])
+	}
+}
+
+func TestWizardOffline(t *testing.T) {
+	connect := func(ctx context.Context, region string) (*awsClients, error) {
+		return nil, errors.New("no credentials")
+	}
+	config, output, err := runWizard(t, []string{
+		"",
+		"", // no detected account, asked again
+		"123456789012",
+		"admin",
+		"m5.large", // not a GPU instance
+		"g4dn.xlarge",
+		"", "", "",
+		"", "", "", "",
+		"private",
+	}, connect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output, "built-in GPU catalog") {
+		t.Errorf("expected the offline notice:\n%s", output)
+	}
+	if config["aws:region"] != DEFAULT_REGION || config["worker:linuxInstance"] != "g4dn.xlarge" || config["cluster:endpointAccess"] != "private" {
+		t.Errorf("unexpected config %v", config)
+	}
+	if _, ok := config["cluster:publicAccessCidrs"]; ok {
+		t.Error("a private endpoint has no public CIDRs")
+	}
+}
+
+func TestWizardIncompleteInput(t *testing.T) {
+	connect := func(ctx context.Context, region string) (*awsClients, error) {
+		return nil, errors.New("no credentials")
+	}
+	if _, _, err := runWizard(t, []string{"us-east-1"}, connect); err == nil {
+		t.Error("expected an error when the input ends early")
+	}
+}
+
+func TestGeneratePassword(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		password, err := generatePassword(PASSWORD_LENGTH)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, class := range []string{passwordLower, passwordUpper, passwordDigits, passwordSymbols} {
+			if !strings.ContainsAny(password, class) {
+				t.Errorf("%q has no character of %q", password, class)
+			}
+		}
+		if !strings.ContainsAny(password[:1], passwordLower+passwordUpper) {
+			t.Errorf("%q does not start with a letter", password)
+		}
+	}
+}
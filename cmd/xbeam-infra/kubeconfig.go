@@ -0,0 +1,223 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"infra/workload"
+)
+
+// Sections of a kubeconfig whose entries are merged by name
+var kubeconfigSections = []string{"clusters", "users", "contexts"}
+
+type kubeconfigOptions struct {
+	Path    string
+	Context string
+	RoleArn string
+	Profile string
+	Tenant  string
+}
+
+func defaultKubeconfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".kube", "config")
+	}
+	return filepath.Join(home, ".kube", "config")
+}
+
+// newKubeconfigArgs reads the cluster from the stack outputs. A private only endpoint is reached through the SSM
+// tunnel of the API jump host, on cluster:apiTunnelPort. A tenant kubeconfig assumes the role of the tenant and
+// defaults to its namespace.
+func newKubeconfigArgs(config auto.ConfigMap, outputs auto.OutputMap, options *kubeconfigOptions) (workload.KubeconfigArgs, error) {
+	args := workload.KubeconfigArgs{
+		Region:      config["aws:region"].Value,
+		ContextName: options.Context,
+		RoleArn:     options.RoleArn,
+		Profile:     options.Profile,
+	}
+	for _, output := range []struct {
+		name  string
+		value *string
+	}{
+		{"ClusterName", &args.ClusterName},
+		{"ClusterEndpoint", &args.Endpoint},
+		{"ClusterCertificateAuthority", &args.CertificateAuthority},
+	} {
+		value, ok := outputs[output.name].Value.(string)
+		if !ok || value == "" {
+			return args, fmt.Errorf("the stack has no %s output, deploy it with up first", output.name)
+		}
+		*output.value = value
+	}
+	if config["cluster:endpointAccess"].Value == workload.ENDPOINT_ACCESS_PRIVATE {
+		args.TunnelPort = workload.DEFAULT_API_TUNNEL_PORT
+		if raw := config["cluster:apiTunnelPort"].Value; raw != "" {
+			port, err := strconv.Atoi(raw)
+			if err != nil {
+				return args, errors.New("cluster:apiTunnelPort must be a valid port")
+			}
+			args.TunnelPort = port
+		}
+	}
+	if options.Tenant != "" {
+		return tenantKubeconfigArgs(config, args, options.Tenant)
+	}
+	return args, nil
+}
+
+func tenantKubeconfigArgs(config auto.ConfigMap, args workload.KubeconfigArgs, name string) (workload.KubeconfigArgs, error) {
+	tenants, err := workload.ParseTenantConfigs(config["cluster:tenants"].Value)
+	if err != nil {
+		return args, err
+	}
+	for _, tenant := range tenants {
+		if tenant.Name != name {
+			continue
+		}
+		if args.RoleArn == "" {
+			args.RoleArn = tenant.RoleArn
+		}
+		if args.ContextName == "" {
+			args.ContextName = args.ClusterName + "-" + tenant.Name
+		}
+		args.Namespace = tenant.Name
+		return args, nil
+	}
+	return args, fmt.Errorf("no tenant %s in cluster:tenants", name)
+}
+
+func entryName(entry interface{}) string {
+	if m, ok := entry.(map[string]interface{}); ok {
+		if name, ok := m["name"].(string); ok {
+			return name
+		}
+	}
+	return ""
+}
+
+// mergeKubeconfig replaces the entries of existing that have the names of the entries of update, adds the others
+// and makes the context of update the current one.
+func mergeKubeconfig(existing map[string]interface{}, update map[string]interface{}) map[string]interface{} {
+	if existing == nil {
+		return update
+	}
+	for _, section := range kubeconfigSections {
+		replaced := map[string]bool{}
+		entries, _ := update[section].([]interface{})
+		for _, entry := range entries {
+			replaced[entryName(entry)] = true
+		}
+		merged := []interface{}{}
+		current, _ := existing[section].([]interface{})
+		for _, entry := range current {
+			if !replaced[entryName(entry)] {
+				merged = append(merged, entry)
+			}
+		}
+		existing[section] = append(merged, entries...)
+	}
+	for _, key := range []string{"apiVersion", "kind"} {
+		if existing[key] == nil {
+			existing[key] = update[key]
+		}
+	}
+	existing["current-context"] = update["current-context"]
+	return existing
+}
+
+// writeKubeconfig merges the kubeconfig into the file at path and replaces it with a file only the owner can read.
+func writeKubeconfig(path string, kubeconfig map[string]interface{}) error {
+	var existing map[string]interface{}
+	raw, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := yaml.Unmarshal(raw, &existing); err != nil {
+			return fmt.Errorf("invalid kubeconfig %s: %w", path, err)
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return err
+	}
+	content, err := yaml.Marshal(mergeKubeconfig(existing, kubeconfig))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	// CreateTemp creates the file with mode 0600, the rename replaces the old file and its mode
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(file.Name(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+func newKubeconfigCommand(options *stackOptions) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "kubeconfig",
+		Short: "Manage access to the cluster",
+	}
+	kubeconfig := &kubeconfigOptions{}
+	write := &cobra.Command{
+		Use:   "write",
+		Short: "Merge the cluster into a kubeconfig file, authenticating with aws eks get-token",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			stack, err := selectStack(ctx, options)
+			if err != nil {
+				return err
+			}
+			config, err := stack.GetAllConfig(ctx)
+			if err != nil {
+				return err
+			}
+			outputs, err := stack.Outputs(ctx)
+			if err != nil {
+				return err
+			}
+			kubeconfigArgs, err := newKubeconfigArgs(config, outputs, kubeconfig)
+			if err != nil {
+				return err
+			}
+			content, err := workload.NewKubeconfig(kubeconfigArgs)
+			if err != nil {
+				return err
+			}
+			if err := writeKubeconfig(kubeconfig.Path, content); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Wrote context %s to %s\n", content["current-context"], kubeconfig.Path)
+			if kubeconfigArgs.TunnelPort != 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), "The endpoint is private, start the tunnel of the ApiTunnelCommand output first")
+			}
+			return nil
+		},
+	}
+	write.Flags().StringVar(&kubeconfig.Path, "path", defaultKubeconfigPath(), "kubeconfig file to merge the cluster into")
+	write.Flags().StringVar(&kubeconfig.Context, "context", "", "name of the context, defaults to the cluster name")
+	write.Flags().StringVar(&kubeconfig.RoleArn, "role-arn", "", "IAM role aws eks get-token assumes")
+	write.Flags().StringVar(&kubeconfig.Profile, "profile", "", "AWS CLI profile aws eks get-token uses")
+	write.Flags().StringVar(&kubeconfig.Tenant, "tenant", "", "tenant of cluster:tenants whose role and namespace the context uses")
+	command.AddCommand(write)
+	return command
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"gopkg.in/yaml.v3"
+
+	"infra/policy"
+	"infra/workload"
+)
+
+func clusterOutputs() auto.OutputMap {
+	return auto.OutputMap{
+		"ClusterName":                 {Value: "dev-eks"},
+		"ClusterEndpoint":             {Value: "https://0123456789ABCDEF.gr7.us-west-2.eks.amazonaws.com"},
+		"ClusterCertificateAuthority": {Value: "Q0EK"},
+		"Kubeconfig":                  {Value: "{}", Secret: true},
+	}
+}
+
+func TestNewKubeconfigArgs(t *testing.T) {
+	config := auto.ConfigMap{"aws:region": {Value: "us-west-2"}}
+	args, err := newKubeconfigArgs(config, clusterOutputs(), &kubeconfigOptions{Profile: "xbeam"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args.ClusterName != "dev-eks" || args.Region != "us-west-2" || args.Profile != "xbeam" || args.TunnelPort != 0 {
+		t.Errorf("unexpected args %+v", args)
+	}
+	config["cluster:endpointAccess"] = auto.ConfigValue{Value: workload.ENDPOINT_ACCESS_PRIVATE}
+	config["cluster:apiTunnelPort"] = auto.ConfigValue{Value: "9443"}
+	if args, err = newKubeconfigArgs(config, clusterOutputs(), &kubeconfigOptions{}); err != nil || args.TunnelPort != 9443 {
+		t.Errorf("expected the private endpoint through the tunnel port, got %+v %v", args, err)
+	}
+	if _, err := newKubeconfigArgs(config, auto.OutputMap{}, &kubeconfigOptions{}); err == nil {
+		t.Error("expected a stack without outputs to fail")
+	}
+}
+
+func TestNewTenantKubeconfigArgs(t *testing.T) {
+	config := auto.ConfigMap{
+		"aws:region":      {Value: "us-west-2"},
+		"cluster:tenants": {Value: `[{"name":"team-a","roleArn":"arn:aws:iam::123456789012:role/team-a"}]`},
+	}
+	args, err := newKubeconfigArgs(config, clusterOutputs(), &kubeconfigOptions{Tenant: "team-a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args.RoleArn != "arn:aws:iam::123456789012:role/team-a" || args.Namespace != "team-a" || args.ContextName != "dev-eks-team-a" {
+		t.Errorf("unexpected args %+v", args)
+	}
+	if _, err := newKubeconfigArgs(config, clusterOutputs(), &kubeconfigOptions{Tenant: "team-b"}); err == nil {
+		t.Error("expected an unknown tenant to fail")
+	}
+}
+
+func readKubeconfig(t *testing.T, path string) map[string]interface{} {
+	t.Helper()
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kubeconfig map[string]interface{}
+	if err := yaml.Unmarshal(raw, &kubeconfig); err != nil {
+		t.Fatal(err)
+	}
+	return kubeconfig
+}
+
+func names(t *testing.T, kubeconfig map[string]interface{}, section string) []string {
+	t.Helper()
+	found := []string{}
+	for _, entry := range kubeconfig[section].([]interface{}) {
+		found = append(found, entryName(entry))
+	}
+	return found
+}
+
+func TestWriteKubeconfigMerges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".kube", "config")
+	other, err := workload.NewKubeconfig(workload.KubeconfigArgs{
+		ClusterName: "other", Endpoint: "https://other", CertificateAuthority: "Q0EK", Region: "us-east-1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other["preferences"] = map[string]interface{}{}
+	if err := writeKubeconfig(path, other); err != nil {
+		t.Fatal(err)
+	}
+	// A file left world-readable by another tool is replaced by one only the owner can read
+	if err := os.Chmod(path, 0644); err != nil {
+		t.Fatal(err)
+	}
+	args, err := newKubeconfigArgs(auto.ConfigMap{"aws:region": {Value: "us-west-2"}}, clusterOutputs(), &kubeconfigOptions{Context: "xbeam-dev"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		kubeconfig, err := workload.NewKubeconfig(args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := writeKubeconfig(path, kubeconfig); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := policy.CheckKubeconfigFile(path); err != nil {
+		t.Error(err)
+	}
+	merged := readKubeconfig(t, path)
+	for _, section := range kubeconfigSections {
+		if got := names(t, merged, section); len(got) != 2 || got[0] != "other" || got[1] != "xbeam-dev" {
+			t.Errorf("%s: expected other and xbeam-dev once each, got %v", section, got)
+		}
+	}
+	if merged["current-context"] != "xbeam-dev" || merged["preferences"] == nil {
+		t.Errorf("unexpected kubeconfig %v", merged)
+	}
+}
+
+func TestWriteKubeconfigRejectsInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, []byte("clusters: ["), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeKubeconfig(path, map[string]interface{}{}); err == nil {
+		t.Error("expected an invalid kubeconfig to be left alone")
+	}
+}
@@ -0,0 +1,349 @@
+// xbeam-infra deploys the XBeam workload with the Pulumi Automation API. The program is embedded, only the
+// Pulumi CLI is needed, and state is kept in a local file backend unless --backend is given.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/events"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/optdestroy"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/optpreview"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/optup"
+	"github.com/spf13/cobra"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := newRootCommand().ExecuteContext(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func newRootCommand() *cobra.Command {
+	options := &stackOptions{}
+	root := &cobra.Command{
+		Use:           "xbeam-infra",
+		Short:         "Deploy the XBeam workload infrastructure",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	root.PersistentFlags().StringVarP(&options.Stack, "stack", "s", "dev", "stack name")
+	root.PersistentFlags().StringVar(&options.Home, "home", defaultHome(), "directory of the stack config files and the local state")
+	root.PersistentFlags().StringVar(&options.Backend, "backend", os.Getenv("PULUMI_BACKEND_URL"), "Pulumi backend URL, defaults to a file backend in --home")
+	root.AddCommand(
+		newInitCommand(options),
+		newUpCommand(options),
+		newPreviewCommand(options),
+		newPreflightCommand(options),
+		newDestroyCommand(options),
+		newStatusCommand(options),
+		newOutputsCommand(options),
+		newKubeconfigCommand(options),
+		newConfigCommand(options),
+	)
+	return root
+}
+
+// importConfig sets every value of a Pulumi.<stack>.yaml file on the stack, when a file is given.
+func importConfig(ctx context.Context, stack auto.Stack, configFile string) error {
+	if configFile == "" {
+		return nil
+	}
+	config, err := loadConfigFile(configFile)
+	if err != nil {
+		return err
+	}
+	return stack.SetAllConfig(ctx, config)
+}
+
+// streamProgress starts printing engine events, the returned function waits for the stream to end
+// and prints the phase summary.
+func streamProgress(out io.Writer) (chan events.EngineEvent, func()) {
+	stream := make(chan events.EngineEvent)
+	done := make(chan struct{})
+	p := newProgress(out)
+	go p.consume(stream, done)
+	return stream, func() {
+		<-done
+		p.summary()
+	}
+}
+
+func newUpCommand(options *stackOptions) *cobra.Command {
+	var configFile string
+	var skipPreflight bool
+	var packs policyPacks
+	command := &cobra.Command{
+		Use:   "up",
+		Short: "Create or update the workload",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			stack, err := selectStack(ctx, options)
+			if err != nil {
+				return err
+			}
+			if err := importConfig(ctx, stack, configFile); err != nil {
+				return err
+			}
+			paths, err := packs.absolute()
+			if err != nil {
+				return err
+			}
+			if !skipPreflight {
+				if err := preflightStack(ctx, stack, cmd.OutOrStdout()); err != nil {
+					return fmt.Errorf("%w, fix them or pass --skip-preflight", err)
+				}
+			}
+			stream, wait := streamProgress(cmd.OutOrStdout())
+			_, err = stack.Up(ctx, optup.EventStreams(stream), upPolicyPacks(paths))
+			wait()
+			return err
+		},
+	}
+	command.Flags().StringVar(&configFile, "config-file", "", "import config from a Pulumi.<stack>.yaml file first")
+	command.Flags().BoolVar(&skipPreflight, "skip-preflight", false, "deploy without checking the quotas, offerings and image access first")
+	packs.addFlag(command)
+	return command
+}
+
+func newPreviewCommand(options *stackOptions) *cobra.Command {
+	var configFile string
+	var packs policyPacks
+	command := &cobra.Command{
+		Use:   "preview",
+		Short: "Show the changes up would make",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			stack, err := selectStack(ctx, options)
+			if err != nil {
+				return err
+			}
+			if err := importConfig(ctx, stack, configFile); err != nil {
+				return err
+			}
+			paths, err := packs.absolute()
+			if err != nil {
+				return err
+			}
+			stream, wait := streamProgress(cmd.OutOrStdout())
+			_, err = stack.Preview(ctx, optpreview.EventStreams(stream), previewPolicyPacks(paths))
+			wait()
+			return err
+		},
+	}
+	command.Flags().StringVar(&configFile, "config-file", "", "import config from a Pulumi.<stack>.yaml file first")
+	packs.addFlag(command)
+	return command
+}
+
+func newDestroyCommand(options *stackOptions) *cobra.Command {
+	var yes bool
+	command := &cobra.Command{
+		Use:   "destroy",
+		Short: "Delete every resource of the workload",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !yes {
+				return errors.New("destroy deletes the cluster and its network, pass --yes to confirm")
+			}
+			ctx := cmd.Context()
+			stack, err := selectStack(ctx, options)
+			if err != nil {
+				return err
+			}
+			stream, wait := streamProgress(cmd.OutOrStdout())
+			_, err = stack.Destroy(ctx, optdestroy.EventStreams(stream))
+			wait()
+			return err
+		},
+	}
+	command.Flags().BoolVar(&yes, "yes", false, "confirm the deletion")
+	return command
+}
+
+func newStatusCommand(options *stackOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "Show the stack and its last update",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			stack, err := selectStack(ctx, options)
+			if err != nil {
+				return err
+			}
+			info, err := stack.Info(ctx)
+			if err != nil {
+				return err
+			}
+			history, err := stack.History(ctx, 1, 1)
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			fmt.Fprintf(out, "Stack:     %s\n", stack.Name())
+			fmt.Fprintf(out, "Backend:   %s\n", options.backendURL())
+			if info.ResourceCount != nil {
+				fmt.Fprintf(out, "Resources: %d\n", *info.ResourceCount)
+			}
+			if info.UpdateInProgress {
+				fmt.Fprintln(out, "An update is in progress")
+			}
+			if len(history) == 0 {
+				fmt.Fprintln(out, "Never deployed")
+				return nil
+			}
+			last := history[0]
+			fmt.Fprintf(out, "Last %s: %s, started %s", last.Kind, last.Result, last.StartTime)
+			if last.EndTime != nil {
+				fmt.Fprintf(out, ", ended %s", *last.EndTime)
+			}
+			fmt.Fprintln(out)
+			if last.ResourceChanges != nil {
+				changes := *last.ResourceChanges
+				ops := make([]string, 0, len(changes))
+				for op := range changes {
+					ops = append(ops, op)
+				}
+				sort.Strings(ops)
+				for _, op := range ops {
+					fmt.Fprintf(out, "  %-8s %d\n", op, changes[op])
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// outputValues masks secret outputs unless showSecrets is set.
+func outputValues(outputs auto.OutputMap, showSecrets bool) map[string]interface{} {
+	values := map[string]interface{}{}
+	for name, output := range outputs {
+		if output.Secret && !showSecrets {
+			values[name] = "[secret]"
+			continue
+		}
+		values[name] = output.Value
+	}
+	return values
+}
+
+func newOutputsCommand(options *stackOptions) *cobra.Command {
+	var asJSON, showSecrets bool
+	command := &cobra.Command{
+		Use:   "outputs",
+		Short: "Print the stack outputs",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			stack, err := selectStack(ctx, options)
+			if err != nil {
+				return err
+			}
+			outputs, err := stack.Outputs(ctx)
+			if err != nil {
+				return err
+			}
+			values := outputValues(outputs, showSecrets)
+			out := cmd.OutOrStdout()
+			if asJSON {
+				encoder := json.NewEncoder(out)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(values)
+			}
+			names := make([]string, 0, len(values))
+			for name := range values {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				fmt.Fprintf(out, "%s: %v\n", name, values[name])
+			}
+			return nil
+		},
+	}
+	command.Flags().BoolVar(&asJSON, "json", false, "print the outputs as a JSON object")
+	command.Flags().BoolVar(&showSecrets, "show-secrets", false, "print secret outputs in plain text")
+	return command
+}
+
+func newConfigCommand(options *stackOptions) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "config",
+		Short: "Manage the stack config",
+	}
+	var secret bool
+	set := &cobra.Command{
+		Use:   "set <key> <value>",
+		Short: "Set a config value, e.g. worker:linuxInstance g4dn.2xlarge",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			stack, err := selectStack(ctx, options)
+			if err != nil {
+				return err
+			}
+			return stack.SetConfig(ctx, args[0], auto.ConfigValue{
+				Value:  args[1],
+				Secret: secret || isSecretConfigKey(args[0]),
+			})
+		},
+	}
+	set.Flags().BoolVar(&secret, "secret", false, "encrypt the value")
+	importFile := &cobra.Command{
+		Use:   "import <file>",
+		Short: "Set every value of a Pulumi.<stack>.yaml file",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			stack, err := selectStack(ctx, options)
+			if err != nil {
+				return err
+			}
+			return importConfig(ctx, stack, args[0])
+		},
+	}
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "Print the stack config, secrets masked",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			stack, err := selectStack(ctx, options)
+			if err != nil {
+				return err
+			}
+			config, err := stack.GetAllConfig(ctx)
+			if err != nil {
+				return err
+			}
+			keys := make([]string, 0, len(config))
+			for key := range config {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				value := config[key].Value
+				if config[key].Secret {
+					value = "[secret]"
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "%s: %s\n", key, value)
+			}
+			return nil
+		},
+	}
+	command.AddCommand(set, importFile, list)
+	return command
+}
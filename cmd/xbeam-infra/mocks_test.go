@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/servicequotas"
+	sqtypes "github.com/aws/aws-sdk-go-v2/service/servicequotas/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+)
+
+type fakeSTS struct {
+	arn string
+}
+
+func (f *fakeSTS) GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+	return &sts.GetCallerIdentityOutput{Account: aws.String("210987654321"), Arn: aws.String(f.arn)}, nil
+}
+
+// fakeEC2 offers its instance types in every location that is asked for, except the ones in missingIn
+type fakeEC2 struct {
+	offered   []string
+	missingIn map[string]string
+	vcpus     map[string]int32
+	vpcs      int
+	gateways  int
+	addresses int
+	images    []string
+	// imageFilters are the filters of the last DescribeImages
+	imageFilters []ec2types.Filter
+	// runError is the error code of the dry run launch
+	runError string
+}
+
+func (f *fakeEC2) DescribeInstanceTypeOfferings(ctx context.Context, params *ec2.DescribeInstanceTypeOfferingsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstanceTypeOfferingsOutput, error) {
+	locations := []string{"us-west-2"}
+	for _, filter := range params.Filters {
+		if aws.ToString(filter.Name) == "location" {
+			locations = filter.Values
+		}
+	}
+	output := &ec2.DescribeInstanceTypeOfferingsOutput{}
+	for _, instanceType := range f.offered {
+		for _, location := range locations {
+			if f.missingIn[instanceType] == location {
+				continue
+			}
+			output.InstanceTypeOfferings = append(output.InstanceTypeOfferings, ec2types.InstanceTypeOffering{
+				InstanceType: ec2types.InstanceType(instanceType),
+				Location:     aws.String(location),
+			})
+		}
+	}
+	return output, nil
+}
+
+func (f *fakeEC2) DescribeInstanceTypes(ctx context.Context, params *ec2.DescribeInstanceTypesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstanceTypesOutput, error) {
+	output := &ec2.DescribeInstanceTypesOutput{}
+	for _, instanceType := range params.InstanceTypes {
+		if vcpus, ok := f.vcpus[string(instanceType)]; ok {
+			output.InstanceTypes = append(output.InstanceTypes, ec2types.InstanceTypeInfo{
+				InstanceType: instanceType,
+				VCpuInfo:     &ec2types.VCpuInfo{DefaultVCpus: aws.Int32(vcpus)},
+			})
+		}
+	}
+	return output, nil
+}
+
+func (f *fakeEC2) DescribeVpcs(ctx context.Context, params *ec2.DescribeVpcsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeVpcsOutput, error) {
+	return &ec2.DescribeVpcsOutput{Vpcs: make([]ec2types.Vpc, f.vpcs)}, nil
+}
+
+func (f *fakeEC2) DescribeInternetGateways(ctx context.Context, params *ec2.DescribeInternetGatewaysInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInternetGatewaysOutput, error) {
+	return &ec2.DescribeInternetGatewaysOutput{InternetGateways: make([]ec2types.InternetGateway, f.gateways)}, nil
+}
+
+func (f *fakeEC2) DescribeAddresses(ctx context.Context, params *ec2.DescribeAddressesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeAddressesOutput, error) {
+	return &ec2.DescribeAddressesOutput{Addresses: make([]ec2types.Address, f.addresses)}, nil
+}
+
+func (f *fakeEC2) DescribeImages(ctx context.Context, params *ec2.DescribeImagesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeImagesOutput, error) {
+	f.imageFilters = params.Filters
+	output := &ec2.DescribeImagesOutput{}
+	for i, image := range f.images {
+		output.Images = append(output.Images, ec2types.Image{
+			ImageId:      aws.String(image),
+			CreationDate: aws.String(fmt.Sprintf("2024-0%d-01T00:00:00.000Z", i+1)),
+		})
+	}
+	return output, nil
+}
+
+func (f *fakeEC2) RunInstances(ctx context.Context, params *ec2.RunInstancesInput, optFns ...func(*ec2.Options)) (*ec2.RunInstancesOutput, error) {
+	return nil, &smithy.GenericAPIError{Code: f.runError, Message: "dry run of " + aws.ToString(params.ImageId)}
+}
+
+// fakeQuotas returns the applied quotas by code, and the default for the others
+type fakeQuotas struct {
+	applied  map[string]float64
+	defaults map[string]float64
+}
+
+func (f *fakeQuotas) GetServiceQuota(ctx context.Context, params *servicequotas.GetServiceQuotaInput, optFns ...func(*servicequotas.Options)) (*servicequotas.GetServiceQuotaOutput, error) {
+	value, ok := f.applied[aws.ToString(params.QuotaCode)]
+	if !ok {
+		return nil, &sqtypes.NoSuchResourceException{Message: aws.String("no applied quota")}
+	}
+	return &servicequotas.GetServiceQuotaOutput{Quota: &sqtypes.ServiceQuota{Value: aws.Float64(value)}}, nil
+}
+
+func (f *fakeQuotas) GetAWSDefaultServiceQuota(ctx context.Context, params *servicequotas.GetAWSDefaultServiceQuotaInput, optFns ...func(*servicequotas.Options)) (*servicequotas.GetAWSDefaultServiceQuotaOutput, error) {
+	value, ok := f.defaults[aws.ToString(params.QuotaCode)]
+	if !ok {
+		return nil, fmt.Errorf("unknown quota %s", aws.ToString(params.QuotaCode))
+	}
+	return &servicequotas.GetAWSDefaultServiceQuotaOutput{Quota: &sqtypes.ServiceQuota{Value: aws.Float64(value)}}, nil
+}
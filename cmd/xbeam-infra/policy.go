@@ -0,0 +1,41 @@
+package main
+
+import (
+	"path/filepath"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/optpreview"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/optup"
+	"github.com/spf13/cobra"
+)
+
+// policyPacks are the --policy-pack directories, the Automation API has no option helper for them.
+type policyPacks []string
+
+func (p *policyPacks) addFlag(command *cobra.Command) {
+	command.Flags().StringArrayVar((*[]string)(p), "policy-pack", nil, "run the policy pack in this directory, e.g. policypack")
+}
+
+// absolute resolves the directories here, the Pulumi CLI runs in the workspace directory.
+func (p policyPacks) absolute() ([]string, error) {
+	paths := []string{}
+	for _, path := range p {
+		absolute, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, absolute)
+	}
+	return paths, nil
+}
+
+type upPolicyPacks []string
+
+func (p upPolicyPacks) ApplyOption(opts *optup.Options) {
+	opts.PolicyPacks = append(opts.PolicyPacks, p...)
+}
+
+type previewPolicyPacks []string
+
+func (p previewPolicyPacks) ApplyOption(opts *optpreview.Options) {
+	opts.PolicyPacks = append(opts.PolicyPacks, p...)
+}
@@ -0,0 +1,477 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/servicequotas"
+	sqtypes "github.com/aws/aws-sdk-go-v2/service/servicequotas/types"
+	"github.com/aws/smithy-go"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"github.com/spf13/cobra"
+
+	"infra/workload"
+)
+
+const (
+	CHECK_PASS = "PASS"
+	CHECK_WARN = "WARN"
+	CHECK_FAIL = "FAIL"
+	// A quota used above this ratio is reported as a warning
+	QUOTA_WARN_RATIO = 0.8
+)
+
+// Running On-Demand vCPU quotas by instance family, families without an entry are not checked
+var vcpuQuotas = map[string]vcpuQuota{
+	"g":  {Code: "L-DB2E81BA", Label: "G and VT"},
+	"vt": {Code: "L-DB2E81BA", Label: "G and VT"},
+	"p":  {Code: "L-417A185B", Label: "P"},
+	"a":  {Code: "L-1216C47A", Label: "Standard"},
+	"c":  {Code: "L-1216C47A", Label: "Standard"},
+	"d":  {Code: "L-1216C47A", Label: "Standard"},
+	"h":  {Code: "L-1216C47A", Label: "Standard"},
+	"i":  {Code: "L-1216C47A", Label: "Standard"},
+	"m":  {Code: "L-1216C47A", Label: "Standard"},
+	"r":  {Code: "L-1216C47A", Label: "Standard"},
+	"t":  {Code: "L-1216C47A", Label: "Standard"},
+	"z":  {Code: "L-1216C47A", Label: "Standard"},
+}
+
+type vcpuQuota struct {
+	Code  string
+	Label string
+}
+
+// networkQuota is a regional limit the network of the workload counts against
+type networkQuota struct {
+	Name    string
+	Service string
+	Code    string
+	// Creates is the number setupEKSNetwork adds
+	Creates int
+	// inUse counts the existing resources in the region
+	inUse func(ctx context.Context, api ec2API) (int, error)
+}
+
+type checkResult struct {
+	Status string
+	Name   string
+	Detail string
+}
+
+type preflightReport struct {
+	Results []checkResult
+}
+
+func (r *preflightReport) add(status string, name string, format string, args ...interface{}) {
+	r.Results = append(r.Results, checkResult{Status: status, Name: name, Detail: fmt.Sprintf(format, args...)})
+}
+
+func (r *preflightReport) count(status string) int {
+	count := 0
+	for _, result := range r.Results {
+		if result.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+func (r *preflightReport) failed() bool {
+	return r.count(CHECK_FAIL) > 0
+}
+
+func (r *preflightReport) print(out io.Writer) {
+	width := 0
+	for _, result := range r.Results {
+		width = max(width, len(result.Name))
+	}
+	for _, result := range r.Results {
+		fmt.Fprintf(out, "%s  %-*s  %s\n", result.Status, width, result.Name, result.Detail)
+	}
+	fmt.Fprintf(out, "Preflight: %d passed, %d warnings, %d failed\n", r.count(CHECK_PASS), r.count(CHECK_WARN), r.count(CHECK_FAIL))
+}
+
+type poolSize struct {
+	Pool         string
+	InstanceType string
+	MaxSize      int
+}
+
+// preflightConfig is the part of the stack config the checks need
+type preflightConfig struct {
+	Region string
+	Pools  []poolSize
+	// Deployed is set when the network of the stack exists already, so it does not count against the limits again
+	Deployed bool
+	// KubernetesVersion and WindowsAmiNamePattern select the Windows worker image as the program does
+	KubernetesVersion     string
+	WindowsAmiNamePattern string
+}
+
+func newPreflightConfig(config auto.ConfigMap, outputs auto.OutputMap) (*preflightConfig, error) {
+	region := config["aws:region"].Value
+	if region == "" {
+		return nil, errors.New("aws:region is required")
+	}
+	pools := []poolSize{{Pool: workload.POOL_SYSTEM, InstanceType: workload.SYSTEM_INSTANCE_TYPE, MaxSize: workload.SYSTEM_POOL_MAX_SIZE}}
+	for _, pool := range []string{workload.POOL_LINUX, workload.POOL_WINDOWS} {
+		instanceType := config["worker:"+pool+"Instance"].Value
+		if instanceType == "" {
+			return nil, fmt.Errorf("worker:%sInstance is required", pool)
+		}
+		maxSize, err := strconv.Atoi(config["worker:"+pool+"MaxSize"].Value)
+		if err != nil {
+			return nil, fmt.Errorf("worker:%sMaxSize must be a number", pool)
+		}
+		pools = append(pools, poolSize{Pool: pool, InstanceType: instanceType, MaxSize: maxSize})
+	}
+	_, deployed := outputs["VPC"]
+	version := config["eks:version"].Value
+	if version == "" {
+		version = workload.K8S_VERSION
+	}
+	return &preflightConfig{
+		Region:                region,
+		Pools:                 pools,
+		Deployed:              deployed,
+		KubernetesVersion:     version,
+		WindowsAmiNamePattern: config["worker:windowsAmiNamePattern"].Value,
+	}, nil
+}
+
+func (c *preflightConfig) instanceTypes() []string {
+	seen := map[string]bool{}
+	types := []string{}
+	for _, pool := range c.Pools {
+		if !seen[pool.InstanceType] {
+			seen[pool.InstanceType] = true
+			types = append(types, pool.InstanceType)
+		}
+	}
+	return types
+}
+
+func (c *preflightConfig) windowsInstanceType() string {
+	for _, pool := range c.Pools {
+		if pool.Pool == workload.POOL_WINDOWS {
+			return pool.InstanceType
+		}
+	}
+	return ""
+}
+
+// runPreflight checks that the account can hold the workload. Checks that cannot be made, e.g. for a missing
+// permission, are reported as warnings.
+func runPreflight(ctx context.Context, clients *awsClients, config *preflightConfig) *preflightReport {
+	report := &preflightReport{}
+	checkVcpuQuotas(ctx, clients, config, report)
+	checkNetworkQuotas(ctx, clients, config, report)
+	checkOfferings(ctx, clients.ec2, config, report)
+	checkWindowsImage(ctx, clients.ec2, config, report)
+	return report
+}
+
+// instanceFamily returns the leading letters of an instance type, e.g. g for g4dn.xlarge
+func instanceFamily(instanceType string) string {
+	end := strings.IndexAny(instanceType, "0123456789.")
+	if end < 0 {
+		return instanceType
+	}
+	return instanceType[:end]
+}
+
+// serviceQuota returns the applied quota, or the AWS default for quotas that were never changed.
+func serviceQuota(ctx context.Context, api quotasAPI, service string, code string) (float64, error) {
+	var quota *sqtypes.ServiceQuota
+	applied, err := api.GetServiceQuota(ctx, &servicequotas.GetServiceQuotaInput{ServiceCode: aws.String(service), QuotaCode: aws.String(code)})
+	var missing *sqtypes.NoSuchResourceException
+	switch {
+	case errors.As(err, &missing):
+		fallback, err := api.GetAWSDefaultServiceQuota(ctx, &servicequotas.GetAWSDefaultServiceQuotaInput{ServiceCode: aws.String(service), QuotaCode: aws.String(code)})
+		if err != nil {
+			return 0, err
+		}
+		quota = fallback.Quota
+	case err != nil:
+		return 0, err
+	default:
+		quota = applied.Quota
+	}
+	if quota == nil || quota.Value == nil {
+		return 0, fmt.Errorf("quota %s of %s has no value", code, service)
+	}
+	return *quota.Value, nil
+}
+
+// quotaStatus compares what is needed with the quota
+func quotaStatus(needed float64, quota float64) string {
+	switch {
+	case needed > quota:
+		return CHECK_FAIL
+	case needed > quota*QUOTA_WARN_RATIO:
+		return CHECK_WARN
+	default:
+		return CHECK_PASS
+	}
+}
+
+// checkVcpuQuotas compares the vCPUs of every pool at its maximum size with the On-Demand quota of its family.
+func checkVcpuQuotas(ctx context.Context, clients *awsClients, config *preflightConfig, report *preflightReport) {
+	described, err := clients.ec2.DescribeInstanceTypes(ctx, &ec2.DescribeInstanceTypesInput{
+		InstanceTypes: toInstanceTypes(config.instanceTypes()),
+	})
+	if err != nil {
+		report.add(CHECK_WARN, "vCPU quotas", "cannot describe the instance types: %v", err)
+		return
+	}
+	vcpus := map[string]int{}
+	for _, info := range described.InstanceTypes {
+		if info.VCpuInfo != nil && info.VCpuInfo.DefaultVCpus != nil {
+			vcpus[string(info.InstanceType)] = int(*info.VCpuInfo.DefaultVCpus)
+		}
+	}
+	needed := map[vcpuQuota]int{}
+	for _, pool := range config.Pools {
+		quota, ok := vcpuQuotas[instanceFamily(pool.InstanceType)]
+		if !ok {
+			report.add(CHECK_WARN, "vCPU quotas", "no quota is known for %s of the %s pool", pool.InstanceType, pool.Pool)
+			continue
+		}
+		if _, ok := vcpus[pool.InstanceType]; !ok {
+			report.add(CHECK_FAIL, "vCPU quotas", "%s of the %s pool is not a known instance type", pool.InstanceType, pool.Pool)
+			continue
+		}
+		needed[quota] += vcpus[pool.InstanceType] * pool.MaxSize
+	}
+	quotas := make([]vcpuQuota, 0, len(needed))
+	for quota := range needed {
+		quotas = append(quotas, quota)
+	}
+	sort.Slice(quotas, func(i, j int) bool { return quotas[i].Label < quotas[j].Label })
+	for _, quota := range quotas {
+		name := fmt.Sprintf("vCPU quota %s", quota.Label)
+		limit, err := serviceQuota(ctx, clients.quotas, "ec2", quota.Code)
+		if err != nil {
+			report.add(CHECK_WARN, name, "cannot read quota %s: %v", quota.Code, err)
+			continue
+		}
+		report.add(quotaStatus(float64(needed[quota]), limit), name,
+			"the pools need up to %d of %.0f On-Demand vCPUs, running instances count against the same quota", needed[quota], limit)
+	}
+}
+
+func toInstanceTypes(names []string) []ec2types.InstanceType {
+	types := make([]ec2types.InstanceType, len(names))
+	for i, name := range names {
+		types[i] = ec2types.InstanceType(name)
+	}
+	return types
+}
+
+var networkQuotas = []networkQuota{
+	{
+		Name: "VPCs", Service: "vpc", Code: "L-F678F1CE", Creates: workload.NetworkFootprint.Vpcs,
+		inUse: func(ctx context.Context, api ec2API) (int, error) {
+			count := 0
+			paginator := ec2.NewDescribeVpcsPaginator(api, &ec2.DescribeVpcsInput{})
+			for paginator.HasMorePages() {
+				page, err := paginator.NextPage(ctx)
+				if err != nil {
+					return 0, err
+				}
+				count += len(page.Vpcs)
+			}
+			return count, nil
+		},
+	},
+	{
+		Name: "Internet gateways", Service: "vpc", Code: "L-A4707A72", Creates: workload.NetworkFootprint.InternetGateways,
+		inUse: func(ctx context.Context, api ec2API) (int, error) {
+			count := 0
+			paginator := ec2.NewDescribeInternetGatewaysPaginator(api, &ec2.DescribeInternetGatewaysInput{})
+			for paginator.HasMorePages() {
+				page, err := paginator.NextPage(ctx)
+				if err != nil {
+					return 0, err
+				}
+				count += len(page.InternetGateways)
+			}
+			return count, nil
+		},
+	},
+	{
+		Name: "Elastic IPs", Service: "ec2", Code: "L-0263D0A3", Creates: workload.NetworkFootprint.ElasticIps,
+		inUse: func(ctx context.Context, api ec2API) (int, error) {
+			addresses, err := api.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{})
+			if err != nil {
+				return 0, err
+			}
+			return len(addresses.Addresses), nil
+		},
+	},
+}
+
+// checkNetworkQuotas checks that the region has room for the network setupEKSNetwork creates.
+func checkNetworkQuotas(ctx context.Context, clients *awsClients, config *preflightConfig, report *preflightReport) {
+	for _, quota := range networkQuotas {
+		if config.Deployed {
+			report.add(CHECK_PASS, quota.Name, "the network of the stack exists already")
+			continue
+		}
+		inUse, err := quota.inUse(ctx, clients.ec2)
+		if err != nil {
+			report.add(CHECK_WARN, quota.Name, "cannot count the %s in use: %v", quota.Name, err)
+			continue
+		}
+		limit, err := serviceQuota(ctx, clients.quotas, quota.Service, quota.Code)
+		if err != nil {
+			report.add(CHECK_WARN, quota.Name, "cannot read quota %s: %v", quota.Code, err)
+			continue
+		}
+		report.add(quotaStatus(float64(inUse+quota.Creates), limit), quota.Name,
+			"%d in use and %d to create, the limit is %.0f", inUse, quota.Creates, limit)
+	}
+}
+
+// checkOfferings checks that every instance type is offered in each availability zone of the network.
+func checkOfferings(ctx context.Context, api ec2API, config *preflightConfig, report *preflightReport) {
+	zones := workload.NetworkAvailabilityZones(config.Region)
+	offered := map[string]map[string]bool{}
+	paginator := ec2.NewDescribeInstanceTypeOfferingsPaginator(api, &ec2.DescribeInstanceTypeOfferingsInput{
+		LocationType: ec2types.LocationTypeAvailabilityZone,
+		Filters: []ec2types.Filter{
+			{Name: aws.String("location"), Values: zones},
+			{Name: aws.String("instance-type"), Values: config.instanceTypes()},
+		},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			report.add(CHECK_WARN, "Instance offerings", "cannot list the instance type offerings: %v", err)
+			return
+		}
+		for _, offering := range page.InstanceTypeOfferings {
+			instanceType := string(offering.InstanceType)
+			if offered[instanceType] == nil {
+				offered[instanceType] = map[string]bool{}
+			}
+			offered[instanceType][aws.ToString(offering.Location)] = true
+		}
+	}
+	for _, instanceType := range config.instanceTypes() {
+		missing := []string{}
+		for _, zone := range zones {
+			if !offered[instanceType][zone] {
+				missing = append(missing, zone)
+			}
+		}
+		name := "Offering " + instanceType
+		if len(missing) > 0 {
+			report.add(CHECK_FAIL, name, "not offered in %s", strings.Join(missing, ", "))
+			continue
+		}
+		report.add(CHECK_PASS, name, "offered in %s", strings.Join(zones, ", "))
+	}
+}
+
+// checkWindowsImage checks that the Windows worker image the program would pick is visible and that the account is subscribed to its
+// marketplace product. A dry run launch fails with OptInRequired without the subscription.
+func checkWindowsImage(ctx context.Context, api ec2API, config *preflightConfig, report *preflightReport) {
+	const name = "Windows image"
+	var filters []ec2types.Filter
+	for _, filter := range workload.WindowsAmiFilters(config.WindowsAmiNamePattern, config.KubernetesVersion) {
+		filters = append(filters, ec2types.Filter{Name: aws.String(filter.Name), Values: filter.Values})
+	}
+	images, err := api.DescribeImages(ctx, &ec2.DescribeImagesInput{Filters: filters})
+	if err != nil {
+		report.add(CHECK_WARN, name, "cannot describe the images: %v", err)
+		return
+	}
+	if len(images.Images) == 0 {
+		if config.WindowsAmiNamePattern != "" {
+			report.add(CHECK_FAIL, name, "no image of product %s matches %s for Kubernetes %s in %s", workload.XBEAM_WINDOWS_PRODUCT_CODE, config.WindowsAmiNamePattern, config.KubernetesVersion, config.Region)
+			return
+		}
+		report.add(CHECK_FAIL, name, "no image of product %s is visible in %s", workload.XBEAM_WINDOWS_PRODUCT_CODE, config.Region)
+		return
+	}
+	sort.Slice(images.Images, func(i, j int) bool {
+		return aws.ToString(images.Images[i].CreationDate) > aws.ToString(images.Images[j].CreationDate)
+	})
+	imageId := aws.ToString(images.Images[0].ImageId)
+	_, err = api.RunInstances(ctx, &ec2.RunInstancesInput{
+		DryRun:       aws.Bool(true),
+		ImageId:      aws.String(imageId),
+		InstanceType: ec2types.InstanceType(config.windowsInstanceType()),
+		MinCount:     aws.Int32(1),
+		MaxCount:     aws.Int32(1),
+	})
+	var apiErr smithy.APIError
+	switch {
+	case err == nil:
+		report.add(CHECK_PASS, name, "%s can be launched", imageId)
+	case errors.As(err, &apiErr) && apiErr.ErrorCode() == "DryRunOperation":
+		report.add(CHECK_PASS, name, "%s can be launched", imageId)
+	case errors.As(err, &apiErr) && apiErr.ErrorCode() == "OptInRequired":
+		report.add(CHECK_FAIL, name, "%s needs a subscription to marketplace product %s", imageId, workload.XBEAM_WINDOWS_PRODUCT_CODE)
+	default:
+		report.add(CHECK_WARN, name, "%s is visible, the subscription cannot be checked: %v", imageId, err)
+	}
+}
+
+// preflightStack runs the checks against the config and outputs of a stack and prints the report.
+func preflightStack(ctx context.Context, stack auto.Stack, out io.Writer) error {
+	config, err := stack.GetAllConfig(ctx)
+	if err != nil {
+		return err
+	}
+	outputs, err := stack.Outputs(ctx)
+	if err != nil {
+		return err
+	}
+	checks, err := newPreflightConfig(config, outputs)
+	if err != nil {
+		return err
+	}
+	clients, err := newAWSClients(ctx, checks.Region)
+	if err != nil {
+		return err
+	}
+	report := runPreflight(ctx, clients, checks)
+	report.print(out)
+	if report.failed() {
+		return errors.New("preflight checks failed")
+	}
+	return nil
+}
+
+func newPreflightCommand(options *stackOptions) *cobra.Command {
+	var configFile string
+	command := &cobra.Command{
+		Use:   "preflight",
+		Short: "Check the AWS quotas, instance offerings and image access of the stack",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			stack, err := selectStack(ctx, options)
+			if err != nil {
+				return err
+			}
+			if err := importConfig(ctx, stack, configFile); err != nil {
+				return err
+			}
+			return preflightStack(ctx, stack, cmd.OutOrStdout())
+		},
+	}
+	command.Flags().StringVar(&configFile, "config-file", "", "import config from a Pulumi.<stack>.yaml file first")
+	return command
+}
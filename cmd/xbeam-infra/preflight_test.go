@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+
+	"infra/workload"
+)
+
+func preflightClients(ec2 *fakeEC2) *awsClients {
+	return &awsClients{
+		ec2: ec2,
+		quotas: &fakeQuotas{
+			applied: map[string]float64{"L-DB2E81BA": 64},
+			defaults: map[string]float64{
+				"L-DB2E81BA": 0, "L-1216C47A": 1152, "L-F678F1CE": 5, "L-A4707A72": 5, "L-0263D0A3": 5,
+			},
+		},
+	}
+}
+
+func healthyEC2() *fakeEC2 {
+	return &fakeEC2{
+		offered:   []string{"g5.xlarge", "g4dn.2xlarge", workload.SYSTEM_INSTANCE_TYPE},
+		vcpus:     map[string]int32{"g5.xlarge": 4, "g4dn.2xlarge": 8, workload.SYSTEM_INSTANCE_TYPE: 2},
+		vpcs:      1,
+		gateways:  1,
+		addresses: 1,
+		images:    []string{"ami-old", "ami-new"},
+		runError:  "DryRunOperation",
+	}
+}
+
+func testPreflightConfig() *preflightConfig {
+	return &preflightConfig{
+		Region: "us-west-2",
+		Pools: []poolSize{
+			{Pool: workload.POOL_SYSTEM, InstanceType: workload.SYSTEM_INSTANCE_TYPE, MaxSize: workload.SYSTEM_POOL_MAX_SIZE},
+			{Pool: workload.POOL_LINUX, InstanceType: "g5.xlarge", MaxSize: 3},
+			{Pool: workload.POOL_WINDOWS, InstanceType: "g4dn.2xlarge", MaxSize: 2},
+		},
+	}
+}
+
+func findResult(t *testing.T, report *preflightReport, name string) checkResult {
+	t.Helper()
+	for _, result := range report.Results {
+		if result.Name == name {
+			return result
+		}
+	}
+	t.Fatalf("no %s check in %v", name, report.Results)
+	return checkResult{}
+}
+
+func TestPreflightPass(t *testing.T) {
+	report := runPreflight(context.Background(), preflightClients(healthyEC2()), testPreflightConfig())
+	if report.failed() || report.count(CHECK_WARN) > 0 {
+		t.Errorf("expected every check to pass: %v", report.Results)
+	}
+	if result := findResult(t, report, "vCPU quota G and VT"); !strings.Contains(result.Detail, "28 of 64") {
+		t.Errorf("expected 3 x 4 + 2 x 8 vCPUs, got %q", result.Detail)
+	}
+	if result := findResult(t, report, "vCPU quota Standard"); !strings.Contains(result.Detail, "6 of 1152") {
+		t.Errorf("expected the system pool on the standard quota, got %q", result.Detail)
+	}
+	if result := findResult(t, report, "Windows image"); !strings.Contains(result.Detail, "ami-new") {
+		t.Errorf("expected the newest image, got %q", result.Detail)
+	}
+	out := &bytes.Buffer{}
+	report.print(out)
+	if !strings.Contains(out.String(), "Preflight: 9 passed, 0 warnings, 0 failed") {
+		t.Errorf("unexpected report:\n%s", out)
+	}
+}
+
+func TestPreflightFail(t *testing.T) {
+	ec2 := healthyEC2()
+	ec2.missingIn = map[string]string{"g5.xlarge": "us-west-2b"}
+	ec2.vpcs = 5
+	ec2.addresses = 3
+	ec2.runError = "OptInRequired"
+	clients := preflightClients(ec2)
+	clients.quotas.(*fakeQuotas).applied["L-DB2E81BA"] = 24
+	report := runPreflight(context.Background(), clients, testPreflightConfig())
+	expected := map[string]string{
+		"vCPU quota G and VT":   CHECK_FAIL,
+		"VPCs":                  CHECK_FAIL,
+		"Elastic IPs":           CHECK_WARN,
+		"Internet gateways":     CHECK_PASS,
+		"Offering g5.xlarge":    CHECK_FAIL,
+		"Offering g4dn.2xlarge": CHECK_PASS,
+		"Windows image":         CHECK_FAIL,
+		"vCPU quota Standard":   CHECK_PASS,
+		"Offering " + workload.SYSTEM_INSTANCE_TYPE: CHECK_PASS,
+	}
+	for name, status := range expected {
+		if result := findResult(t, report, name); result.Status != status {
+			t.Errorf("%s: expected %s, got %s %q", name, status, result.Status, result.Detail)
+		}
+	}
+	if result := findResult(t, report, "Offering g5.xlarge"); result.Detail != "not offered in us-west-2b" {
+		t.Errorf("unexpected detail %q", result.Detail)
+	}
+	if !report.failed() {
+		t.Error("expected the report to fail")
+	}
+}
+
+func TestPreflightUnknownImage(t *testing.T) {
+	ec2 := healthyEC2()
+	ec2.images = nil
+	report := runPreflight(context.Background(), preflightClients(ec2), testPreflightConfig())
+	if result := findResult(t, report, "Windows image"); result.Status != CHECK_FAIL {
+		t.Errorf("expected an invisible image to fail, got %v", result)
+	}
+	ec2 = healthyEC2()
+	ec2.runError = "UnauthorizedOperation"
+	report = runPreflight(context.Background(), preflightClients(ec2), testPreflightConfig())
+	if result := findResult(t, report, "Windows image"); result.Status != CHECK_WARN {
+		t.Errorf("expected a warning when the launch cannot be checked, got %v", result)
+	}
+}
+
+// The image is looked up with the filters of the program, so preflight passes only when up finds the image
+func TestPreflightWindowsImageFilters(t *testing.T) {
+	ec2 := healthyEC2()
+	config := testPreflightConfig()
+	config.KubernetesVersion = "1.29"
+	config.WindowsAmiNamePattern = "xbeam-windows-{version}-*"
+	runPreflight(context.Background(), preflightClients(ec2), config)
+	expected := workload.WindowsAmiFilters(config.WindowsAmiNamePattern, config.KubernetesVersion)
+	if len(ec2.imageFilters) != len(expected) {
+		t.Fatalf("expected the filters %+v, got %+v", expected, ec2.imageFilters)
+	}
+	for i, filter := range expected {
+		if aws.ToString(ec2.imageFilters[i].Name) != filter.Name || strings.Join(ec2.imageFilters[i].Values, ",") != strings.Join(filter.Values, ",") {
+			t.Errorf("expected the filter %+v, got %+v", filter, ec2.imageFilters[i])
+		}
+	}
+
+	ec2 = healthyEC2()
+	ec2.images = nil
+	report := runPreflight(context.Background(), preflightClients(ec2), config)
+	if result := findResult(t, report, "Windows image"); result.Status != CHECK_FAIL || !strings.Contains(result.Detail, "xbeam-windows-{version}-*") {
+		t.Errorf("expected a failure naming the pattern, got %v", result)
+	}
+}
+
+func TestPreflightDeployedNetwork(t *testing.T) {
+	ec2 := healthyEC2()
+	ec2.vpcs = 5
+	config := testPreflightConfig()
+	config.Deployed = true
+	report := runPreflight(context.Background(), preflightClients(ec2), config)
+	if result := findResult(t, report, "VPCs"); result.Status != CHECK_PASS {
+		t.Errorf("the VPC of a deployed stack is already counted, got %v", result)
+	}
+}
+
+func TestNewPreflightConfig(t *testing.T) {
+	config := auto.ConfigMap{
+		"aws:region":             {Value: "us-west-2"},
+		"worker:linuxInstance":   {Value: "g5.xlarge"},
+		"worker:linuxMaxSize":    {Value: "3"},
+		"worker:windowsInstance": {Value: "g4dn.2xlarge"},
+		"worker:windowsMaxSize":  {Value: "2"},
+	}
+	checks, err := newPreflightConfig(config, auto.OutputMap{"VPC": {Value: "vpc-1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !checks.Deployed || len(checks.Pools) != 3 || checks.windowsInstanceType() != "g4dn.2xlarge" {
+		t.Errorf("unexpected config %+v", checks)
+	}
+	if checks.KubernetesVersion != workload.K8S_VERSION || checks.WindowsAmiNamePattern != "" {
+		t.Errorf("expected the default Kubernetes version and no image name pattern, got %+v", checks)
+	}
+	config["eks:version"] = auto.ConfigValue{Value: "1.30"}
+	config["worker:windowsAmiNamePattern"] = auto.ConfigValue{Value: "xbeam-windows-{version}-*"}
+	checks, err = newPreflightConfig(config, nil)
+	if err != nil || checks.KubernetesVersion != "1.30" || checks.WindowsAmiNamePattern != "xbeam-windows-{version}-*" {
+		t.Errorf("expected the Windows image settings of the stack, got %+v, %v", checks, err)
+	}
+	delete(config, "worker:windowsMaxSize")
+	if _, err := newPreflightConfig(config, nil); err == nil {
+		t.Error("expected an error without worker:windowsMaxSize")
+	}
+}
+
+func TestInstanceFamily(t *testing.T) {
+	for instanceType, family := range map[string]string{"g4dn.xlarge": "g", "vt1.3xlarge": "vt", "t3.medium": "t", "p3.2xlarge": "p", "inf2.xlarge": "inf"} {
+		if got := instanceFamily(instanceType); got != family {
+			t.Errorf("%s: expected %s, got %s", instanceType, family, got)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/events"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+
+	"infra/workload"
+)
+
+// Deployment phases, in the order they run
+const (
+	PHASE_NETWORK     = "network"
+	PHASE_CLUSTER     = "cluster"
+	PHASE_NODE_GROUPS = "node groups"
+	PHASE_ADDONS      = "add-ons"
+	PHASE_OTHER       = "other"
+)
+
+var phases = []string{PHASE_NETWORK, PHASE_CLUSTER, PHASE_NODE_GROUPS, PHASE_ADDONS, PHASE_OTHER}
+
+// Operations shown in summaries, in this order
+var summaryOps = []apitype.OpType{
+	apitype.OpCreate,
+	apitype.OpUpdate,
+	apitype.OpReplace,
+	apitype.OpDelete,
+	apitype.OpSame,
+}
+
+// resourcePhase finds the phase of a resource from the component types in its URN,
+// urn:pulumi:<stack>::<project>::<parent type>$<type>::<name>.
+func resourcePhase(urn string) string {
+	parts := strings.SplitN(urn, "::", 4)
+	if len(parts) < 4 {
+		return PHASE_OTHER
+	}
+	types := strings.Split(parts[2], "$")
+	resourceType := types[len(types)-1]
+	switch {
+	case resourceType == "aws:eks/addon:Addon":
+		return PHASE_ADDONS
+	case resourceType == "aws:eks/nodeGroup:NodeGroup":
+		return PHASE_NODE_GROUPS
+	}
+	for i := len(types) - 1; i >= 0; i-- {
+		switch types[i] {
+		case workload.TYPE_WORKLOAD_NETWORK:
+			return PHASE_NETWORK
+		case workload.TYPE_GPU_NODE_POOL:
+			return PHASE_NODE_GROUPS
+		case workload.TYPE_CLUSTER_AUTOSCALER:
+			return PHASE_ADDONS
+		case workload.TYPE_WORKLOAD_CLUSTER:
+			return PHASE_CLUSTER
+		}
+	}
+	return PHASE_OTHER
+}
+
+func resourceName(urn string) string {
+	parts := strings.SplitN(urn, "::", 4)
+	return parts[len(parts)-1]
+}
+
+type phaseSummary struct {
+	ops      map[apitype.OpType]int
+	failures int
+	// Engine timestamps of the first and last step, in seconds
+	start int
+	end   int
+}
+
+// progress prints resource steps as they happen and a summary per phase at the end.
+type progress struct {
+	out     io.Writer
+	phases  map[string]*phaseSummary
+	started map[string]int
+}
+
+func newProgress(out io.Writer) *progress {
+	return &progress{
+		out:     out,
+		phases:  map[string]*phaseSummary{},
+		started: map[string]int{},
+	}
+}
+
+// consume handles events until the channel is closed, the Automation API closes it when the operation ends.
+func (p *progress) consume(stream <-chan events.EngineEvent, done chan<- struct{}) {
+	for event := range stream {
+		p.handle(event)
+	}
+	close(done)
+}
+
+func (p *progress) phase(urn string, timestamp int) *phaseSummary {
+	name := resourcePhase(urn)
+	summary, ok := p.phases[name]
+	if !ok {
+		summary = &phaseSummary{ops: map[apitype.OpType]int{}, start: timestamp}
+		p.phases[name] = summary
+	}
+	if timestamp > summary.end {
+		summary.end = timestamp
+	}
+	return summary
+}
+
+func (p *progress) handle(event events.EngineEvent) {
+	if event.Error != nil {
+		fmt.Fprintf(p.out, "error reading engine events: %v\n", event.Error)
+		return
+	}
+	switch {
+	case event.ResourcePreEvent != nil:
+		step := event.ResourcePreEvent.Metadata
+		if skipStep(step) {
+			return
+		}
+		p.phase(step.URN, event.Timestamp)
+		p.started[step.URN] = event.Timestamp
+		if step.Op != apitype.OpSame && !event.ResourcePreEvent.Planning {
+			fmt.Fprintf(p.out, "[%s] %s %s %s\n", resourcePhase(step.URN), opVerb(step.Op, false), step.Type, resourceName(step.URN))
+		}
+	case event.ResOutputsEvent != nil:
+		step := event.ResOutputsEvent.Metadata
+		if skipStep(step) {
+			return
+		}
+		summary := p.phase(step.URN, event.Timestamp)
+		summary.ops[summaryOp(step.Op)]++
+		if step.Op == apitype.OpSame {
+			return
+		}
+		if event.ResOutputsEvent.Planning {
+			fmt.Fprintf(p.out, "[%s] will %s %s %s\n", resourcePhase(step.URN), step.Op, step.Type, resourceName(step.URN))
+			return
+		}
+		elapsed := time.Duration(event.Timestamp-p.started[step.URN]) * time.Second
+		fmt.Fprintf(p.out, "[%s] %s %s %s (%s)\n", resourcePhase(step.URN), opVerb(step.Op, true), step.Type, resourceName(step.URN), elapsed)
+	case event.ResOpFailedEvent != nil:
+		step := event.ResOpFailedEvent.Metadata
+		p.phase(step.URN, event.Timestamp).failures++
+		fmt.Fprintf(p.out, "[%s] failed to %s %s %s\n", resourcePhase(step.URN), step.Op, step.Type, resourceName(step.URN))
+	case event.DiagnosticEvent != nil:
+		diagnostic := event.DiagnosticEvent
+		if diagnostic.Severity == "error" || diagnostic.Severity == "warning" {
+			fmt.Fprintf(p.out, "%s: %s", diagnostic.Severity, diagnostic.Message)
+			if !strings.HasSuffix(diagnostic.Message, "\n") {
+				fmt.Fprintln(p.out)
+			}
+		}
+	}
+}
+
+// skipStep hides the stack resource and default providers, they are not part of any phase.
+func skipStep(step apitype.StepEventMetadata) bool {
+	return step.Type == "pulumi:pulumi:Stack" || strings.HasPrefix(step.Type, "pulumi:providers:")
+}
+
+// summaryOp folds the replacement steps into replace.
+func summaryOp(op apitype.OpType) apitype.OpType {
+	switch op {
+	case apitype.OpCreateReplacement, apitype.OpDeleteReplaced, apitype.OpDiscardReplaced:
+		return apitype.OpReplace
+	}
+	return op
+}
+
+func opVerb(op apitype.OpType, done bool) string {
+	verbs := map[apitype.OpType][2]string{
+		apitype.OpCreate:            {"creating", "created"},
+		apitype.OpUpdate:            {"updating", "updated"},
+		apitype.OpDelete:            {"deleting", "deleted"},
+		apitype.OpReplace:           {"replacing", "replaced"},
+		apitype.OpCreateReplacement: {"creating replacement", "created replacement"},
+		apitype.OpDeleteReplaced:    {"deleting replaced", "deleted replaced"},
+		apitype.OpRead:              {"reading", "read"},
+		apitype.OpRefresh:           {"refreshing", "refreshed"},
+		apitype.OpImport:            {"importing", "imported"},
+	}
+	verb, ok := verbs[op]
+	if !ok {
+		return string(op)
+	}
+	if done {
+		return verb[1]
+	}
+	return verb[0]
+}
+
+// summary prints one line per phase that had steps.
+func (p *progress) summary() {
+	if len(p.phases) == 0 {
+		return
+	}
+	fmt.Fprintln(p.out, "\nSummary:")
+	for _, name := range phases {
+		summary, ok := p.phases[name]
+		if !ok {
+			continue
+		}
+		counts := []string{}
+		for _, op := range summaryOps {
+			if count := summary.ops[op]; count > 0 {
+				counts = append(counts, fmt.Sprintf("%d %s", count, op))
+			}
+		}
+		others := []string{}
+		for op, count := range summary.ops {
+			if !containsOp(summaryOps, op) {
+				others = append(others, fmt.Sprintf("%d %s", count, op))
+			}
+		}
+		sort.Strings(others)
+		counts = append(counts, others...)
+		if summary.failures > 0 {
+			counts = append(counts, fmt.Sprintf("%d failed", summary.failures))
+		}
+		if len(counts) == 0 {
+			counts = append(counts, "no changes")
+		}
+		elapsed := time.Duration(summary.end-summary.start) * time.Second
+		fmt.Fprintf(p.out, "  %-12s %s (%s)\n", name+":", strings.Join(counts, ", "), elapsed)
+	}
+}
+
+func containsOp(ops []apitype.OpType, op apitype.OpType) bool {
+	for _, o := range ops {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/events"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+)
+
+func TestResourcePhase(t *testing.T) {
+	tests := []struct {
+		urn   string
+		phase string
+	}{
+		{"urn:pulumi:dev::infra::xbeam:index:WorkloadNetwork$aws:ec2/vpc:Vpc::workload-Vpc", PHASE_NETWORK},
+		{"urn:pulumi:dev::infra::xbeam:index:WorkloadCluster$eks:index:Cluster::workload-Cluster", PHASE_CLUSTER},
+		{"urn:pulumi:dev::infra::xbeam:index:WorkloadCluster$aws:eks/nodeGroup:NodeGroup::workload-SystemNodeGroup", PHASE_NODE_GROUPS},
+		{"urn:pulumi:dev::infra::xbeam:index:WorkloadCluster$aws:eks/addon:Addon::coredns", PHASE_ADDONS},
+		{"urn:pulumi:dev::infra::xbeam:index:GpuNodePool$aws:ec2/launchTemplate:LaunchTemplate::workload-LinuxLaunchTemplate", PHASE_NODE_GROUPS},
+		{"urn:pulumi:dev::infra::xbeam:index:ClusterAutoscaler$kubernetes:helm.sh/v3:Release::cluster-autoscaler", PHASE_ADDONS},
+		// Pre-component stacks have every resource at the root
+		{"urn:pulumi:dev::infra::aws:iam/role:Role::workload-ClusterRole", PHASE_OTHER},
+		{"not-a-urn", PHASE_OTHER},
+	}
+	for _, test := range tests {
+		if phase := resourcePhase(test.urn); phase != test.phase {
+			t.Errorf("%s: expected phase %s, got %s", test.urn, test.phase, phase)
+		}
+	}
+}
+
+func stepEvent(timestamp int, op apitype.OpType, urn string, typ string, done bool) events.EngineEvent {
+	metadata := apitype.StepEventMetadata{Op: op, URN: urn, Type: typ}
+	event := apitype.EngineEvent{Timestamp: timestamp}
+	if done {
+		event.ResOutputsEvent = &apitype.ResOutputsEvent{Metadata: metadata}
+	} else {
+		event.ResourcePreEvent = &apitype.ResourcePreEvent{Metadata: metadata}
+	}
+	return events.EngineEvent{EngineEvent: event}
+}
+
+func TestProgressSummary(t *testing.T) {
+	out := &bytes.Buffer{}
+	p := newProgress(out)
+	stream := make(chan events.EngineEvent)
+	done := make(chan struct{})
+	go p.consume(stream, done)
+
+	vpc := "urn:pulumi:dev::infra::xbeam:index:WorkloadNetwork$aws:ec2/vpc:Vpc::workload-Vpc"
+	subnet := "urn:pulumi:dev::infra::xbeam:index:WorkloadNetwork$aws:ec2/subnet:Subnet::workload-Subnet"
+	cluster := "urn:pulumi:dev::infra::xbeam:index:WorkloadCluster$eks:index:Cluster::workload-Cluster"
+	stack := "urn:pulumi:dev::infra::pulumi:pulumi:Stack::infra-dev"
+	for _, event := range []events.EngineEvent{
+		stepEvent(100, apitype.OpSame, stack, "pulumi:pulumi:Stack", false),
+		stepEvent(100, apitype.OpCreate, vpc, "aws:ec2/vpc:Vpc", false),
+		stepEvent(112, apitype.OpCreate, vpc, "aws:ec2/vpc:Vpc", true),
+		stepEvent(112, apitype.OpSame, subnet, "aws:ec2/subnet:Subnet", false),
+		stepEvent(112, apitype.OpSame, subnet, "aws:ec2/subnet:Subnet", true),
+		stepEvent(120, apitype.OpUpdate, cluster, "eks:index:Cluster", false),
+		{EngineEvent: apitype.EngineEvent{Timestamp: 700, ResOpFailedEvent: &apitype.ResOpFailedEvent{
+			Metadata: apitype.StepEventMetadata{Op: apitype.OpUpdate, URN: cluster, Type: "eks:index:Cluster"},
+		}}},
+	} {
+		stream <- event
+	}
+	close(stream)
+	<-done
+	p.summary()
+
+	output := out.String()
+	for _, expected := range []string{
+		"[network] creating aws:ec2/vpc:Vpc workload-Vpc",
+		"[network] created aws:ec2/vpc:Vpc workload-Vpc (12s)",
+		"[cluster] failed to update eks:index:Cluster workload-Cluster",
+		"network:     1 create, 1 same (12s)",
+		"cluster:     1 failed (9m40s)",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected %q in output:\n%s", expected, output)
+		}
+	}
+	if strings.Contains(output, "infra-dev") {
+		t.Errorf("the stack resource must not be reported:\n%s", output)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/workspace"
+	"gopkg.in/yaml.v3"
+
+	"infra/program"
+)
+
+// Config keys stored encrypted in the stack config, even when they are imported from a plain text file
+var secretConfigKeys = []string{
+	"worker:windowsPassword",
+	"cluster:grafanaAdminPassword",
+}
+
+type stackOptions struct {
+	Stack string
+	// Home holds the project and stack config files, and the state with the default backend
+	Home string
+	// Backend is a Pulumi backend URL, defaults to a file backend in Home
+	Backend string
+}
+
+func defaultHome() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".xbeam-infra"
+	}
+	return filepath.Join(home, ".xbeam-infra")
+}
+
+func (o *stackOptions) backendURL() string {
+	if o.Backend != "" {
+		return o.Backend
+	}
+	return "file://" + filepath.ToSlash(filepath.Join(o.Home, "state"))
+}
+
+// selectStack creates or selects the stack with the workload as an inline program. The workspace directory
+// is kept in Home because it holds the stack config files.
+func selectStack(ctx context.Context, options *stackOptions) (auto.Stack, error) {
+	if options.Stack == "" {
+		return auto.Stack{}, errors.New("a stack name is required")
+	}
+	backend := options.backendURL()
+	if strings.HasPrefix(backend, "file://") {
+		_, passphrase := os.LookupEnv("PULUMI_CONFIG_PASSPHRASE")
+		_, passphraseFile := os.LookupEnv("PULUMI_CONFIG_PASSPHRASE_FILE")
+		if !passphrase && !passphraseFile {
+			return auto.Stack{}, errors.New("the file backend encrypts secrets with a passphrase, set PULUMI_CONFIG_PASSPHRASE or PULUMI_CONFIG_PASSPHRASE_FILE")
+		}
+		if err := os.MkdirAll(strings.TrimPrefix(backend, "file://"), 0700); err != nil {
+			return auto.Stack{}, err
+		}
+	}
+	workDir := filepath.Join(options.Home, "project")
+	if err := os.MkdirAll(workDir, 0700); err != nil {
+		return auto.Stack{}, err
+	}
+	project := workspace.Project{
+		Name:    program.PROJECT_NAME,
+		Runtime: workspace.NewProjectRuntimeInfo("go", nil),
+		Backend: &workspace.ProjectBackend{URL: backend},
+	}
+	return auto.UpsertStackInlineSource(ctx, options.Stack, program.PROJECT_NAME, program.Run,
+		auto.Project(project),
+		auto.WorkDir(workDir),
+	)
+}
+
+// loadConfigFile reads the config section of a Pulumi.<stack>.yaml file. Lists and maps are stored as JSON,
+// the way the program parses them.
+func loadConfigFile(path string) (auto.ConfigMap, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Config map[string]interface{} `yaml:"config"`
+	}
+	if err := yaml.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	config := auto.ConfigMap{}
+	for key, value := range file.Config {
+		if secure, ok := value.(map[string]interface{}); ok && len(secure) == 1 && secure["secure"] != nil {
+			return nil, fmt.Errorf("%s is encrypted for another stack, set it with xbeam-infra config set --secret", key)
+		}
+		configValue, err := configString(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of %s: %w", key, err)
+		}
+		config[key] = auto.ConfigValue{
+			Value:  configValue,
+			Secret: isSecretConfigKey(key),
+		}
+	}
+	return config, nil
+}
+
+func configString(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []interface{}, map[string]interface{}:
+		encoded, err := json.Marshal(v)
+		return string(encoded), err
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+func isSecretConfigKey(key string) bool {
+	for _, secret := range secretConfigKeys {
+		if key == secret {
+			return true
+		}
+	}
+	return false
+}
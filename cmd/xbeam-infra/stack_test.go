@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+)
+
+func TestLoadConfigFile(t *testing.T) {
+	config, err := loadConfigFile(filepath.Join("..", "..", "Pulumi.dev.example.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]auto.ConfigValue{
+		"aws:region":             {Value: "us-east-1"},
+		"worker:linuxMinSize":    {Value: "1"},
+		"eks:createKmsKey":       {Value: "false"},
+		"eks:logTypes":           {Value: `["api","audit","authenticator"]`},
+		"worker:windowsPassword": {Value: "<windows-password>", Secret: true},
+	}
+	for key, value := range expected {
+		if config[key] != value {
+			t.Errorf("%s: expected %+v, got %+v", key, value, config[key])
+		}
+	}
+}
+
+func TestLoadConfigFileRejectsEncryptedValues(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Pulumi.prod.yaml")
+	err := os.WriteFile(path, []byte("config:\n  worker:windowsPassword:\n    secure: v1:abc\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfigFile(path); err == nil {
+		t.Error("values encrypted for another stack must be rejected")
+	}
+}
+
+func TestOutputValuesMasksSecrets(t *testing.T) {
+	outputs := auto.OutputMap{
+		"Kubeconfig":     {Value: "apiVersion: v1", Secret: true},
+		"LinuxNodeGroup": {Value: "workload-linux"},
+	}
+	values := outputValues(outputs, false)
+	if values["Kubeconfig"] != "[secret]" || values["LinuxNodeGroup"] != "workload-linux" {
+		t.Errorf("unexpected outputs %v", values)
+	}
+	if outputValues(outputs, true)["Kubeconfig"] != "apiVersion: v1" {
+		t.Error("--show-secrets must print secret outputs")
+	}
+}
+
+// TestFileBackendStack needs the Pulumi CLI but no cloud login, the stack is never deployed.
+func TestFileBackendStack(t *testing.T) {
+	if _, err := exec.LookPath("pulumi"); err != nil {
+		t.Skip("the Pulumi CLI is not installed")
+	}
+	t.Setenv("PULUMI_CONFIG_PASSPHRASE", "test")
+	ctx := context.Background()
+	options := &stackOptions{Stack: "test", Home: t.TempDir()}
+	stack, err := selectStack(ctx, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := importConfig(ctx, stack, filepath.Join("..", "..", "Pulumi.dev.example.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	password, err := stack.GetConfig(ctx, "worker:windowsPassword")
+	if err != nil || !password.Secret {
+		t.Errorf("worker:windowsPassword must be stored as a secret, got %+v, %v", password, err)
+	}
+	outputs, err := stack.Outputs(ctx)
+	if err != nil || len(outputs) != 0 {
+		t.Errorf("a new stack has no outputs, got %v, %v", outputs, err)
+	}
+}
@@ -0,0 +1,73 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource/plugin"
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type analyzer struct {
+	pulumirpc.UnimplementedAnalyzerServer
+}
+
+// NewAnalyzer returns the analyzer server of the policy pack. Every policy is mandatory and checks resources one at
+// a time; stack wide analysis is left unimplemented, which the engine accepts.
+func NewAnalyzer() pulumirpc.AnalyzerServer {
+	return &analyzer{}
+}
+
+func (a *analyzer) Analyze(ctx context.Context, req *pulumirpc.AnalyzeRequest) (*pulumirpc.AnalyzeResponse, error) {
+	properties, err := plugin.UnmarshalProperties(req.GetProperties(), plugin.MarshalOptions{
+		KeepUnknowns: true,
+		KeepSecrets:  true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading the properties of %s: %w", req.GetUrn(), err)
+	}
+	r := Resource{Type: req.GetType(), Name: req.GetName(), Urn: req.GetUrn(), Properties: properties}
+	diagnostics := []*pulumirpc.AnalyzeDiagnostic{}
+	for _, policy := range Policies {
+		for _, message := range policy.Validate(r) {
+			diagnostics = append(diagnostics, &pulumirpc.AnalyzeDiagnostic{
+				PolicyName:        policy.Name,
+				PolicyPackName:    POLICY_PACK_NAME,
+				PolicyPackVersion: Version,
+				Description:       policy.Description,
+				Message:           message,
+				EnforcementLevel:  pulumirpc.EnforcementLevel_MANDATORY,
+				Urn:               req.GetUrn(),
+			})
+		}
+	}
+	return &pulumirpc.AnalyzeResponse{Diagnostics: diagnostics}, nil
+}
+
+func (a *analyzer) GetAnalyzerInfo(ctx context.Context, req *emptypb.Empty) (*pulumirpc.AnalyzerInfo, error) {
+	policies := []*pulumirpc.PolicyInfo{}
+	for _, policy := range Policies {
+		policies = append(policies, &pulumirpc.PolicyInfo{
+			Name:             policy.Name,
+			DisplayName:      policy.Name,
+			Description:      policy.Description,
+			EnforcementLevel: pulumirpc.EnforcementLevel_MANDATORY,
+		})
+	}
+	return &pulumirpc.AnalyzerInfo{
+		Name:        POLICY_PACK_NAME,
+		DisplayName: "xbeam security baseline",
+		Version:     Version,
+		Policies:    policies,
+	}, nil
+}
+
+func (a *analyzer) GetPluginInfo(ctx context.Context, req *emptypb.Empty) (*pulumirpc.PluginInfo, error) {
+	return &pulumirpc.PluginInfo{Version: Version}, nil
+}
+
+// Configure accepts any configuration, the policies have no settings.
+func (a *analyzer) Configure(ctx context.Context, req *pulumirpc.ConfigureAnalyzerRequest) (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, nil
+}
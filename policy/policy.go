@@ -0,0 +1,380 @@
+// Package policy is the security baseline of the workload as a Pulumi policy pack. The policies run in the
+// pulumi-analyzer-policy-xbeam plugin during previews and updates, and against the mocks in the program tests.
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+)
+
+const POLICY_PACK_NAME = "xbeam-baseline"
+
+// Version is set at build time with -ldflags "-X infra/policy.Version=..."
+var Version = "0.1.0"
+
+// Ports that must never be open to the internet
+var adminPorts = []int{22, 3389}
+
+// Tags every taggable resource carries, the workload sets them from its Deployment
+var RequiredTags = []string{"Project", "Stack"}
+
+// AWS resource types of the workload that support tags
+var taggableTypes = []string{
+	"aws:cloudwatch/logGroup:LogGroup",
+	"aws:ec2/eip:Eip",
+	"aws:ec2/instance:Instance",
+	"aws:ec2/internetGateway:InternetGateway",
+	"aws:ec2/launchTemplate:LaunchTemplate",
+	"aws:ec2/natGateway:NatGateway",
+	"aws:ec2/routeTable:RouteTable",
+	"aws:ec2/securityGroup:SecurityGroup",
+	"aws:ec2/subnet:Subnet",
+	"aws:ec2/volume:Volume",
+	"aws:ec2/vpc:Vpc",
+	"aws:eks/addon:Addon",
+	"aws:eks/cluster:Cluster",
+	"aws:eks/nodeGroup:NodeGroup",
+	"aws:iam/instanceProfile:InstanceProfile",
+	"aws:iam/policy:Policy",
+	"aws:iam/role:Role",
+	"aws:kinesis/firehoseDeliveryStream:FirehoseDeliveryStream",
+	"aws:kms/key:Key",
+}
+
+// IAM identity policies; key and bucket policies are resource policies, where "*" is the resource itself
+var identityPolicyTypes = []string{
+	"aws:iam/groupPolicy:GroupPolicy",
+	"aws:iam/policy:Policy",
+	"aws:iam/rolePolicy:RolePolicy",
+	"aws:iam/userPolicy:UserPolicy",
+}
+
+// Action prefixes that only read
+var readOnlyActionPrefixes = []string{"Describe", "Get", "List"}
+
+// Resource is what a policy sees of a resource: its type, name and input properties.
+type Resource struct {
+	Type       string
+	Name       string
+	Urn        string
+	Properties resource.PropertyMap
+}
+
+type Policy struct {
+	Name        string
+	Description string
+	// Validate returns one message per violation
+	Validate func(r Resource) []string
+}
+
+type Violation struct {
+	Policy   string
+	Resource string
+	Message  string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s: %s", v.Policy, v.Resource, v.Message)
+}
+
+// Policies are all mandatory, a violation stops the update.
+var Policies = []Policy{
+	{
+		Name:        "no-public-admin-ingress",
+		Description: "Security groups do not allow SSH (22) or RDP (3389) from 0.0.0.0/0 or ::/0.",
+		Validate:    validateIngress,
+	},
+	{
+		Name:        "ebs-encrypted",
+		Description: "Every EBS volume is encrypted.",
+		Validate:    validateEbsEncryption,
+	},
+	{
+		Name:        "imdsv2-required",
+		Description: "Launch templates and instances require IMDSv2 session tokens.",
+		Validate:    validateImdsV2,
+	},
+	{
+		Name:        "no-wildcard-mutating-iam",
+		Description: "IAM policies do not allow actions other than Describe, Get or List on Resource \"*\".",
+		Validate:    validateIamResources,
+	},
+	{
+		Name:        "required-tags",
+		Description: "Taggable resources carry the " + strings.Join(RequiredTags, " and ") + " tags.",
+		Validate:    validateTags,
+	},
+}
+
+// Validate runs every policy against the resource.
+func Validate(r Resource) []Violation {
+	violations := []Violation{}
+	for _, policy := range Policies {
+		for _, message := range policy.Validate(r) {
+			violations = append(violations, Violation{Policy: policy.Name, Resource: r.Name, Message: message})
+		}
+	}
+	return violations
+}
+
+// CheckKubeconfigFile checks that only the owner can read the kubeconfig file. The file is written by xbeam-infra,
+// not by a resource, so the analyzer cannot see it; the tests of the command check the file instead.
+func CheckKubeconfigFile(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if mode := info.Mode().Perm(); mode&0077 != 0 {
+		return fmt.Errorf("%s has mode %04o, the kubeconfig must only be readable by its owner", path, mode)
+	}
+	return nil
+}
+
+// plain unwraps secrets, unknown values are returned as nulls and never violate a policy.
+func plain(value resource.PropertyValue) resource.PropertyValue {
+	for value.IsSecret() {
+		value = value.SecretValue().Element
+	}
+	if value.IsComputed() || value.IsOutput() {
+		return resource.NewNullProperty()
+	}
+	return value
+}
+
+func property(object resource.PropertyMap, key string) resource.PropertyValue {
+	return plain(object[resource.PropertyKey(key)])
+}
+
+func objects(value resource.PropertyValue) []resource.PropertyMap {
+	found := []resource.PropertyMap{}
+	if value.IsObject() {
+		return append(found, value.ObjectValue())
+	}
+	if value.IsArray() {
+		for _, item := range value.ArrayValue() {
+			if item = plain(item); item.IsObject() {
+				found = append(found, item.ObjectValue())
+			}
+		}
+	}
+	return found
+}
+
+func stringList(value resource.PropertyValue) []string {
+	found := []string{}
+	if value.IsString() {
+		return append(found, value.StringValue())
+	}
+	if value.IsArray() {
+		for _, item := range value.ArrayValue() {
+			if item = plain(item); item.IsString() {
+				found = append(found, item.StringValue())
+			}
+		}
+	}
+	return found
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// opensAdminPort reports the admin port an ingress from the internet covers, or 0.
+func opensAdminPort(protocol string, fromPort, toPort resource.PropertyValue, cidrs []string) int {
+	if !contains(cidrs, "0.0.0.0/0") && !contains(cidrs, "::/0") {
+		return 0
+	}
+	switch strings.ToLower(protocol) {
+	case "-1", "all":
+		return adminPorts[0]
+	case "tcp", "6":
+	default:
+		return 0
+	}
+	if !fromPort.IsNumber() || !toPort.IsNumber() {
+		return 0
+	}
+	for _, port := range adminPorts {
+		if float64(port) >= fromPort.NumberValue() && float64(port) <= toPort.NumberValue() {
+			return port
+		}
+	}
+	return 0
+}
+
+func validateIngress(r Resource) []string {
+	messages := []string{}
+	check := func(protocol string, fromPort, toPort resource.PropertyValue, cidrs []string) {
+		if port := opensAdminPort(protocol, fromPort, toPort, cidrs); port != 0 {
+			messages = append(messages, fmt.Sprintf("port %d is open to %s", port, strings.Join(cidrs, ", ")))
+		}
+	}
+	p := r.Properties
+	switch r.Type {
+	case "aws:ec2/securityGroup:SecurityGroup":
+		for _, ingress := range objects(property(p, "ingress")) {
+			cidrs := append(stringList(property(ingress, "cidrBlocks")), stringList(property(ingress, "ipv6CidrBlocks"))...)
+			check(stringOf(property(ingress, "protocol")), property(ingress, "fromPort"), property(ingress, "toPort"), cidrs)
+		}
+	case "aws:ec2/securityGroupRule:SecurityGroupRule":
+		if stringOf(property(p, "type")) == "ingress" {
+			cidrs := append(stringList(property(p, "cidrBlocks")), stringList(property(p, "ipv6CidrBlocks"))...)
+			check(stringOf(property(p, "protocol")), property(p, "fromPort"), property(p, "toPort"), cidrs)
+		}
+	case "aws:vpc/securityGroupIngressRule:SecurityGroupIngressRule":
+		cidrs := append(stringList(property(p, "cidrIpv4")), stringList(property(p, "cidrIpv6"))...)
+		check(stringOf(property(p, "ipProtocol")), property(p, "fromPort"), property(p, "toPort"), cidrs)
+	}
+	return messages
+}
+
+// stringOf returns string values as is, numbers as integers and anything else as "". Protocols may be given
+// either as names or numbers.
+func stringOf(value resource.PropertyValue) string {
+	switch {
+	case value.IsString():
+		return value.StringValue()
+	case value.IsNumber():
+		return fmt.Sprint(int(value.NumberValue()))
+	}
+	return ""
+}
+
+// encrypted is false only for a known value that does not enable encryption, launch templates use "true" strings.
+func encrypted(value resource.PropertyValue) bool {
+	switch {
+	case value.IsBool():
+		return value.BoolValue()
+	case value.IsString():
+		return value.StringValue() == "true"
+	}
+	return false
+}
+
+func validateEbsEncryption(r Resource) []string {
+	messages := []string{}
+	p := r.Properties
+	switch r.Type {
+	case "aws:ec2/launchTemplate:LaunchTemplate":
+		for _, mapping := range objects(property(p, "blockDeviceMappings")) {
+			for _, ebs := range objects(property(mapping, "ebs")) {
+				if !encrypted(property(ebs, "encrypted")) {
+					messages = append(messages, fmt.Sprintf("the EBS volume of %s is not encrypted", stringOf(property(mapping, "deviceName"))))
+				}
+			}
+		}
+	case "aws:ec2/instance:Instance":
+		root := objects(property(p, "rootBlockDevice"))
+		if len(root) == 0 || !encrypted(property(root[0], "encrypted")) {
+			messages = append(messages, "the root volume is not encrypted")
+		}
+		for _, device := range objects(property(p, "ebsBlockDevices")) {
+			if !encrypted(property(device, "encrypted")) {
+				messages = append(messages, fmt.Sprintf("the EBS volume of %s is not encrypted", stringOf(property(device, "deviceName"))))
+			}
+		}
+	case "aws:ec2/volume:Volume":
+		if !encrypted(property(p, "encrypted")) {
+			messages = append(messages, "the volume is not encrypted")
+		}
+	}
+	return messages
+}
+
+func validateImdsV2(r Resource) []string {
+	if r.Type != "aws:ec2/launchTemplate:LaunchTemplate" && r.Type != "aws:ec2/instance:Instance" {
+		return nil
+	}
+	options := objects(property(r.Properties, "metadataOptions"))
+	if len(options) == 0 || stringOf(property(options[0], "httpTokens")) != "required" {
+		return []string{"metadataOptions.httpTokens must be required"}
+	}
+	return nil
+}
+
+func readOnlyAction(action string) bool {
+	name := action[strings.Index(action, ":")+1:]
+	for _, prefix := range readOnlyActionPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// listOf reads an IAM policy element that is either a string or a list of strings.
+func listOf(element interface{}) []string {
+	switch v := element.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		list := []string{}
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+func validateIamResources(r Resource) []string {
+	if !contains(identityPolicyTypes, r.Type) {
+		return nil
+	}
+	document := property(r.Properties, "policy")
+	if !document.IsString() {
+		return nil
+	}
+	var policy struct {
+		Statement interface{} `json:"Statement"`
+	}
+	if err := json.Unmarshal([]byte(document.StringValue()), &policy); err != nil {
+		return []string{fmt.Sprintf("the policy is not valid JSON: %v", err)}
+	}
+	statements, ok := policy.Statement.([]interface{})
+	if !ok {
+		statements = []interface{}{policy.Statement}
+	}
+	messages := []string{}
+	for _, raw := range statements {
+		statement, ok := raw.(map[string]interface{})
+		if !ok || statement["Effect"] != "Allow" || !contains(listOf(statement["Resource"]), "*") {
+			continue
+		}
+		for _, action := range listOf(statement["Action"]) {
+			if !readOnlyAction(action) {
+				messages = append(messages, fmt.Sprintf("%s is allowed on every resource", action))
+			}
+		}
+	}
+	return messages
+}
+
+func validateTags(r Resource) []string {
+	if !contains(taggableTypes, r.Type) {
+		return nil
+	}
+	tags := property(r.Properties, "tags")
+	if tags.IsNull() && r.Properties.HasValue("tags") {
+		// The tags are not known yet
+		return nil
+	}
+	messages := []string{}
+	for _, key := range RequiredTags {
+		if !tags.IsObject() || !tags.ObjectValue().HasValue(resource.PropertyKey(key)) {
+			messages = append(messages, fmt.Sprintf("the %s tag is missing", key))
+		}
+	}
+	return messages
+}
@@ -0,0 +1,195 @@
+package policy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource/plugin"
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+)
+
+func newResource(typ string, properties map[string]interface{}) Resource {
+	return Resource{Type: typ, Name: "test", Properties: resource.NewPropertyMapFromMap(properties)}
+}
+
+// policyNames returns the policies the resource violates.
+func policyNames(r Resource) []string {
+	names := []string{}
+	for _, violation := range Validate(r) {
+		names = append(names, violation.Policy)
+	}
+	return names
+}
+
+var tags = map[string]interface{}{"Project": "infra", "Stack": "dev"}
+
+func TestValidateIngress(t *testing.T) {
+	tests := []struct {
+		name     string
+		resource Resource
+		want     string
+	}{
+		{"rdp from anywhere", newResource("aws:ec2/securityGroup:SecurityGroup", map[string]interface{}{
+			"tags": tags,
+			"ingress": []interface{}{
+				map[string]interface{}{"protocol": "tcp", "fromPort": 3478, "toPort": 3478, "cidrBlocks": []interface{}{"0.0.0.0/0"}},
+				map[string]interface{}{"protocol": "tcp", "fromPort": 3389, "toPort": 3389, "ipv6CidrBlocks": []interface{}{"::/0"}},
+			},
+		}), "port 3389 is open to ::/0"},
+		{"ssh in a port range", newResource("aws:ec2/securityGroupRule:SecurityGroupRule", map[string]interface{}{
+			"type": "ingress", "protocol": "6", "fromPort": 0, "toPort": 1024, "cidrBlocks": []interface{}{"0.0.0.0/0"},
+		}), "port 22 is open to 0.0.0.0/0"},
+		{"all traffic", newResource("aws:vpc/securityGroupIngressRule:SecurityGroupIngressRule", map[string]interface{}{
+			"ipProtocol": "-1", "cidrIpv4": "0.0.0.0/0",
+		}), "port 22 is open to 0.0.0.0/0"},
+		{"ssh from the vpc", newResource("aws:ec2/securityGroupRule:SecurityGroupRule", map[string]interface{}{
+			"type": "ingress", "protocol": "tcp", "fromPort": 22, "toPort": 22, "cidrBlocks": []interface{}{"10.0.0.0/16"},
+		}), ""},
+		{"egress", newResource("aws:ec2/securityGroupRule:SecurityGroupRule", map[string]interface{}{
+			"type": "egress", "protocol": "-1", "fromPort": 0, "toPort": 0, "cidrBlocks": []interface{}{"0.0.0.0/0"},
+		}), ""},
+	}
+	for _, test := range tests {
+		messages := validateIngress(test.resource)
+		if got := strings.Join(messages, "; "); got != test.want {
+			t.Errorf("%s: expected %q, got %q", test.name, test.want, got)
+		}
+	}
+}
+
+func TestValidateLaunchTemplate(t *testing.T) {
+	template := newResource("aws:ec2/launchTemplate:LaunchTemplate", map[string]interface{}{
+		"tags": tags,
+		"blockDeviceMappings": []interface{}{
+			map[string]interface{}{"deviceName": "/dev/xvda", "ebs": map[string]interface{}{"encrypted": "true"}},
+		},
+		"metadataOptions": map[string]interface{}{"httpTokens": "required"},
+	})
+	if names := policyNames(template); len(names) != 0 {
+		t.Errorf("expected no violations, got %v", names)
+	}
+	template.Properties["blockDeviceMappings"] = resource.NewPropertyValue([]interface{}{
+		map[string]interface{}{"deviceName": "/dev/sda1", "ebs": map[string]interface{}{"volumeSize": 100}},
+	})
+	template.Properties["metadataOptions"] = resource.NewPropertyValue(map[string]interface{}{"httpTokens": "optional"})
+	if names := strings.Join(policyNames(template), ","); names != "ebs-encrypted,imdsv2-required" {
+		t.Errorf("expected the volume and the metadata options to fail, got %s", names)
+	}
+	delete(template.Properties, "metadataOptions")
+	if messages := validateImdsV2(template); len(messages) != 1 {
+		t.Errorf("expected missing metadata options to fail, got %v", messages)
+	}
+}
+
+func TestValidateInstance(t *testing.T) {
+	instance := newResource("aws:ec2/instance:Instance", map[string]interface{}{
+		"tags":            tags,
+		"rootBlockDevice": map[string]interface{}{"encrypted": true},
+		"metadataOptions": map[string]interface{}{"httpTokens": "required"},
+	})
+	if names := policyNames(instance); len(names) != 0 {
+		t.Errorf("expected no violations, got %v", names)
+	}
+	delete(instance.Properties, "rootBlockDevice")
+	if messages := validateEbsEncryption(instance); strings.Join(messages, "") != "the root volume is not encrypted" {
+		t.Errorf("expected the default root volume to fail, got %v", messages)
+	}
+}
+
+func TestValidateIamResources(t *testing.T) {
+	tests := []struct {
+		policy string
+		want   int
+	}{
+		{`{"Statement":[{"Effect":"Allow","Action":["ec2:DescribeImages","eks:ListClusters"],"Resource":"*"}]}`, 0},
+		{`{"Statement":[{"Effect":"Allow","Action":"s3:PutObject","Resource":["arn:aws:s3:::bucket/*"]}]}`, 0},
+		{`{"Statement":{"Effect":"Allow","Action":["ec2:DescribeImages","ec2:TerminateInstances"],"Resource":"*"}}`, 1},
+		{`{"Statement":[{"Effect":"Deny","Action":"*","Resource":"*"},{"Effect":"Allow","Action":"*","Resource":"*"}]}`, 1},
+	}
+	for _, test := range tests {
+		r := newResource("aws:iam/rolePolicy:RolePolicy", map[string]interface{}{"policy": test.policy})
+		if messages := validateIamResources(r); len(messages) != test.want {
+			t.Errorf("%s: expected %d violations, got %v", test.policy, test.want, messages)
+		}
+	}
+	// Key policies name the key itself as "*"
+	key := newResource("aws:kms/key:Key", map[string]interface{}{
+		"tags":   tags,
+		"policy": `{"Statement":[{"Effect":"Allow","Action":"kms:*","Resource":"*"}]}`,
+	})
+	if names := policyNames(key); len(names) != 0 {
+		t.Errorf("expected key policies to be ignored, got %v", names)
+	}
+}
+
+func TestValidateTags(t *testing.T) {
+	role := newResource("aws:iam/role:Role", map[string]interface{}{"tags": map[string]interface{}{"Project": "infra"}})
+	if messages := validateTags(role); strings.Join(messages, "") != "the Stack tag is missing" {
+		t.Errorf("unexpected messages %v", messages)
+	}
+	delete(role.Properties, "tags")
+	if messages := validateTags(role); len(messages) != 2 {
+		t.Errorf("expected both tags to be missing, got %v", messages)
+	}
+	rule := newResource("aws:ec2/securityGroupRule:SecurityGroupRule", nil)
+	if messages := validateTags(rule); len(messages) != 0 {
+		t.Errorf("security group rules have no tags, got %v", messages)
+	}
+}
+
+func TestUnknownAndSecretValues(t *testing.T) {
+	template := newResource("aws:ec2/launchTemplate:LaunchTemplate", map[string]interface{}{
+		"metadataOptions": map[string]interface{}{"httpTokens": "required"},
+	})
+	template.Properties["tags"] = resource.MakeComputed(resource.NewStringProperty(""))
+	template.Properties["blockDeviceMappings"] = resource.MakeSecret(resource.NewPropertyValue([]interface{}{
+		map[string]interface{}{"deviceName": "/dev/xvda", "ebs": map[string]interface{}{"encrypted": "false"}},
+	}))
+	if names := strings.Join(policyNames(template), ","); names != "ebs-encrypted" {
+		t.Errorf("expected unknown tags to pass and the secret mapping to be checked, got %s", names)
+	}
+}
+
+func TestCheckKubeconfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := os.WriteFile(path, []byte("apiVersion: v1"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckKubeconfigFile(path); err != nil {
+		t.Error(err)
+	}
+	if err := os.Chmod(path, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckKubeconfigFile(path); err == nil {
+		t.Error("expected a world-readable kubeconfig to fail")
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	properties, err := plugin.MarshalProperties(resource.NewPropertyMapFromMap(map[string]interface{}{
+		"policy": `{"Statement":[{"Effect":"Allow","Action":"iam:PassRole","Resource":"*"}]}`,
+		"tags":   tags,
+	}), plugin.MarshalOptions{KeepUnknowns: true, KeepSecrets: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := NewAnalyzer().Analyze(context.Background(), &pulumirpc.AnalyzeRequest{
+		Type: "aws:iam/policy:Policy", Name: "test", Urn: "urn:pulumi:dev::infra::aws:iam/policy:Policy::test", Properties: properties,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Diagnostics) != 1 {
+		t.Fatalf("expected one diagnostic, got %v", response.Diagnostics)
+	}
+	diagnostic := response.Diagnostics[0]
+	if diagnostic.PolicyName != "no-wildcard-mutating-iam" || diagnostic.EnforcementLevel != pulumirpc.EnforcementLevel_MANDATORY ||
+		diagnostic.PolicyPackName != POLICY_PACK_NAME {
+		t.Errorf("unexpected diagnostic %v", diagnostic)
+	}
+}
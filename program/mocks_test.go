@@ -0,0 +1,229 @@
+package program
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
+	awseks "github.com/pulumi/pulumi-aws/sdk/v6/go/aws/eks"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/tokens"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+
+	"infra/workload"
+)
+
+const (
+	TEST_PROJECT         = "infra"
+	TEST_STACK           = "dev"
+	TEST_ACCOUNT         = "123456789012"
+	TEST_CLUSTER_NAME    = "dev-eks"
+	TEST_ENDPOINT        = "https://ABCDEF.gr7.us-east-1.eks.amazonaws.com"
+	TEST_CA_DATA         = "Q0VSVElGSUNBVEU="
+	TEST_DNS_IP          = "172.20.0.10"
+	TEST_AMI             = "ami-0123456789abcdef0"
+	TEST_OLD_AMI         = "ami-0fedcba9876543210"
+	TEST_RELEASE_VERSION = "1.29.3-20240531"
+	FIXTURE_PREFIX       = "fixture-"
+)
+
+// The eks:index:Cluster component is implemented by the pulumi-eks plugin. Its mock returns references to fixture
+// resources that registerFixtures creates before the program runs.
+var fixtureTypes = map[string]string{
+	"eksCluster":           "aws:eks/cluster:Cluster",
+	"nodeSecurityGroup":    "aws:ec2/securityGroup:SecurityGroup",
+	"clusterSecurityGroup": "aws:ec2/securityGroup:SecurityGroup",
+	"oidcProvider":         "aws:iam/openIdConnectProvider:OpenIdConnectProvider",
+}
+
+func fixtureName(output string) string {
+	return FIXTURE_PREFIX + output
+}
+
+func fixtureId(output string) string {
+	return fixtureName(output) + "_id"
+}
+
+func fixtureReference(output string) resource.PropertyValue {
+	urn := resource.NewURN(tokens.QName(TEST_STACK), tokens.PackageName(TEST_PROJECT), "", tokens.Type(fixtureTypes[output]), fixtureName(output))
+	return resource.MakeCustomResourceReference(urn, resource.ID(fixtureId(output)), "")
+}
+
+func registerFixtures(ctx *pulumi.Context) error {
+	_, err := awseks.NewCluster(ctx, fixtureName("eksCluster"), &awseks.ClusterArgs{
+		Name:      pulumi.String(TEST_CLUSTER_NAME),
+		RoleArn:   pulumi.String("arn:aws:iam::" + TEST_ACCOUNT + ":role/fixture"),
+		VpcConfig: &awseks.ClusterVpcConfigArgs{SubnetIds: pulumi.StringArray{}},
+	})
+	if err != nil {
+		return err
+	}
+	for _, output := range []string{"nodeSecurityGroup", "clusterSecurityGroup"} {
+		if _, err := ec2.NewSecurityGroup(ctx, fixtureName(output), &ec2.SecurityGroupArgs{}); err != nil {
+			return err
+		}
+	}
+	_, err = iam.NewOpenIdConnectProvider(ctx, fixtureName("oidcProvider"), &iam.OpenIdConnectProviderArgs{
+		Url:             pulumi.String("https://oidc.eks.us-east-1.amazonaws.com/id/ABCDEF"),
+		ClientIdLists:   pulumi.StringArray{pulumi.String("sts.amazonaws.com")},
+		ThumbprintLists: pulumi.StringArray{},
+	})
+	return err
+}
+
+// mocks records every resource and echoes the inputs back as outputs, with the computed outputs the program reads.
+// With unknown set every output stays unknown, so in a preview no ApplyT callback runs, except previewKnown.
+type mocks struct {
+	lock      sync.Mutex
+	resources []pulumi.MockResourceArgs
+	unknown   bool
+	// clusterVersion is the Kubernetes version of the running cluster, empty when there is none yet
+	clusterVersion string
+}
+
+// previewKnown are the outputs that stay known with unknown set, the ones the Windows user data is rendered from.
+// The user data is a secret and the mock monitor cannot unmarshal an unknown secret input, which the engine can.
+var previewKnown = map[string][]string{
+	"eks:index:Cluster":          {"eksCluster"},
+	"aws:eks/cluster:Cluster":    {"name", "endpoint", "certificateAuthority"},
+	"kubernetes:core/v1:Service": {"spec"},
+}
+
+func (m *mocks) NewResource(args pulumi.MockResourceArgs) (string, resource.PropertyMap, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.resources = append(m.resources, args)
+	id := args.Name + "_id"
+	if args.ID != "" {
+		id = args.ID
+	}
+	outputs := args.Inputs.Copy()
+	switch args.TypeToken {
+	case "eks:index:Cluster":
+		outputs = resource.NewPropertyMapFromMap(map[string]interface{}{
+			"kubeconfig": map[string]interface{}{
+				"apiVersion": "v1",
+				"clusters": []interface{}{map[string]interface{}{
+					"name":    "kubernetes",
+					"cluster": map[string]interface{}{"server": TEST_ENDPOINT, "certificate-authority-data": TEST_CA_DATA},
+				}},
+			},
+		})
+		for output := range fixtureTypes {
+			if output != "oidcProvider" {
+				outputs[resource.PropertyKey(output)] = fixtureReference(output)
+			}
+		}
+		outputs["core"] = resource.NewObjectProperty(resource.PropertyMap{
+			"oidcProvider":         fixtureReference("oidcProvider"),
+			"clusterSecurityGroup": fixtureReference("clusterSecurityGroup"),
+		})
+	case "aws:eks/cluster:Cluster":
+		outputs["endpoint"] = resource.NewStringProperty(TEST_ENDPOINT)
+		outputs["certificateAuthority"] = resource.NewObjectProperty(resource.PropertyMap{"data": resource.NewStringProperty(TEST_CA_DATA)})
+		outputs["vpcConfig"] = resource.NewObjectProperty(resource.PropertyMap{"clusterSecurityGroupId": resource.NewStringProperty(fixtureId("clusterSecurityGroup"))})
+	case "kubernetes:core/v1:Service":
+		outputs["spec"] = resource.NewObjectProperty(resource.PropertyMap{"clusterIP": resource.NewStringProperty(TEST_DNS_IP)})
+	}
+	if _, ok := outputs["arn"]; !ok && args.Custom {
+		outputs["arn"] = resource.NewStringProperty(fmt.Sprintf("arn:aws:mock::%s:%s", TEST_ACCOUNT, args.Name))
+	}
+	if m.unknown {
+		known := resource.PropertyMap{}
+		for _, key := range previewKnown[args.TypeToken] {
+			if value, ok := outputs[resource.PropertyKey(key)]; ok {
+				known[resource.PropertyKey(key)] = value
+			}
+		}
+		return args.ID, known, nil
+	}
+	return id, outputs, nil
+}
+
+func (m *mocks) Call(args pulumi.MockCallArgs) (resource.PropertyMap, error) {
+	switch args.Token {
+	case "aws:index/getPartition:getPartition":
+		return resource.NewPropertyMapFromMap(map[string]interface{}{"id": "aws", "partition": "aws", "dnsSuffix": "amazonaws.com"}), nil
+	case "aws:ec2/getAmi:getAmi":
+		// The latest Windows image and the one of the default Kubernetes version are TEST_AMI, the one of any other
+		// version TEST_OLD_AMI
+		ami := TEST_AMI
+		for _, filter := range args.Args["filters"].ArrayValue() {
+			filter := filter.ObjectValue()
+			if filter["name"].StringValue() == "name" && !strings.Contains(filter["values"].ArrayValue()[0].StringValue(), "-"+workload.K8S_VERSION+"-") {
+				ami = TEST_OLD_AMI
+			}
+		}
+		return resource.NewPropertyMapFromMap(map[string]interface{}{"id": ami, "imageId": ami}), nil
+	case "aws:eks/getCluster:getCluster":
+		if m.clusterVersion == "" {
+			return nil, errors.New("reading EKS Cluster: couldn't find resource")
+		}
+		return resource.NewPropertyMapFromMap(map[string]interface{}{"name": args.Args["name"].StringValue(), "version": m.clusterVersion}), nil
+	case "aws:ssm/getParameter:getParameter":
+		return resource.NewPropertyMapFromMap(map[string]interface{}{"name": args.Args["name"].StringValue(), "value": TEST_RELEASE_VERSION}), nil
+	}
+	return args.Args, nil
+}
+
+// byType returns the resources of the program, without the fixtures.
+func (m *mocks) byType(typeToken string) []pulumi.MockResourceArgs {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	found := []pulumi.MockResourceArgs{}
+	for _, r := range m.resources {
+		if r.TypeToken == typeToken && !strings.HasPrefix(r.Name, FIXTURE_PREFIX) {
+			found = append(found, r)
+		}
+	}
+	return found
+}
+
+// names returns the type and name of every resource of the program.
+func (m *mocks) names() map[string]bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	names := map[string]bool{}
+	for _, r := range m.resources {
+		if !strings.HasPrefix(r.Name, FIXTURE_PREFIX) {
+			names[r.TypeToken+"::"+r.Name] = true
+		}
+	}
+	return names
+}
+
+// urn returns the URN the engine gives a resource. It has the type of the parent but not its name, so two
+// children with the same name and type collide even under different components.
+func urn(r pulumi.MockResourceArgs) resource.URN {
+	parent := r.RegisterRPC.GetParent()
+	if r.ReadRPC != nil {
+		parent = r.ReadRPC.GetParent()
+	}
+	parentType := tokens.Type("")
+	if parentURN := resource.URN(parent); parentURN != "" && parentURN.QualifiedType() != resource.RootStackType {
+		parentType = parentURN.QualifiedType()
+	}
+	return resource.NewURN(tokens.QName(TEST_STACK), tokens.PackageName(TEST_PROJECT), parentType, tokens.Type(r.TypeToken), r.Name)
+}
+
+// duplicateURNs returns the URNs that more than one resource of the program registered.
+func (m *mocks) duplicateURNs() []string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	seen := map[resource.URN]int{}
+	for _, r := range m.resources {
+		seen[urn(r)]++
+	}
+	duplicates := []string{}
+	for urn, count := range seen {
+		if count > 1 {
+			duplicates = append(duplicates, string(urn))
+		}
+	}
+	sort.Strings(duplicates)
+	return duplicates
+}
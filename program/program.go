@@ -0,0 +1,404 @@
+// Package program is the XBeam workload Pulumi program, shared by the pulumi CLI entry point and xbeam-infra.
+package program
+
+import (
+	"errors"
+	"fmt"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+	"infra/workload"
+	"strconv"
+	"strings"
+)
+
+func validate(regionOK bool, windowsInstanceTypeOK bool, linuxInstanceTypeOK bool, windowsAMIOK bool, adminOK bool, accOK bool, linuxDesiredCapacityOK bool, linuxMinSizeOK bool, linuxMaxSizeOK bool, windowsDesiredCapacityOK bool, windowsMinSizeOK bool, windowsMaxSizeOK bool, windowsPasswordOK bool) error {
+	if !regionOK {
+		return errors.New("region is required")
+	}
+	if !windowsInstanceTypeOK {
+		return errors.New("windowsInstance is required")
+	}
+	if !linuxInstanceTypeOK {
+		return errors.New("linuxInstance is required")
+	}
+	if !windowsAMIOK {
+		return errors.New("windowsAmi is required")
+	}
+	if !adminOK {
+		return errors.New("adminUsername is required")
+	}
+	if !accOK {
+		return errors.New("accountId is required")
+	}
+	if !linuxDesiredCapacityOK {
+		return errors.New("linuxDesiredCapacity is required")
+	}
+	if !linuxMinSizeOK {
+		return errors.New("linuxMinSize is required")
+	}
+	if !linuxMaxSizeOK {
+		return errors.New("linuxMaxSize is required")
+	}
+	if !windowsDesiredCapacityOK {
+		return errors.New("windowsDesiredCapacity is required")
+	}
+	if !windowsMinSizeOK {
+		return errors.New("windowsMinSize is required")
+	}
+	if !windowsMaxSizeOK {
+		return errors.New("windowsMaxSize is required")
+	}
+	if !windowsPasswordOK {
+		return errors.New("windowsPassword is required")
+	}
+	return nil
+}
+
+// SAMPLE_WINDOWS_PASSWORD is the Windows password the sample config used to ship with, so it is known to everyone.
+const SAMPLE_WINDOWS_PASSWORD = "Sup3rs3cret!!!"
+
+// validateSampleValues rejects the <placeholders> of Pulumi.dev.example.yaml and the old sample password, so the
+// sample is never deployed unchanged.
+func validateSampleValues(accountId string, adminUsername string, windowsPassword string) error {
+	for _, field := range []struct{ key, value string }{
+		{"eks:accountId", accountId},
+		{"eks:adminUsername", adminUsername},
+		{"worker:windowsPassword", windowsPassword},
+	} {
+		if strings.HasPrefix(field.value, "<") && strings.HasSuffix(field.value, ">") {
+			return fmt.Errorf("%s is still the placeholder %s of the sample config", field.key, field.value)
+		}
+	}
+	if windowsPassword == SAMPLE_WINDOWS_PASSWORD {
+		return errors.New("worker:windowsPassword is the published sample password, set your own")
+	}
+	return nil
+}
+
+// PROJECT_NAME is the Pulumi project of the program, as in Pulumi.yaml
+const PROJECT_NAME = "infra"
+
+// Run creates the workload from the stack config.
+func Run(ctx *pulumi.Context) error {
+	rg, regionOK := ctx.GetConfig("aws:region")
+	windowsInstanceType, windowsInstanceTypeOK := ctx.GetConfig("worker:windowsInstance")
+	linuxInstanceType, linuxInstanceTypeOK := ctx.GetConfig("worker:linuxInstance")
+	_, windowsAMIOK := ctx.GetConfig("worker:windowsAmi")
+	adminUsername, adminOK := ctx.GetConfig("eks:adminUsername")
+	accountId, accOK := ctx.GetConfig("eks:accountId")
+	linuxDesiredCapacity, linuxDesiredCapacityOK := ctx.GetConfig("worker:linuxDesiredCapacity")
+	linuxMinSize, linuxMinSizeOK := ctx.GetConfig("worker:linuxMinSize")
+	linuxMaxSize, linuxMaxSizeOK := ctx.GetConfig("worker:linuxMaxSize")
+
+	windowsDesiredCapacity, windowsDesiredCapacityOK := ctx.GetConfig("worker:windowsDesiredCapacity")
+	windowsMinSize, windowsMinSizeOK := ctx.GetConfig("worker:windowsMinSize")
+	windowsMaxSize, windowsMaxSizeOK := ctx.GetConfig("worker:windowsMaxSize")
+
+	plainWindowsPassword, windowsPasswordOK := ctx.GetConfig("worker:windowsPassword")
+	// The passwords are read as secrets, so they stay encrypted in the state and in every output derived from them
+	windowsPassword := config.GetSecret(ctx, "worker:windowsPassword")
+	err := validate(regionOK, windowsInstanceTypeOK, linuxInstanceTypeOK, windowsAMIOK, adminOK, accOK, linuxDesiredCapacityOK, linuxMinSizeOK, linuxMaxSizeOK, windowsDesiredCapacityOK, windowsMinSizeOK, windowsMaxSizeOK, windowsPasswordOK)
+	if err != nil {
+		return err
+	}
+	if err := validateSampleValues(accountId, adminUsername, plainWindowsPassword); err != nil {
+		return err
+	}
+	partition, err := workload.LookupPartition(ctx)
+	if err != nil {
+		return err
+	}
+	deployment := workload.NewDeployment(ctx.Stack(), rg, accountId, partition)
+	windowsDesiredCapacityInt, _ := strconv.Atoi(windowsDesiredCapacity)
+	windowsMinSizeInt, _ := strconv.Atoi(windowsMinSize)
+	windowsMaxSizeInt, _ := strconv.Atoi(windowsMaxSize)
+
+	linuxDesiredCapacityInt, _ := strconv.Atoi(linuxDesiredCapacity)
+	linuxMinSizeInt, _ := strconv.Atoi(linuxMinSize)
+	linuxMaxSizeInt, _ := strconv.Atoi(linuxMaxSize)
+
+	k8sVersion, k8sVersionOK := ctx.GetConfig("eks:version")
+	if !k8sVersionOK {
+		k8sVersion = workload.K8S_VERSION
+	}
+	maxUnavailableInt := 1
+	if maxUnavailable, ok := ctx.GetConfig("worker:maxUnavailable"); ok {
+		maxUnavailableInt, err = strconv.Atoi(maxUnavailable)
+		if err != nil || maxUnavailableInt < 1 {
+			return errors.New("worker:maxUnavailable must be a positive integer")
+		}
+	}
+	// worker:windowsAmiNamePattern picks the Windows image of the Kubernetes version, the latest image otherwise
+	windowsAmiNamePattern, _ := ctx.GetConfig("worker:windowsAmiNamePattern")
+	// A change of eks:version upgrades the running cluster: the control plane first, then the system, Linux and
+	// Windows pools one after another. eks:upgrade also rolls the pools to the latest node images of the version.
+	currentVersion, err := workload.LookupClusterVersion(ctx, deployment.ClusterName())
+	if err != nil {
+		return err
+	}
+	var upgradePlan *workload.UpgradePlan
+	if upgrade, _ := ctx.GetConfig("eks:upgrade"); currentVersion != "" && (currentVersion != k8sVersion || upgrade == "true") {
+		upgradePlan, err = workload.PlanUpgrade(ctx, currentVersion, k8sVersion, windowsAmiNamePattern)
+		if err != nil {
+			return err
+		}
+		ctx.Log.Info(fmt.Sprintf("Upgrading cluster from %s to %s", upgradePlan.CurrentVersion, upgradePlan.TargetVersion), nil)
+	}
+	var systemReleaseVersion, linuxReleaseVersion pulumi.StringPtrInput
+	if upgradePlan != nil {
+		systemReleaseVersion = pulumi.String(upgradePlan.SystemReleaseVersion)
+		linuxReleaseVersion = pulumi.String(upgradePlan.LinuxReleaseVersion)
+	}
+	endpointAccess, err := workload.LoadEndpointAccess(ctx)
+	if err != nil {
+		return err
+	}
+	logging, err := workload.LoadLoggingConfig(ctx)
+	if err != nil {
+		return err
+	}
+	encryption, err := workload.LoadClusterEncryption(ctx)
+	if err != nil {
+		return err
+	}
+	addons, err := workload.LoadAddonConfigs(ctx)
+	if err != nil {
+		return err
+	}
+	tenants, err := workload.LoadTenantConfigs(ctx)
+	if err != nil {
+		return err
+	}
+	timeSlicingReplicas, err := workload.LoadTimeSlicingReplicas(ctx)
+	if err != nil {
+		return err
+	}
+	gpuMetricsSink, err := workload.LoadGpuMetricsSink(ctx)
+	if err != nil {
+		return err
+	}
+	containerInsights, err := workload.LoadContainerInsights(ctx)
+	if err != nil {
+		return err
+	}
+	if containerInsights {
+		if err := workload.EnableContainerInsights(addons); err != nil {
+			return err
+		}
+	}
+	observabilityConfig, err := workload.LoadObservabilityConfig(ctx)
+	if err != nil {
+		return err
+	}
+	grafanaAdminPassword, _ := ctx.GetConfig("cluster:grafanaAdminPassword")
+	if observabilityConfig != nil && grafanaAdminPassword == "" {
+		return errors.New("cluster:grafanaAdminPassword is required with cluster:observability")
+	}
+	// The ServiceMonitors need the CRDs of the Prometheus Operator, which only cluster:observability installs
+	if gpuMetricsSink == workload.GPU_METRICS_PROMETHEUS && observabilityConfig == nil {
+		return errors.New("worker:gpuMetrics=prometheus requires cluster:observability")
+	}
+	metadataOptions := map[string]workload.MetadataOptions{}
+	for _, pool := range []string{workload.POOL_SYSTEM, workload.POOL_LINUX, workload.POOL_WINDOWS} {
+		metadataOptions[pool], err = workload.LoadMetadataOptions(ctx, pool)
+		if err != nil {
+			return err
+		}
+	}
+	var windowsImageId string
+	if upgradePlan != nil {
+		windowsImageId = upgradePlan.WindowsImageId
+	} else {
+		ami, err := workload.LookupAMI(ctx, windowsAmiNamePattern, k8sVersion)
+		if err != nil {
+			return err
+		}
+		windowsImageId = ami.ImageId
+	}
+
+	network, err := workload.NewWorkloadNetwork(ctx, "network", &workload.WorkloadNetworkArgs{
+		Deployment: deployment,
+	})
+	if err != nil {
+		return err
+	}
+	cluster, err := workload.NewWorkloadCluster(ctx, "cluster", &workload.WorkloadClusterArgs{
+		Deployment:            deployment,
+		Network:               network,
+		Version:               k8sVersion,
+		AdminUsername:         adminUsername,
+		EndpointAccess:        endpointAccess,
+		Logging:               logging,
+		Encryption:            encryption,
+		Addons:                addons,
+		SystemReleaseVersion:  systemReleaseVersion,
+		SystemMetadataOptions: metadataOptions[workload.POOL_SYSTEM],
+		MaxUnavailable:        maxUnavailableInt,
+		Tenants:               tenants,
+	})
+	if err != nil {
+		return err
+	}
+	linuxPoolArgs := &workload.GpuNodePoolArgs{
+		Deployment:      deployment,
+		Network:         network,
+		Cluster:         cluster,
+		Pool:            workload.POOL_LINUX,
+		InstanceType:    linuxInstanceType,
+		DesiredCapacity: linuxDesiredCapacityInt,
+		MinSize:         linuxMinSizeInt,
+		MaxSize:         linuxMaxSizeInt,
+		MaxUnavailable:  maxUnavailableInt,
+		MetadataOptions: metadataOptions[workload.POOL_LINUX],
+		ReleaseVersion:  linuxReleaseVersion,
+	}
+	if upgradePlan != nil {
+		linuxPoolArgs.After = []pulumi.Resource{cluster.SystemNodeGroup}
+	}
+	linuxPool, err := workload.NewGpuNodePool(ctx, "linux-gpu-pool", linuxPoolArgs)
+	if err != nil {
+		return err
+	}
+	windowsPoolArgs := &workload.GpuNodePoolArgs{
+		Deployment:      deployment,
+		Network:         network,
+		Cluster:         cluster,
+		Pool:            workload.POOL_WINDOWS,
+		InstanceType:    windowsInstanceType,
+		DesiredCapacity: windowsDesiredCapacityInt,
+		MinSize:         windowsMinSizeInt,
+		MaxSize:         windowsMaxSizeInt,
+		MaxUnavailable:  maxUnavailableInt,
+		MetadataOptions: metadataOptions[workload.POOL_WINDOWS],
+		ImageId:         windowsImageId,
+		WindowsPassword: windowsPassword,
+	}
+	if upgradePlan != nil {
+		windowsPoolArgs.After = []pulumi.Resource{linuxPool.NodeGroup}
+	}
+	windowsPool, err := workload.NewGpuNodePool(ctx, "windows-gpu-pool", windowsPoolArgs)
+	if err != nil {
+		return err
+	}
+	_, err = workload.NewClusterAutoscaler(ctx, "cluster-autoscaler", &workload.ClusterAutoscalerArgs{
+		Deployment: deployment,
+		Cluster:    cluster,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = workload.NewWindowsGpuSupport(ctx, "windows-gpu", &workload.WindowsGpuSupportArgs{
+		Cluster:           cluster,
+		Pool:              windowsPool,
+		DevicePluginImage: workload.LoadWindowsDevicePluginImage(ctx),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = workload.NewNvidiaDevicePlugin(ctx, "nvidia-device-plugin", &workload.NvidiaDevicePluginArgs{
+		Cluster:             cluster,
+		TimeSlicingReplicas: timeSlicingReplicas,
+	})
+	if err != nil {
+		return err
+	}
+	var observability *workload.Observability
+	if observabilityConfig != nil {
+		observability, err = workload.NewObservability(ctx, "observability", &workload.ObservabilityArgs{
+			Cluster:              cluster,
+			Config:               *observabilityConfig,
+			GrafanaAdminPassword: config.GetSecret(ctx, "cluster:grafanaAdminPassword"),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	var gpuMetrics *workload.GpuMetrics
+	if gpuMetricsSink != "" {
+		gpuMetricsArgs := &workload.GpuMetricsArgs{
+			Deployment:       deployment,
+			Cluster:          cluster,
+			Sink:             gpuMetricsSink,
+			LogRetentionDays: logging.RetentionDays,
+		}
+		// The ServiceMonitors wait for the CRDs of the Prometheus Operator
+		if observability != nil {
+			gpuMetricsArgs.After = []pulumi.Resource{observability.PrometheusStack}
+		}
+		gpuMetrics, err = workload.NewGpuMetrics(ctx, "gpu-metrics", gpuMetricsArgs)
+		if err != nil {
+			return err
+		}
+	}
+	var insights *workload.ContainerInsights
+	if containerInsights {
+		insights, err = workload.NewContainerInsights(ctx, "container-insights", &workload.ContainerInsightsArgs{
+			Deployment:       deployment,
+			Cluster:          cluster,
+			LogRetentionDays: logging.RetentionDays,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	tenantKubeconfigs := pulumi.StringMap{}
+	for _, tenantConfig := range tenants {
+		tenant, err := workload.NewTenant(ctx, "tenant-"+tenantConfig.Name, &workload.TenantArgs{
+			Deployment: deployment,
+			Cluster:    cluster,
+			Config:     tenantConfig,
+		})
+		if err != nil {
+			return err
+		}
+		tenantKubeconfigs[tenantConfig.Name] = tenant.Kubeconfig
+	}
+
+	// The kubeconfig is only exported, as a secret; xbeam-infra kubeconfig write renders one from the cluster outputs
+	ctx.Export("Kubeconfig", pulumi.ToSecret(cluster.Kubeconfig))
+	if len(tenants) > 0 {
+		ctx.Export("TenantKubeconfigs", pulumi.ToSecret(tenantKubeconfigs))
+	}
+	ctx.Export("ClusterName", cluster.Cluster.EksCluster.Name())
+	ctx.Export("ClusterEndpoint", cluster.Cluster.EksCluster.Endpoint())
+	ctx.Export("ClusterCertificateAuthority", cluster.Cluster.EksCluster.CertificateAuthority().Data())
+	for name, id := range network.ResourceIds {
+		ctx.Export(name, id)
+	}
+	ctx.Export("InternalSecurityGroupID", network.InternalSecurityGroupRule)
+	ctx.Export("SecurityGroupRules", cluster.SecurityGroupRules)
+	if encryption.CreateKmsKey {
+		ctx.Export("ClusterKey", cluster.KeyArn)
+	}
+	ctx.Export("ControlPlaneLogGroup", cluster.Logging.LogGroup.Name)
+	ctx.Export("Addons", cluster.AddonVersions)
+	if cluster.ApiJumpHost != nil {
+		ctx.Export("ApiJumpHost", cluster.ApiJumpHost.Instance.ID())
+		ctx.Export("ApiTunnelCommand", cluster.ApiJumpHost.TunnelCommand)
+	}
+	ctx.Export("SystemNodeGroup", cluster.SystemNodeGroup.ID())
+	ctx.Export("LinuxNodeGroup", linuxPool.NodeGroup.ID())
+	ctx.Export("WindowsNodeGroup", windowsPool.NodeGroup.ID())
+	if gpuMetrics != nil && gpuMetrics.Dashboard != nil {
+		ctx.Export("GpuDashboard", gpuMetrics.Dashboard.DashboardName)
+	}
+	if observability != nil {
+		ctx.Export("GrafanaAdminPassword", observability.GrafanaAdminPassword)
+	}
+	if insights != nil {
+		ctx.Export("ContainerLogGroups", pulumi.StringArray{insights.ApplicationLogGroup.Name, insights.WindowsEventsLogGroup.Name})
+	}
+	ctx.Export("WorkerSecurityGroup", network.WorkerSecurityGroup.ID())
+	ctx.Export("ClusterCoreSecurityGroup", cluster.Cluster.Core.ClusterSecurityGroup().ApplyT(func(sg interface{}) (pulumi.IDOutput, error) {
+		return sg.(*ec2.SecurityGroup).ID(), nil
+	}).(pulumi.IDOutput))
+	ctx.Export("ClusterSecurityGroup", cluster.Cluster.ClusterSecurityGroup.ApplyT(func(sg interface{}) (pulumi.IDOutput, error) {
+		return sg.(*ec2.SecurityGroup).ID(), nil
+	}).(pulumi.IDOutput))
+	ctx.Export("ClusterNodeSecurityGroup", cluster.Cluster.NodeSecurityGroup.ApplyT(func(sg interface{}) (pulumi.IDOutput, error) {
+		return sg.(*ec2.SecurityGroup).ID(), nil
+	}).(pulumi.IDOutput))
+
+	return deployment.Names.Duplicates()
+}
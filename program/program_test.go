@@ -0,0 +1,791 @@
+package program
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+
+	"infra/policy"
+	"infra/workload"
+)
+
+const WINDOWS_PASSWORD = "Passw0rd!"
+
+// TEST_AMI_NAME_PATTERN matches the Windows image of a version in the mocks
+const TEST_AMI_NAME_PATTERN = "xbeam-windows-{version}-*"
+
+func testConfig() map[string]string {
+	return map[string]string{
+		"aws:region":                    "us-east-1",
+		"eks:accountId":                 TEST_ACCOUNT,
+		"eks:adminUsername":             "admin",
+		"worker:linuxInstance":          "g5.xlarge",
+		"worker:linuxMinSize":           "1",
+		"worker:linuxMaxSize":           "4",
+		"worker:linuxDesiredCapacity":   "2",
+		"worker:windowsInstance":        "g4dn.2xlarge",
+		"worker:windowsMinSize":         "0",
+		"worker:windowsMaxSize":         "3",
+		"worker:windowsDesiredCapacity": "1",
+		"worker:windowsAmi":             "XBeamWindows",
+		"worker:windowsPassword":        WINDOWS_PASSWORD,
+	}
+}
+
+// runProgram runs Run with the mocks in a temporary directory, as a preview when dryRun is set, and returns
+// the directory.
+func runProgram(t *testing.T, m *mocks, config map[string]string, dryRun bool) string {
+	t.Helper()
+	workDir := t.TempDir()
+	if err := runProgramErr(t, m, config, dryRun, workDir); err != nil {
+		t.Fatal(err)
+	}
+	return workDir
+}
+
+// runProgramErr runs Run with the mocks in workDir and returns its error.
+func runProgramErr(t *testing.T, m *mocks, config map[string]string, dryRun bool, workDir string) error {
+	t.Helper()
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(workDir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(dir)
+	return pulumi.RunErr(func(ctx *pulumi.Context) error {
+		if err := registerFixtures(ctx); err != nil {
+			return err
+		}
+		return Run(ctx)
+	}, pulumi.WithMocks(TEST_PROJECT, TEST_STACK, m), func(info *pulumi.RunInfo) {
+		info.Config = config
+		info.DryRun = dryRun
+	})
+}
+
+// deployed runs the program once with the test config.
+func deployed(t *testing.T) *mocks {
+	m := &mocks{}
+	runProgram(t, m, testConfig(), false)
+	return m
+}
+
+// plain returns an input without its secret wrapper.
+func plain(value resource.PropertyValue) resource.PropertyValue {
+	if value.IsSecret() {
+		return value.SecretValue().Element
+	}
+	return value
+}
+
+// byName returns the resource of the type whose name starts with prefix, e.g. workload-LinuxNodeGroup.
+func byName(t *testing.T, m *mocks, typeToken string, prefix string) pulumi.MockResourceArgs {
+	t.Helper()
+	for _, r := range m.byType(typeToken) {
+		if strings.HasPrefix(r.Name, prefix) {
+			return r
+		}
+	}
+	t.Fatalf("no %s named %s*", typeToken, prefix)
+	return pulumi.MockResourceArgs{}
+}
+
+func TestProgramResources(t *testing.T) {
+	m := deployed(t)
+	expected := []string{
+		"aws:cloudwatch/logGroup:LogGroup::workload-ControlPlaneLogGroup-WorkloadCluster-us-east-1-dev",
+		"aws:ec2/eip:Eip::workload-NatGatewayEIP1-us-east-1-dev",
+		"aws:ec2/eip:Eip::workload-NatGatewayEIP2-us-east-1-dev",
+		"aws:ec2/internetGateway:InternetGateway::workload-IGW-us-east-1-dev",
+		"aws:ec2/internetGatewayAttachment:InternetGatewayAttachment::workload-VPCGatewayAttachment-us-east-1-dev",
+		"aws:ec2/launchTemplate:LaunchTemplate::workload-LinuxLaunchTemplate-WorkloadCluster-us-east-1-dev",
+		"aws:ec2/launchTemplate:LaunchTemplate::workload-SystemLaunchTemplate-WorkloadCluster-us-east-1-dev",
+		"aws:ec2/launchTemplate:LaunchTemplate::workload-WindowsLaunchTemplate-WorkloadCluster-us-east-1-dev",
+		"aws:ec2/natGateway:NatGateway::workload-NatGateway01-us-east-1-dev",
+		"aws:ec2/natGateway:NatGateway::workload-NatGateway02-us-east-1-dev",
+		"aws:ec2/route:Route::workload-PrivateRoute01-us-east-1-dev",
+		"aws:ec2/route:Route::workload-PrivateRoute02-us-east-1-dev",
+		"aws:ec2/route:Route::workload-PublicRoute-us-east-1-dev",
+		"aws:ec2/routeTable:RouteTable::workload-PrivateRouteTable01-us-east-1-dev",
+		"aws:ec2/routeTable:RouteTable::workload-PrivateRouteTable02-us-east-1-dev",
+		"aws:ec2/routeTable:RouteTable::workload-PublicRouteTable-us-east-1-dev",
+		"aws:ec2/routeTableAssociation:RouteTableAssociation::workload-PrivateRouteTableAssociation01-us-east-1-dev",
+		"aws:ec2/routeTableAssociation:RouteTableAssociation::workload-PrivateRouteTableAssociation02-us-east-1-dev",
+		"aws:ec2/routeTableAssociation:RouteTableAssociation::workload-PublicRouteTableAssociation01-us-east-1-dev",
+		"aws:ec2/routeTableAssociation:RouteTableAssociation::workload-PublicRouteTableAssociation02-us-east-1-dev",
+		"aws:ec2/securityGroup:SecurityGroup::workload-WorkerSecurityGroup-us-east-1-dev",
+		"aws:ec2/securityGroupRule:SecurityGroupRule::workload-AllowFromSecurityGroup-cluster-sg-worker-sg-us-east-1-dev",
+		"aws:ec2/securityGroupRule:SecurityGroupRule::workload-AllowFromSecurityGroup-node-sg-worker-sg-us-east-1-dev",
+		"aws:ec2/securityGroupRule:SecurityGroupRule::workload-AllowFromSecurityGroup-worker-sg-cluster-sg-us-east-1-dev",
+		"aws:ec2/securityGroupRule:SecurityGroupRule::workload-AllowFromSecurityGroup-worker-sg-node-sg-us-east-1-dev",
+		"aws:ec2/securityGroupRule:SecurityGroupRule::workload-AllowFromSecurityGroup-worker-sg-worker-sg-us-east-1-dev",
+		"aws:ec2/subnet:Subnet::workload-PrivateSubnet01-us-east-1-dev",
+		"aws:ec2/subnet:Subnet::workload-PrivateSubnet02-us-east-1-dev",
+		"aws:ec2/subnet:Subnet::workload-PublicSubnet01-us-east-1-dev",
+		"aws:ec2/subnet:Subnet::workload-PublicSubnet02-us-east-1-dev",
+		"aws:ec2/vpc:Vpc::workload-VPC-us-east-1-dev",
+		"aws:eks/addon:Addon::workload-Addon-aws-ebs-csi-driver-WorkloadCluster-us-east-1-dev",
+		"aws:eks/addon:Addon::workload-Addon-coredns-WorkloadCluster-us-east-1-dev",
+		"aws:eks/addon:Addon::workload-Addon-eks-pod-identity-agent-WorkloadCluster-us-east-1-dev",
+		"aws:eks/addon:Addon::workload-Addon-kube-proxy-WorkloadCluster-us-east-1-dev",
+		"aws:eks/addon:Addon::workload-Addon-vpc-cni-WorkloadCluster-us-east-1-dev",
+		"aws:eks/nodeGroup:NodeGroup::workload-LinuxNodeGroup-WorkloadCluster-us-east-1-dev",
+		"aws:eks/nodeGroup:NodeGroup::workload-SystemNodeGroup-WorkloadCluster-us-east-1-dev",
+		"aws:eks/nodeGroup:NodeGroup::workload-WindowsNodeGroup-WorkloadCluster-us-east-1-dev",
+		"aws:iam/instanceProfile:InstanceProfile::workload-ClusterInstanceProfile-us-east-1-dev",
+		"aws:iam/policy:Policy::workload-AutoScalerPolicy-WorkloadCluster-us-east-1-dev",
+		"aws:iam/policyAttachment:PolicyAttachment::workload-AutoScalerPolicyAttachment-WorkloadCluster-us-east-1-dev",
+		"aws:iam/role:Role::workload-AddonRole-aws-ebs-csi-driver-us-east-1-dev",
+		"aws:iam/role:Role::workload-AutoScalerRole-WorkloadCluster-us-east-1-dev",
+		"aws:iam/role:Role::workload-ClusterRole-us-east-1-dev",
+		"aws:iam/role:Role::workload-LinuxWorkerRole-WorkloadCluster-us-east-1-dev",
+		"aws:iam/role:Role::workload-SystemRole-WorkloadCluster-us-east-1-dev",
+		"aws:iam/role:Role::workload-WindowsWorkerRole-WorkloadCluster-us-east-1-dev",
+		"aws:kms/key:Key::workload-ControlPlaneLogsKey-WorkloadCluster-us-east-1-dev",
+		"eks:index:Cluster::workload-WorkloadCluster-us-east-1-dev",
+		"kubernetes:apps/v1:DaemonSet::windows-gpu-device-plugin",
+		"kubernetes:apps/v1:DaemonSet::windows-gpu-validation",
+		"kubernetes:core/v1:Service::cluster-kube-dns",
+		"kubernetes:core/v1:ServiceAccount::cluster-autoscaler",
+		"kubernetes:helm.sh/v3:Release::cluster-autoscaler",
+		"kubernetes:helm.sh/v3:Release::nvidia-device-plugin",
+		"pulumi:providers:kubernetes::cluster-k8s",
+		"xbeam:index:ClusterAutoscaler::cluster-autoscaler",
+		"xbeam:index:GpuNodePool::linux-gpu-pool",
+		"xbeam:index:GpuNodePool::windows-gpu-pool",
+		"xbeam:index:NvidiaDevicePlugin::nvidia-device-plugin",
+		"xbeam:index:WindowsGpuSupport::windows-gpu",
+		"xbeam:index:WorkloadCluster::cluster",
+		"xbeam:index:WorkloadNetwork::network",
+	}
+	names := m.names()
+	for _, name := range expected {
+		if !names[name] {
+			t.Errorf("missing %s", name)
+		}
+		delete(names, name)
+	}
+	unexpected := []string{}
+	for name := range names {
+		unexpected = append(unexpected, name)
+	}
+	sort.Strings(unexpected)
+	for _, name := range unexpected {
+		t.Errorf("unexpected %s", name)
+	}
+}
+
+// TestTwoClusters builds two workloads in one stack, as the provider does for a program with two
+// WorkloadCluster resources, and expects every resource to get its own URN.
+func TestTwoClusters(t *testing.T) {
+	m := &mocks{}
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		if err := registerFixtures(ctx); err != nil {
+			return err
+		}
+		partition := &workload.Partition{Name: workload.PARTITION_AWS, DnsSuffix: "amazonaws.com"}
+		for _, name := range []string{"blue", "green"} {
+			deployment := workload.NewDeployment(TEST_STACK+"-"+name, "us-east-1", TEST_ACCOUNT, partition)
+			network, err := workload.NewWorkloadNetwork(ctx, name+"-network", &workload.WorkloadNetworkArgs{
+				Deployment: deployment,
+			})
+			if err != nil {
+				return err
+			}
+			endpointAccess, err := workload.NewEndpointAccess("", nil, 0)
+			if err != nil {
+				return err
+			}
+			logging, err := workload.NewLoggingConfig(nil, workload.DEFAULT_LOG_RETENTION_DAYS, "", "")
+			if err != nil {
+				return err
+			}
+			cluster, err := workload.NewWorkloadCluster(ctx, name, &workload.WorkloadClusterArgs{
+				Deployment:            deployment,
+				Network:               network,
+				Version:               workload.K8S_VERSION,
+				AdminUsername:         "admin",
+				EndpointAccess:        endpointAccess,
+				Logging:               logging,
+				Addons:                map[string]workload.AddonConfig{},
+				SystemMetadataOptions: workload.DefaultMetadataOptions(),
+				MaxUnavailable:        1,
+			})
+			if err != nil {
+				return err
+			}
+			_, err = workload.NewClusterAutoscaler(ctx, name+"-cluster-autoscaler", &workload.ClusterAutoscalerArgs{
+				Deployment: deployment,
+				Cluster:    cluster,
+			}, pulumi.Parent(cluster))
+			if err != nil {
+				return err
+			}
+			windowsPool, err := workload.NewGpuNodePool(ctx, name+"-windows-gpu-pool", &workload.GpuNodePoolArgs{
+				Deployment:      deployment,
+				Network:         network,
+				Cluster:         cluster,
+				Pool:            workload.POOL_WINDOWS,
+				InstanceType:    "g4dn.2xlarge",
+				DesiredCapacity: 1,
+				MaxSize:         1,
+				MaxUnavailable:  1,
+				MetadataOptions: workload.DefaultMetadataOptions(),
+				ImageId:         TEST_AMI,
+				WindowsPassword: pulumi.String(WINDOWS_PASSWORD),
+			}, pulumi.Parent(cluster))
+			if err != nil {
+				return err
+			}
+			_, err = workload.NewWindowsGpuSupport(ctx, name+"-windows-gpu", &workload.WindowsGpuSupportArgs{
+				Cluster: cluster,
+				Pool:    windowsPool,
+			})
+			if err != nil {
+				return err
+			}
+			_, err = workload.NewNvidiaDevicePlugin(ctx, name+"-nvidia-device-plugin", &workload.NvidiaDevicePluginArgs{
+				Cluster: cluster,
+			})
+			if err != nil {
+				return err
+			}
+			_, err = workload.NewObservability(ctx, name+"-observability", &workload.ObservabilityArgs{
+				Cluster:              cluster,
+				Config:               workload.ObservabilityConfig{},
+				GrafanaAdminPassword: pulumi.String("Gr4fana!"),
+			})
+			if err != nil {
+				return err
+			}
+			_, err = workload.NewGpuMetrics(ctx, name+"-gpu-metrics", &workload.GpuMetricsArgs{
+				Deployment:       deployment,
+				Cluster:          cluster,
+				Sink:             workload.GPU_METRICS_CLOUDWATCH,
+				LogRetentionDays: workload.DEFAULT_LOG_RETENTION_DAYS,
+			})
+			if err != nil {
+				return err
+			}
+			_, err = workload.NewContainerInsights(ctx, name+"-container-insights", &workload.ContainerInsightsArgs{
+				Deployment:       deployment,
+				Cluster:          cluster,
+				LogRetentionDays: workload.DEFAULT_LOG_RETENTION_DAYS,
+			})
+			if err != nil {
+				return err
+			}
+			_, err = workload.NewTenant(ctx, name+"-tenant-team-a", &workload.TenantArgs{
+				Deployment: deployment,
+				Cluster:    cluster,
+				Config:     workload.TenantConfig{Name: "team-a", RoleArn: "arn:aws:iam::" + TEST_ACCOUNT + ":role/team-a"},
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}, pulumi.WithMocks(TEST_PROJECT, TEST_STACK, m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clusters := m.byType(workload.TYPE_WORKLOAD_CLUSTER); len(clusters) != 2 {
+		t.Fatalf("expected 2 clusters, got %d", len(clusters))
+	}
+	if duplicates := m.duplicateURNs(); len(duplicates) > 0 {
+		t.Errorf("resources share a URN: %v", duplicates)
+	}
+}
+
+func TestNodeGroupScaling(t *testing.T) {
+	m := deployed(t)
+	expected := map[string][3]float64{
+		"workload-SystemNodeGroup":  {1, 1, workload.SYSTEM_POOL_MAX_SIZE},
+		"workload-LinuxNodeGroup":   {2, 1, 4},
+		"workload-WindowsNodeGroup": {1, 0, 3},
+	}
+	for prefix, sizes := range expected {
+		scaling := plain(byName(t, m, "aws:eks/nodeGroup:NodeGroup", prefix).Inputs["scalingConfig"]).ObjectValue()
+		got := [3]float64{scaling["desiredSize"].NumberValue(), scaling["minSize"].NumberValue(), scaling["maxSize"].NumberValue()}
+		if got != sizes {
+			t.Errorf("%s: expected desired, min and max %v, got %v", prefix, sizes, got)
+		}
+	}
+}
+
+func TestSecurityGroupRules(t *testing.T) {
+	m := deployed(t)
+	worker := byName(t, m, "aws:ec2/securityGroup:SecurityGroup", "workload-WorkerSecurityGroup").Name + "_id"
+	groups := map[string]string{
+		"worker-sg":  worker,
+		"node-sg":    fixtureId("nodeSecurityGroup"),
+		"cluster-sg": fixtureId("clusterSecurityGroup"),
+	}
+	rules := m.byType("aws:ec2/securityGroupRule:SecurityGroupRule")
+	if len(rules) != 5 {
+		t.Fatalf("expected 5 security group rules, got %d", len(rules))
+	}
+	for _, rule := range rules {
+		// workload-AllowFromSecurityGroup-<group>-<source>-<region>-<stack>
+		parts := strings.Split(strings.TrimPrefix(rule.Name, "workload-AllowFromSecurityGroup-"), "-")
+		group, source := parts[0]+"-"+parts[1], parts[2]+"-"+parts[3]
+		inputs := rule.Inputs
+		if inputs["securityGroupId"].StringValue() != groups[group] || inputs["sourceSecurityGroupId"].StringValue() != groups[source] {
+			t.Errorf("%s: expected %s from %s, got %s from %s", rule.Name, groups[group], groups[source],
+				inputs["securityGroupId"].StringValue(), inputs["sourceSecurityGroupId"].StringValue())
+		}
+		if inputs["type"].StringValue() != "ingress" || inputs["protocol"].StringValue() != "-1" {
+			t.Errorf("%s: expected an ingress rule for every protocol", rule.Name)
+		}
+	}
+}
+
+func TestTrustPolicies(t *testing.T) {
+	m := deployed(t)
+	oidcArn := "arn:aws:mock::" + TEST_ACCOUNT + ":" + fixtureName("oidcProvider")
+	expected := map[string]string{
+		"workload-ClusterRole":                  "eks.amazonaws.com",
+		"workload-SystemRole":                   "ec2.amazonaws.com",
+		"workload-LinuxWorkerRole":              "ec2.amazonaws.com",
+		"workload-WindowsWorkerRole":            "ec2.amazonaws.com",
+		"workload-AutoScalerRole":               oidcArn,
+		"workload-AddonRole-aws-ebs-csi-driver": oidcArn,
+	}
+	for prefix, principal := range expected {
+		role := byName(t, m, "aws:iam/role:Role", prefix)
+		var policy workload.PolicyDocument
+		if err := json.Unmarshal([]byte(plain(role.Inputs["assumeRolePolicy"]).StringValue()), &policy); err != nil {
+			t.Fatalf("%s: invalid trust policy: %v", prefix, err)
+		}
+		if len(policy.Statement) != 1 || policy.Statement[0].Principal == nil {
+			t.Fatalf("%s: expected one statement with a principal, got %+v", prefix, policy)
+		}
+		statement := policy.Statement[0]
+		principals := append(statement.Principal.Service, statement.Principal.Federated...)
+		if len(principals) == 0 || principals[0] != principal {
+			t.Errorf("%s: expected %s to be trusted, got %v", prefix, principal, principals)
+		}
+		if len(statement.Principal.Federated) > 0 && statement.Action[0] != "sts:AssumeRoleWithWebIdentity" {
+			t.Errorf("%s: a federated principal needs sts:AssumeRoleWithWebIdentity, got %v", prefix, statement.Action)
+		}
+	}
+	for prefix, subject := range map[string]string{
+		"workload-AutoScalerRole":               "system:serviceaccount:kube-system:cluster-autoscaler",
+		"workload-AddonRole-aws-ebs-csi-driver": "system:serviceaccount:kube-system:ebs-csi-controller-sa",
+	} {
+		role := byName(t, m, "aws:iam/role:Role", prefix)
+		if !strings.Contains(plain(role.Inputs["assumeRolePolicy"]).StringValue(), subject) {
+			t.Errorf("%s must be limited to the service account %s", prefix, subject)
+		}
+	}
+}
+
+func TestLaunchTemplateUserData(t *testing.T) {
+	m := deployed(t)
+	windows := byName(t, m, "aws:ec2/launchTemplate:LaunchTemplate", "workload-WindowsLaunchTemplate")
+	if !windows.Inputs["userData"].IsSecret() {
+		t.Error("the Windows user data holds the Administrator password and must be a secret")
+	}
+	decoded, err := base64.StdEncoding.DecodeString(plain(windows.Inputs["userData"]).StringValue())
+	if err != nil {
+		t.Fatalf("user data is not base64: %v", err)
+	}
+	for _, line := range []string{
+		"<powershell>",
+		"net user Administrator '" + WINDOWS_PASSWORD + "'",
+		"-EKSClusterName '" + TEST_CLUSTER_NAME + "' -APIServerEndpoint '" + TEST_ENDPOINT + "' -Base64ClusterCA '" + TEST_CA_DATA + "' -DNSClusterIP '" + TEST_DNS_IP + "'",
+		"-KubeletExtraArgs '--node-labels=workload=gpu'",
+		"</powershell>",
+		"<persist>true</persist>",
+	} {
+		if !strings.Contains(string(decoded), line) {
+			t.Errorf("Windows user data has no %q:\n%s", line, decoded)
+		}
+	}
+	if windows.Inputs["imageId"].StringValue() != TEST_AMI {
+		t.Errorf("expected the Windows pool to use %s, got %v", TEST_AMI, windows.Inputs["imageId"])
+	}
+	// EKS bootstraps the managed Linux node groups and merges its own user data
+	for _, prefix := range []string{"workload-LinuxLaunchTemplate", "workload-SystemLaunchTemplate"} {
+		if userData, ok := byName(t, m, "aws:ec2/launchTemplate:LaunchTemplate", prefix).Inputs["userData"]; ok {
+			t.Errorf("%s must leave the bootstrap to EKS, got user data %v", prefix, userData)
+		}
+	}
+}
+
+// A preview with the outputs unknown runs no ApplyT callback beyond the user data, so a resource that is only created in a full run
+// was created inside one and would be missing from previews.
+func TestNoResourcesInApply(t *testing.T) {
+	full := deployed(t)
+	preview := &mocks{unknown: true}
+	runProgram(t, preview, testConfig(), true)
+	previewed := preview.names()
+	for name := range full.names() {
+		if !previewed[name] {
+			t.Errorf("%s is created inside an ApplyT", name)
+		}
+	}
+}
+
+// The sample config must not be deployable unchanged
+func TestSampleValues(t *testing.T) {
+	for key, value := range map[string]string{
+		"eks:accountId":          "<account-id>",
+		"eks:adminUsername":      "<admin-username>",
+		"worker:windowsPassword": "<windows-password>",
+	} {
+		config := testConfig()
+		config[key] = value
+		m := &mocks{}
+		if err := runProgramErr(t, m, config, false, t.TempDir()); err == nil || !strings.Contains(err.Error(), key) {
+			t.Errorf("expected the placeholder of %s to be rejected, got %v", key, err)
+		}
+		if len(m.byType("eks:index:Cluster")) != 0 {
+			t.Errorf("expected no cluster with the placeholder of %s", key)
+		}
+	}
+	config := testConfig()
+	config["worker:windowsPassword"] = SAMPLE_WINDOWS_PASSWORD
+	if err := runProgramErr(t, &mocks{}, config, false, t.TempDir()); err == nil {
+		t.Error("expected the published sample password to be rejected")
+	}
+}
+
+func TestSecurityBaseline(t *testing.T) {
+	m := &mocks{}
+	workDir := runProgram(t, m, testConfig(), false)
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, r := range m.resources {
+		if strings.HasPrefix(r.Name, FIXTURE_PREFIX) {
+			continue
+		}
+		for _, violation := range policy.Validate(policy.Resource{Type: r.TypeToken, Name: r.Name, Properties: r.Inputs}) {
+			t.Error(violation)
+		}
+	}
+	// The kubeconfig is a secret output, the program writes no files
+	if entries, err := os.ReadDir(workDir); err != nil || len(entries) != 0 {
+		t.Errorf("expected the program to write no files, got %v %v", entries, err)
+	}
+}
+
+func TestTenants(t *testing.T) {
+	config := testConfig()
+	config["cluster:tenants"] = `[{"name":"team-a","roleArn":"arn:aws:iam::` + TEST_ACCOUNT + `:role/team-a","quota":{"gpus":2}}]`
+	m := &mocks{}
+	runProgram(t, m, config, false)
+	names := m.names()
+	for _, name := range []string{
+		"xbeam:index:Tenant::tenant-team-a",
+		"kubernetes:core/v1:Namespace::tenant-team-a",
+		"kubernetes:core/v1:ResourceQuota::tenant-team-a-quota",
+		"kubernetes:core/v1:LimitRange::tenant-team-a-limits",
+		"kubernetes:networking.k8s.io/v1:NetworkPolicy::tenant-team-a-default-deny",
+		"kubernetes:rbac.authorization.k8s.io/v1:Role::tenant-team-a-role",
+		"kubernetes:rbac.authorization.k8s.io/v1:RoleBinding::tenant-team-a-role-binding",
+	} {
+		if !names[name] {
+			t.Errorf("missing %s", name)
+		}
+	}
+	quota := byName(t, m, "kubernetes:core/v1:ResourceQuota", "tenant-team-a")
+	hard := quota.Inputs["spec"].ObjectValue()["hard"].ObjectValue()
+	if hard["requests.nvidia.com/gpu"].StringValue() != "2" {
+		t.Errorf("expected a quota of 2 GPUs, got %v", hard)
+	}
+	binding := byName(t, m, "kubernetes:rbac.authorization.k8s.io/v1:RoleBinding", "tenant-team-a")
+	subject := binding.Inputs["subjects"].ArrayValue()[0].ObjectValue()
+	if subject["kind"].StringValue() != "Group" || subject["name"].StringValue() != "xbeam:tenant:team-a" {
+		t.Errorf("expected the role to be bound to the tenant group, got %v", subject)
+	}
+	// The aws-auth mapping puts the members of the IAM role into the group
+	cluster := byName(t, m, "eks:index:Cluster", "workload-WorkloadCluster")
+	mapped := false
+	for _, mapping := range cluster.Inputs["roleMappings"].ArrayValue() {
+		mapping := mapping.ObjectValue()
+		if mapping["roleArn"].StringValue() == "arn:aws:iam::"+TEST_ACCOUNT+":role/team-a" {
+			groups := mapping["groups"].ArrayValue()
+			mapped = len(groups) == 1 && groups[0].StringValue() == "xbeam:tenant:team-a"
+		}
+	}
+	if !mapped {
+		t.Errorf("expected the tenant role to be mapped to its group, got %v", cluster.Inputs["roleMappings"])
+	}
+}
+
+func TestNvidiaDevicePlugin(t *testing.T) {
+	config := testConfig()
+	config["worker:gpuTimeSlicingReplicas"] = "4"
+	m := &mocks{}
+	runProgram(t, m, config, false)
+	values := byName(t, m, "kubernetes:helm.sh/v3:Release", "nvidia-device-plugin").Inputs["values"].ObjectValue()
+	// Both taints of the GPU node groups are tolerated
+	effects := []string{}
+	for _, toleration := range values["tolerations"].ArrayValue() {
+		toleration := toleration.ObjectValue()
+		if toleration["key"].StringValue() == "workload" && toleration["value"].StringValue() == "gpu" {
+			effects = append(effects, toleration["effect"].StringValue())
+		}
+	}
+	if strings.Join(effects, ",") != "NoSchedule,NoExecute" {
+		t.Errorf("expected the workload=gpu taints to be tolerated, got %v", values["tolerations"])
+	}
+	terms := values["affinity"].ObjectValue()["nodeAffinity"].ObjectValue()["requiredDuringSchedulingIgnoredDuringExecution"].ObjectValue()["nodeSelectorTerms"].ArrayValue()
+	expressions := terms[0].ObjectValue()["matchExpressions"].ArrayValue()
+	if len(expressions) != 2 || expressions[0].ObjectValue()["key"].StringValue() != "workload" {
+		t.Errorf("expected the plugin to require the GPU pool, got %v", expressions)
+	}
+	if !values["gfd"].ObjectValue()["enabled"].BoolValue() {
+		t.Error("expected GPU feature discovery to be enabled")
+	}
+	timeSlicing := values["config"].ObjectValue()["map"].ObjectValue()["time-slicing"].StringValue()
+	if !strings.Contains(timeSlicing, "replicas: 4") {
+		t.Errorf("expected 4 replicas per GPU, got %s", timeSlicing)
+	}
+}
+
+func TestWindowsGpuSupport(t *testing.T) {
+	config := testConfig()
+	config["worker:windowsDevicePluginImage"] = "registry.example.com/device-plugin:1.0"
+	m := &mocks{}
+	runProgram(t, m, config, false)
+	for _, name := range []string{"windows-gpu-device-plugin", "windows-gpu-validation"} {
+		spec := byName(t, m, "kubernetes:apps/v1:DaemonSet", name).Inputs["spec"].ObjectValue()
+		pod := spec["template"].ObjectValue()["spec"].ObjectValue()
+		selector := pod["nodeSelector"].ObjectValue()
+		if selector["kubernetes.io/os"].StringValue() != "windows" || selector["workload"].StringValue() != "gpu" {
+			t.Errorf("%s: expected the Windows GPU pool, got %v", name, selector)
+		}
+		if tolerations := pod["tolerations"].ArrayValue(); len(tolerations) != 2 {
+			t.Errorf("%s: expected the workload=gpu tolerations, got %v", name, tolerations)
+		}
+		if !pod["securityContext"].ObjectValue()["windowsOptions"].ObjectValue()["hostProcess"].BoolValue() {
+			t.Errorf("%s: expected a host process pod", name)
+		}
+		container := pod["containers"].ArrayValue()[0].ObjectValue()
+		if name == "windows-gpu-device-plugin" && container["image"].StringValue() != "registry.example.com/device-plugin:1.0" {
+			t.Errorf("expected the configured device plugin image, got %v", container["image"])
+		}
+		if name == "windows-gpu-validation" {
+			// Every node, also one the autoscaler adds later, is validated by the readiness probe of its pod
+			probe := container["readinessProbe"].ObjectValue()["exec"].ObjectValue()["command"].ArrayValue()
+			if len(probe) == 0 || !strings.Contains(probe[len(probe)-1].StringValue(), "nvidia-smi") {
+				t.Errorf("expected the readiness probe to run the validation script, got %v", probe)
+			}
+		}
+	}
+	if jobs := m.byType("kubernetes:batch/v1:Job"); len(jobs) != 0 {
+		t.Errorf("expected no Job sized from the desired capacity, got %v", jobs)
+	}
+}
+
+func TestGpuMetrics(t *testing.T) {
+	config := testConfig()
+	config["worker:gpuMetrics"] = workload.GPU_METRICS_CLOUDWATCH
+	m := &mocks{}
+	runProgram(t, m, config, false)
+	names := m.names()
+	for _, name := range []string{
+		"xbeam:index:GpuMetrics::gpu-metrics",
+		"kubernetes:helm.sh/v3:Release::gpu-metrics-dcgm-exporter",
+		"kubernetes:apps/v1:DaemonSet::gpu-metrics-windows-exporter",
+		"kubernetes:apps/v1:Deployment::gpu-metrics-cloudwatch-agent",
+		"aws:cloudwatch/dashboard:Dashboard::workload-GpuDashboard-WorkloadCluster-us-east-1-dev",
+		"aws:cloudwatch/logGroup:LogGroup::workload-GpuMetricsLogGroup-WorkloadCluster-us-east-1-dev",
+		"aws:iam/role:Role::workload-GpuMetricsAgentRole-us-east-1-dev",
+	} {
+		if !names[name] {
+			t.Errorf("missing %s", name)
+		}
+	}
+	role := byName(t, m, "aws:iam/role:Role", "workload-GpuMetricsAgentRole")
+	if !strings.Contains(plain(role.Inputs["assumeRolePolicy"]).StringValue(), "system:serviceaccount:gpu-metrics:cloudwatch-agent") {
+		t.Error("the CloudWatch agent role must be limited to its service account")
+	}
+	if len(m.byType("kubernetes:monitoring.coreos.com/v1:ServiceMonitor")) != 0 {
+		t.Error("expected no ServiceMonitor without Prometheus")
+	}
+	for _, r := range m.byType("aws:cloudwatch/logGroup:LogGroup") {
+		for _, violation := range policy.Validate(policy.Resource{Type: r.TypeToken, Name: r.Name, Properties: r.Inputs}) {
+			t.Error(violation)
+		}
+	}
+
+	// Without cluster:observability there is no Prometheus Operator to install the ServiceMonitor CRD
+	config["worker:gpuMetrics"] = workload.GPU_METRICS_PROMETHEUS
+	if err := runProgramErr(t, &mocks{}, config, false, t.TempDir()); err == nil || !strings.Contains(err.Error(), "cluster:observability") {
+		t.Errorf("expected prometheus without cluster:observability to be rejected, got %v", err)
+	}
+	config["cluster:observability"] = `{}`
+	config["cluster:grafanaAdminPassword"] = "Gr4fana!"
+	m = &mocks{}
+	runProgram(t, m, config, false)
+	if len(m.byType("kubernetes:monitoring.coreos.com/v1:ServiceMonitor")) != 1 || len(m.byType("aws:cloudwatch/dashboard:Dashboard")) != 0 {
+		t.Error("expected a ServiceMonitor for the Windows exporter and no CloudWatch resources with Prometheus")
+	}
+	values := byName(t, m, "kubernetes:helm.sh/v3:Release", "gpu-metrics-dcgm-exporter").Inputs["values"].ObjectValue()
+	if !values["serviceMonitor"].ObjectValue()["enabled"].BoolValue() {
+		t.Error("expected the DCGM exporter ServiceMonitor to be enabled")
+	}
+}
+
+func TestObservability(t *testing.T) {
+	config := testConfig()
+	config["cluster:observability"] = `{"prometheusRetention":"30d"}`
+	config["cluster:grafanaAdminPassword"] = "Gr4fana!"
+	config["worker:gpuMetrics"] = workload.GPU_METRICS_PROMETHEUS
+	m := &mocks{}
+	runProgram(t, m, config, false)
+	names := m.names()
+	for _, name := range []string{
+		"xbeam:index:Observability::observability",
+		"kubernetes:core/v1:Namespace::observability",
+		"kubernetes:storage.k8s.io/v1:StorageClass::observability-gp3",
+		"kubernetes:core/v1:Secret::observability-grafana-admin",
+		"kubernetes:helm.sh/v3:Release::observability-kube-prometheus-stack",
+		"kubernetes:helm.sh/v3:Release::observability-loki",
+		"kubernetes:helm.sh/v3:Release::observability-promtail",
+	} {
+		if !names[name] {
+			t.Errorf("missing %s", name)
+		}
+	}
+	storageClass := byName(t, m, "kubernetes:storage.k8s.io/v1:StorageClass", "observability-gp3")
+	parameters := storageClass.Inputs["parameters"].ObjectValue()
+	if parameters["type"].StringValue() != "gp3" || parameters["encrypted"].StringValue() != "true" {
+		t.Errorf("expected encrypted gp3 volumes, got %v", parameters)
+	}
+	values := byName(t, m, "kubernetes:helm.sh/v3:Release", "observability-kube-prometheus-stack").Inputs["values"].ObjectValue()
+	spec := values["prometheus"].ObjectValue()["prometheusSpec"].ObjectValue()
+	if spec["retention"].StringValue() != "30d" || spec["nodeSelector"].ObjectValue()["type"].StringValue() != "system" {
+		t.Errorf("expected Prometheus on the system pool with a 30d retention, got %v", spec)
+	}
+	if spec["serviceMonitorSelectorNilUsesHelmValues"].BoolValue() {
+		t.Error("expected Prometheus to select the GPU metrics ServiceMonitors")
+	}
+	if !values["windowsMonitoring"].ObjectValue()["enabled"].BoolValue() {
+		t.Error("expected the Windows node exporter")
+	}
+	for _, release := range []string{"observability-kube-prometheus-stack", "observability-loki"} {
+		if strings.Contains(byName(t, m, "kubernetes:helm.sh/v3:Release", release).Inputs["values"].String(), "Gr4fana!") {
+			t.Errorf("the Grafana password must not be a value of %s", release)
+		}
+	}
+	secret := byName(t, m, "kubernetes:core/v1:Secret", "observability-grafana-admin")
+	password := plain(secret.Inputs["stringData"]).ObjectValue()["admin-password"]
+	if plain(password).StringValue() != "Gr4fana!" {
+		t.Errorf("expected the Grafana password in the admin secret, got %v", password)
+	}
+	if !secret.Inputs["stringData"].ContainsSecrets() {
+		t.Error("the Grafana password must stay a secret")
+	}
+
+	m = &mocks{}
+	runProgram(t, m, testConfig(), false)
+	if len(m.byType("xbeam:index:Observability")) != 0 || len(m.byType("kubernetes:storage.k8s.io/v1:StorageClass")) != 0 {
+		t.Error("expected no observability stack without cluster:observability")
+	}
+}
+
+func TestContainerInsights(t *testing.T) {
+	config := testConfig()
+	config["cluster:containerInsights"] = "true"
+	m := &mocks{}
+	runProgram(t, m, config, false)
+	names := m.names()
+	for _, name := range []string{
+		"xbeam:index:ContainerInsights::container-insights",
+		"kubernetes:core/v1:Namespace::container-insights",
+		"kubernetes:helm.sh/v3:Release::container-insights-aws-for-fluent-bit",
+		"kubernetes:apps/v1:DaemonSet::container-insights-fluent-bit-windows",
+		"kubernetes:core/v1:ConfigMap::container-insights-fluent-bit-windows-config",
+		"aws:cloudwatch/logGroup:LogGroup::workload-ApplicationLogGroup-WorkloadCluster-us-east-1-dev",
+		"aws:cloudwatch/logGroup:LogGroup::workload-WindowsEventsLogGroup-WorkloadCluster-us-east-1-dev",
+		"aws:iam/role:Role::workload-FluentBitRole-us-east-1-dev",
+	} {
+		if !names[name] {
+			t.Errorf("missing %s", name)
+		}
+	}
+	addon := byName(t, m, "aws:eks/addon:Addon", "workload-Addon-amazon-cloudwatch-observability")
+	if addon.Inputs["configurationValues"].StringValue() != `{"containerLogs":{"enabled":false}}` {
+		t.Errorf("expected the add-on to leave the container logs to Fluent Bit, got %v", addon.Inputs["configurationValues"])
+	}
+	role := byName(t, m, "aws:iam/role:Role", "workload-FluentBitRole")
+	if !strings.Contains(plain(role.Inputs["assumeRolePolicy"]).StringValue(), "system:serviceaccount:fluent-bit:fluent-bit") {
+		t.Error("the Fluent Bit role must be limited to its service account")
+	}
+	windows := byName(t, m, "kubernetes:apps/v1:DaemonSet", "container-insights-fluent-bit-windows")
+	podSpec := windows.Inputs["spec"].ObjectValue()["template"].ObjectValue()["spec"].ObjectValue()
+	if !podSpec["securityContext"].ObjectValue()["windowsOptions"].ObjectValue()["hostProcess"].BoolValue() {
+		t.Error("expected Fluent Bit to run as a host process to read the event logs")
+	}
+	for _, r := range m.byType("aws:cloudwatch/logGroup:LogGroup") {
+		for _, violation := range policy.Validate(policy.Resource{Type: r.TypeToken, Name: r.Name, Properties: r.Inputs}) {
+			t.Error(violation)
+		}
+	}
+
+	if len(m.byType("aws:eks/addon:Addon")) == len(deployed(t).byType("aws:eks/addon:Addon")) {
+		t.Error("expected the CloudWatch observability add-on only with cluster:containerInsights")
+	}
+}
+
+func TestKubernetesVersionChange(t *testing.T) {
+	// Without worker:windowsAmiNamePattern a new cluster takes the latest Windows image
+	config := testConfig()
+	config["eks:version"] = "1.28"
+	m := &mocks{}
+	runProgram(t, m, config, false)
+	if imageId := byName(t, m, "aws:ec2/launchTemplate:LaunchTemplate", "workload-WindowsLaunchTemplate").Inputs["imageId"].StringValue(); imageId != TEST_AMI {
+		t.Errorf("expected the latest Windows image, got %s", imageId)
+	}
+
+	// With it, the Windows image of its version, and it plans no upgrade
+	config["worker:windowsAmiNamePattern"] = TEST_AMI_NAME_PATTERN
+	m = &mocks{}
+	runProgram(t, m, config, false)
+	if imageId := byName(t, m, "aws:ec2/launchTemplate:LaunchTemplate", "workload-WindowsLaunchTemplate").Inputs["imageId"].StringValue(); imageId != TEST_OLD_AMI {
+		t.Errorf("expected the Windows image of 1.28, got %s", imageId)
+	}
+	if byName(t, m, "aws:eks/nodeGroup:NodeGroup", "workload-LinuxNodeGroup").Inputs.HasValue("releaseVersion") {
+		t.Error("expected no pinned release without an upgrade")
+	}
+
+	// Raising eks:version upgrades every pool, even without eks:upgrade
+	config = testConfig()
+	config["worker:windowsAmiNamePattern"] = TEST_AMI_NAME_PATTERN
+	m = &mocks{clusterVersion: "1.28"}
+	runProgram(t, m, config, false)
+	if release := byName(t, m, "aws:eks/nodeGroup:NodeGroup", "workload-LinuxNodeGroup").Inputs["releaseVersion"].StringValue(); release != TEST_RELEASE_VERSION {
+		t.Errorf("expected the Linux pool on the release of %s, got %q", workload.K8S_VERSION, release)
+	}
+	if imageId := byName(t, m, "aws:ec2/launchTemplate:LaunchTemplate", "workload-WindowsLaunchTemplate").Inputs["imageId"].StringValue(); imageId != TEST_AMI {
+		t.Errorf("expected the Windows image of %s, got %s", workload.K8S_VERSION, imageId)
+	}
+	// The node group follows the launch template version, so a new image rolls the Windows nodes
+	windows := byName(t, m, "aws:eks/nodeGroup:NodeGroup", "workload-WindowsNodeGroup")
+	if version := windows.Inputs["launchTemplate"].ObjectValue()["version"]; version.IsString() && version.StringValue() == "$Latest" {
+		t.Error("expected the Windows node group to pin the launch template version")
+	}
+
+	// More than one minor version, or a downgrade, fails before any resource is created
+	for _, current := range []string{"1.27", "1.30"} {
+		m = &mocks{clusterVersion: current}
+		if err := runProgramErr(t, m, testConfig(), true, t.TempDir()); err == nil {
+			t.Errorf("expected %s -> %s to be refused", current, workload.K8S_VERSION)
+		}
+		if len(m.byType("eks:index:Cluster")) != 0 {
+			t.Errorf("expected no resources for %s -> %s", current, workload.K8S_VERSION)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// Package provider serves the workload components to Pulumi programs written in other languages.
+// The schema in schema.json describes the package, the SDKs generated from it call Construct here.
+package provider
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	pulumiprovider "github.com/pulumi/pulumi/sdk/v3/go/pulumi/provider"
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"infra/workload"
+)
+
+const PROVIDER_NAME = "xbeam"
+
+// Version is set at build time with -ldflags "-X infra/provider.Version=..."
+var Version = "0.1.0"
+
+//go:embed schema.json
+var schema string
+
+type xbeamProvider struct {
+	pulumirpc.UnimplementedResourceProviderServer
+
+	engine *grpc.ClientConn
+}
+
+// New returns the provider server, engine is the connection to the Pulumi engine that launched the plugin.
+func New(engine *grpc.ClientConn) pulumirpc.ResourceProviderServer {
+	return &xbeamProvider{engine: engine}
+}
+
+func (p *xbeamProvider) GetSchema(ctx context.Context, req *pulumirpc.GetSchemaRequest) (*pulumirpc.GetSchemaResponse, error) {
+	if req.GetVersion() != 0 {
+		return nil, fmt.Errorf("unsupported schema version %d", req.GetVersion())
+	}
+	return &pulumirpc.GetSchemaResponse{Schema: schema}, nil
+}
+
+func (p *xbeamProvider) GetPluginInfo(ctx context.Context, req *emptypb.Empty) (*pulumirpc.PluginInfo, error) {
+	return &pulumirpc.PluginInfo{Version: Version}, nil
+}
+
+// CheckConfig accepts any configuration, the package has no provider level settings.
+func (p *xbeamProvider) CheckConfig(ctx context.Context, req *pulumirpc.CheckRequest) (*pulumirpc.CheckResponse, error) {
+	return &pulumirpc.CheckResponse{Inputs: req.GetNews()}, nil
+}
+
+func (p *xbeamProvider) DiffConfig(ctx context.Context, req *pulumirpc.DiffRequest) (*pulumirpc.DiffResponse, error) {
+	return &pulumirpc.DiffResponse{}, nil
+}
+
+func (p *xbeamProvider) Configure(ctx context.Context, req *pulumirpc.ConfigureRequest) (*pulumirpc.ConfigureResponse, error) {
+	return &pulumirpc.ConfigureResponse{
+		AcceptSecrets:   true,
+		SupportsPreview: true,
+		AcceptResources: true,
+		AcceptOutputs:   true,
+	}, nil
+}
+
+func (p *xbeamProvider) Construct(ctx context.Context, req *pulumirpc.ConstructRequest) (*pulumirpc.ConstructResponse, error) {
+	return pulumiprovider.Construct(ctx, req, p.engine, construct)
+}
+
+func (p *xbeamProvider) Cancel(ctx context.Context, req *emptypb.Empty) (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, nil
+}
+
+func construct(ctx *pulumi.Context, typ string, name string, inputs pulumiprovider.ConstructInputs, options pulumi.ResourceOption) (*pulumiprovider.ConstructResult, error) {
+	if typ != workload.TYPE_WORKLOAD_CLUSTER {
+		return nil, fmt.Errorf("unknown resource type %s", typ)
+	}
+	args := &WorkloadClusterArgs{}
+	if err := inputs.CopyTo(args); err != nil {
+		return nil, fmt.Errorf("setting args: %w", err)
+	}
+	return constructWorkloadCluster(ctx, name, args, options)
+}
@@ -0,0 +1,124 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+
+	"infra/workload"
+)
+
+type packageSchema struct {
+	Name      string `json:"name"`
+	Resources map[string]struct {
+		IsComponent     bool                       `json:"isComponent"`
+		InputProperties map[string]json.RawMessage `json:"inputProperties"`
+		RequiredInputs  []string                   `json:"requiredInputs"`
+	} `json:"resources"`
+}
+
+func TestSchemaMatchesArgs(t *testing.T) {
+	var spec packageSchema
+	if err := json.Unmarshal([]byte(schema), &spec); err != nil {
+		t.Fatalf("schema.json is not valid JSON: %v", err)
+	}
+	if spec.Name != PROVIDER_NAME {
+		t.Errorf("expected package %s, got %s", PROVIDER_NAME, spec.Name)
+	}
+	resource, ok := spec.Resources[workload.TYPE_WORKLOAD_CLUSTER]
+	if !ok || !resource.IsComponent {
+		t.Fatalf("schema has no %s component", workload.TYPE_WORKLOAD_CLUSTER)
+	}
+	tags := []string{}
+	argsType := reflect.TypeOf(WorkloadClusterArgs{})
+	for i := 0; i < argsType.NumField(); i++ {
+		tags = append(tags, argsType.Field(i).Tag.Get("pulumi"))
+	}
+	inputs := []string{}
+	for input := range resource.InputProperties {
+		inputs = append(inputs, input)
+	}
+	sort.Strings(tags)
+	sort.Strings(inputs)
+	if !reflect.DeepEqual(tags, inputs) {
+		t.Errorf("WorkloadClusterArgs fields %v do not match the schema inputs %v", tags, inputs)
+	}
+	for _, required := range resource.RequiredInputs {
+		if _, ok := resource.InputProperties[required]; !ok {
+			t.Errorf("required input %s is not an input property", required)
+		}
+	}
+}
+
+func TestGetSchema(t *testing.T) {
+	p := New(nil)
+	response, err := p.GetSchema(context.Background(), &pulumirpc.GetSchemaRequest{})
+	if err != nil || response.GetSchema() != schema {
+		t.Errorf("expected the embedded schema, got error %v", err)
+	}
+	if _, err := p.GetSchema(context.Background(), &pulumirpc.GetSchemaRequest{Version: 1}); err == nil {
+		t.Error("schema version 1 must be rejected")
+	}
+}
+
+func TestSettings(t *testing.T) {
+	valid := func() *WorkloadClusterArgs {
+		return &WorkloadClusterArgs{
+			AccountId:           "123456789012",
+			AdminUsername:       "admin",
+			LinuxInstanceType:   "g4dn.xlarge",
+			WindowsInstanceType: "g4dn.2xlarge",
+			WindowsPassword:     pulumi.String("secret"),
+		}
+	}
+	settings, err := valid().settings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.version != workload.K8S_VERSION || settings.maxUnavailable != 1 {
+		t.Errorf("unexpected defaults %+v", settings)
+	}
+	if settings.endpointAccess.Mode != workload.ENDPOINT_ACCESS_PUBLIC || settings.endpointAccess.TunnelPort != workload.DEFAULT_API_TUNNEL_PORT {
+		t.Errorf("unexpected endpoint defaults %+v", settings.endpointAccess)
+	}
+	if settings.logging.RetentionDays != workload.DEFAULT_LOG_RETENTION_DAYS {
+		t.Errorf("expected %d days of log retention, got %d", workload.DEFAULT_LOG_RETENTION_DAYS, settings.logging.RetentionDays)
+	}
+
+	forever := 0
+	args := valid()
+	args.LogRetentionDays = &forever
+	settings, err = args.settings()
+	if err != nil || settings.logging.RetentionDays != 0 {
+		t.Errorf("a retention of 0 must keep logs forever, got %v", err)
+	}
+
+	invalid := map[string]func(*WorkloadClusterArgs){
+		"missing account":      func(a *WorkloadClusterArgs) { a.AccountId = "" },
+		"missing password":     func(a *WorkloadClusterArgs) { a.WindowsPassword = nil },
+		"negative unavailable": func(a *WorkloadClusterArgs) { a.MaxUnavailable = -1 },
+		"unknown endpoint":     func(a *WorkloadClusterArgs) { a.EndpointAccess = "internal" },
+		"private with cidrs": func(a *WorkloadClusterArgs) {
+			a.EndpointAccess = workload.ENDPOINT_ACCESS_PRIVATE
+			a.PublicAccessCidrs = []string{"10.0.0.0/8"}
+		},
+		"unknown log type": func(a *WorkloadClusterArgs) { a.LogTypes = []string{"kubelet"} },
+		"both keys": func(a *WorkloadClusterArgs) {
+			a.CreateKmsKey = true
+			a.KmsKeyArn = "arn:aws:kms:us-east-1:123456789012:key/abc"
+		},
+		"invalid addons": func(a *WorkloadClusterArgs) { a.Addons = "[" },
+	}
+	for name, mutate := range invalid {
+		args := valid()
+		mutate(args)
+		if _, err := args.settings(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package provider
+
+import (
+	"errors"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	pulumiprovider "github.com/pulumi/pulumi/sdk/v3/go/pulumi/provider"
+
+	"infra/workload"
+)
+
+// WorkloadClusterArgs are the inputs of xbeam:index:WorkloadCluster, the tags match schema.json.
+type WorkloadClusterArgs struct {
+	AccountId         string   `pulumi:"accountId"`
+	AdminUsername     string   `pulumi:"adminUsername"`
+	KubernetesVersion string   `pulumi:"kubernetesVersion"`
+	EndpointAccess    string   `pulumi:"endpointAccess"`
+	PublicAccessCidrs []string `pulumi:"publicAccessCidrs"`
+	ApiTunnelPort     int      `pulumi:"apiTunnelPort"`
+	CreateKmsKey      bool     `pulumi:"createKmsKey"`
+	KmsKeyArn         string   `pulumi:"kmsKeyArn"`
+	LogTypes          []string `pulumi:"logTypes"`
+	// LogRetentionDays is a pointer because 0 keeps logs forever
+	LogRetentionDays *int   `pulumi:"logRetentionDays"`
+	Addons           string `pulumi:"addons"`
+	MaxUnavailable   int    `pulumi:"maxUnavailable"`
+
+	LinuxInstanceType    string `pulumi:"linuxInstanceType"`
+	LinuxDesiredCapacity int    `pulumi:"linuxDesiredCapacity"`
+	LinuxMinSize         int    `pulumi:"linuxMinSize"`
+	LinuxMaxSize         int    `pulumi:"linuxMaxSize"`
+
+	WindowsInstanceType    string             `pulumi:"windowsInstanceType"`
+	WindowsDesiredCapacity int                `pulumi:"windowsDesiredCapacity"`
+	WindowsMinSize         int                `pulumi:"windowsMinSize"`
+	WindowsMaxSize         int                `pulumi:"windowsMaxSize"`
+	WindowsImageId         string             `pulumi:"windowsImageId"`
+	WindowsPassword        pulumi.StringInput `pulumi:"windowsPassword"`
+}
+
+// clusterSettings is the workload configuration the stack program would load from config.
+type clusterSettings struct {
+	version        string
+	endpointAccess *workload.EndpointAccess
+	logging        workload.LoggingConfig
+	encryption     workload.ClusterEncryption
+	addons         map[string]workload.AddonConfig
+	maxUnavailable int
+}
+
+// settings validates the inputs and applies the same defaults as the stack config.
+func (args *WorkloadClusterArgs) settings() (*clusterSettings, error) {
+	if args.AccountId == "" {
+		return nil, errors.New("accountId is required")
+	}
+	if args.AdminUsername == "" {
+		return nil, errors.New("adminUsername is required")
+	}
+	if args.LinuxInstanceType == "" {
+		return nil, errors.New("linuxInstanceType is required")
+	}
+	if args.WindowsInstanceType == "" {
+		return nil, errors.New("windowsInstanceType is required")
+	}
+	if args.WindowsPassword == nil {
+		return nil, errors.New("windowsPassword is required")
+	}
+	settings := &clusterSettings{
+		version:        args.KubernetesVersion,
+		maxUnavailable: args.MaxUnavailable,
+	}
+	if settings.version == "" {
+		settings.version = workload.K8S_VERSION
+	}
+	if settings.maxUnavailable == 0 {
+		settings.maxUnavailable = 1
+	}
+	if settings.maxUnavailable < 1 {
+		return nil, errors.New("maxUnavailable must be a positive integer")
+	}
+	var err error
+	settings.endpointAccess, err = workload.NewEndpointAccess(args.EndpointAccess, args.PublicAccessCidrs, args.ApiTunnelPort)
+	if err != nil {
+		return nil, err
+	}
+	retention := workload.DEFAULT_LOG_RETENTION_DAYS
+	if args.LogRetentionDays != nil {
+		retention = *args.LogRetentionDays
+	}
+	settings.logging, err = workload.NewLoggingConfig(args.LogTypes, retention, "", "")
+	if err != nil {
+		return nil, err
+	}
+	settings.encryption, err = workload.NewClusterEncryption(args.KmsKeyArn, args.CreateKmsKey)
+	if err != nil {
+		return nil, err
+	}
+	settings.addons, err = workload.ParseAddonConfigs(args.Addons)
+	if err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// constructWorkloadCluster builds the same resources as the stack program. The returned resource is the
+// cluster component, the node pools and the autoscaler are its children and the network is created next to it.
+func constructWorkloadCluster(ctx *pulumi.Context, name string, args *WorkloadClusterArgs, options pulumi.ResourceOption) (*pulumiprovider.ConstructResult, error) {
+	settings, err := args.settings()
+	if err != nil {
+		return nil, err
+	}
+	region, ok := ctx.GetConfig("aws:region")
+	if !ok {
+		return nil, errors.New("aws:region is required")
+	}
+	partition, err := workload.LookupPartition(ctx)
+	if err != nil {
+		return nil, err
+	}
+	deployment := workload.NewDeployment(ctx.Stack()+"-"+name, region, args.AccountId, partition)
+	imageId := args.WindowsImageId
+	if imageId == "" {
+		ami, err := workload.LookupAMI(ctx, "", settings.version)
+		if err != nil {
+			return nil, err
+		}
+		imageId = ami.ImageId
+	}
+
+	network, err := workload.NewWorkloadNetwork(ctx, name+"-network", &workload.WorkloadNetworkArgs{
+		Deployment: deployment,
+	}, options)
+	if err != nil {
+		return nil, err
+	}
+	cluster, err := workload.NewWorkloadCluster(ctx, name, &workload.WorkloadClusterArgs{
+		Deployment:            deployment,
+		Network:               network,
+		Version:               settings.version,
+		AdminUsername:         args.AdminUsername,
+		EndpointAccess:        settings.endpointAccess,
+		Logging:               settings.logging,
+		Encryption:            settings.encryption,
+		Addons:                settings.addons,
+		SystemMetadataOptions: workload.DefaultMetadataOptions(),
+		MaxUnavailable:        settings.maxUnavailable,
+	}, options)
+	if err != nil {
+		return nil, err
+	}
+	linuxPool, err := workload.NewGpuNodePool(ctx, name+"-linux-gpu-pool", &workload.GpuNodePoolArgs{
+		Deployment:      deployment,
+		Network:         network,
+		Cluster:         cluster,
+		Pool:            workload.POOL_LINUX,
+		InstanceType:    args.LinuxInstanceType,
+		DesiredCapacity: args.LinuxDesiredCapacity,
+		MinSize:         args.LinuxMinSize,
+		MaxSize:         args.LinuxMaxSize,
+		MaxUnavailable:  settings.maxUnavailable,
+		MetadataOptions: workload.DefaultMetadataOptions(),
+	}, pulumi.Parent(cluster))
+	if err != nil {
+		return nil, err
+	}
+	windowsPool, err := workload.NewGpuNodePool(ctx, name+"-windows-gpu-pool", &workload.GpuNodePoolArgs{
+		Deployment:      deployment,
+		Network:         network,
+		Cluster:         cluster,
+		Pool:            workload.POOL_WINDOWS,
+		InstanceType:    args.WindowsInstanceType,
+		DesiredCapacity: args.WindowsDesiredCapacity,
+		MinSize:         args.WindowsMinSize,
+		MaxSize:         args.WindowsMaxSize,
+		MaxUnavailable:  settings.maxUnavailable,
+		MetadataOptions: workload.DefaultMetadataOptions(),
+		ImageId:         imageId,
+		WindowsPassword: args.WindowsPassword,
+	}, pulumi.Parent(cluster))
+	if err != nil {
+		return nil, err
+	}
+	_, err = workload.NewClusterAutoscaler(ctx, name+"-cluster-autoscaler", &workload.ClusterAutoscalerArgs{
+		Deployment: deployment,
+		Cluster:    cluster,
+	}, pulumi.Parent(cluster))
+	if err != nil {
+		return nil, err
+	}
+	if err := deployment.Names.Duplicates(); err != nil {
+		return nil, err
+	}
+
+	state := pulumi.Map{
+		"clusterName":           pulumi.String(cluster.Name),
+		"kubeconfig":            pulumi.ToSecret(cluster.Kubeconfig),
+		"vpcId":                 network.Vpc.ID(),
+		"publicSubnetIds":       subnetIds(network.PublicSubnets),
+		"privateSubnetIds":      subnetIds(network.PrivateSubnets),
+		"workerSecurityGroupId": network.WorkerSecurityGroup.ID(),
+		"systemNodeGroup":       cluster.SystemNodeGroup.ID(),
+		"linuxNodeGroup":        linuxPool.NodeGroup.ID(),
+		"windowsNodeGroup":      windowsPool.NodeGroup.ID(),
+		"addons":                cluster.AddonVersions,
+	}
+	if cluster.ApiJumpHost != nil {
+		state["apiTunnelCommand"] = cluster.ApiJumpHost.TunnelCommand
+	}
+	return &pulumiprovider.ConstructResult{
+		URN:   cluster.URN(),
+		State: state,
+	}, nil
+}
+
+func subnetIds(subnets []*ec2.Subnet) pulumi.StringArray {
+	ids := pulumi.StringArray{}
+	for _, subnet := range subnets {
+		ids = append(ids, subnet.ID())
+	}
+	return ids
+}
@@ -0,0 +1,193 @@
+package workload
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	awsEKS "github.com/pulumi/pulumi-aws/sdk/v6/go/aws/eks"
+	"github.com/pulumi/pulumi-eks/sdk/v2/go/eks"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// AddonConfig is the per add-on entry of the eks:addons config object.
+type AddonConfig struct {
+	Enabled                  *bool           `json:"enabled"`
+	Version                  string          `json:"version"`
+	ResolveConflictsOnCreate string          `json:"resolveConflictsOnCreate"`
+	ResolveConflictsOnUpdate string          `json:"resolveConflictsOnUpdate"`
+	ConfigurationValues      json.RawMessage `json:"configurationValues"`
+}
+
+type addonDefinition struct {
+	Name    string
+	Enabled bool
+	// NeedsNodes add-ons run as deployments and stay DEGRADED until a node group is ready
+	NeedsNodes bool
+	// ServiceAccount and ManagedPolicies describe the IRSA role the add-on needs, if any
+	Namespace       string
+	ServiceAccount  string
+	ManagedPolicies []string
+}
+
+var addonDefinitions = []addonDefinition{
+	{Name: "vpc-cni", Enabled: true},
+	{Name: "kube-proxy", Enabled: true},
+	{Name: "coredns", Enabled: true, NeedsNodes: true},
+	{Name: "eks-pod-identity-agent", Enabled: true},
+	{
+		Name:            "aws-ebs-csi-driver",
+		Enabled:         true,
+		NeedsNodes:      true,
+		Namespace:       "kube-system",
+		ServiceAccount:  "ebs-csi-controller-sa",
+		ManagedPolicies: []string{"service-role/AmazonEBSCSIDriverPolicy"},
+	},
+	{
+		Name:            "amazon-cloudwatch-observability",
+		Enabled:         false,
+		NeedsNodes:      true,
+		Namespace:       "amazon-cloudwatch",
+		ServiceAccount:  "cloudwatch-agent",
+		ManagedPolicies: []string{"CloudWatchAgentServerPolicy", "AWSXrayWriteOnlyAccess"},
+	},
+}
+
+// ParseAddonConfigs decodes an eks:addons JSON object.
+func ParseAddonConfigs(raw string) (map[string]AddonConfig, error) {
+	configs := map[string]AddonConfig{}
+	if raw == "" {
+		return configs, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &configs); err != nil {
+		return nil, fmt.Errorf("invalid eks:addons: %w", err)
+	}
+	for name := range configs {
+		known := false
+		for _, definition := range addonDefinitions {
+			if definition.Name == name {
+				known = true
+			}
+		}
+		if !known {
+			return nil, errors.New("unsupported add-on in eks:addons: " + name)
+		}
+	}
+	return configs, nil
+}
+
+func (c AddonConfig) enabled(definition addonDefinition) bool {
+	if c.Enabled == nil {
+		return definition.Enabled
+	}
+	return *c.Enabled
+}
+
+// configurationValues accepts the add-on configuration either as a JSON object or as an already encoded string.
+func (c AddonConfig) configurationValues() (string, error) {
+	if len(c.ConfigurationValues) == 0 || string(c.ConfigurationValues) == "null" {
+		return "", nil
+	}
+	var encoded string
+	if err := json.Unmarshal(c.ConfigurationValues, &encoded); err == nil {
+		if !json.Valid([]byte(encoded)) {
+			return "", errors.New("configurationValues is not valid JSON")
+		}
+		return encoded, nil
+	}
+	var values map[string]interface{}
+	if err := json.Unmarshal(c.ConfigurationValues, &values); err != nil {
+		return "", errors.New("configurationValues must be a JSON object")
+	}
+	bytes, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+// resolveAddonVersion returns the pinned version, or the default version EKS picks for the cluster version.
+func resolveAddonVersion(ctx *pulumi.Context, name string, k8sVersion string, pinned string) (string, error) {
+	if pinned != "" {
+		return pinned, nil
+	}
+	version, err := awsEKS.GetAddonVersion(ctx, &awsEKS.GetAddonVersionArgs{
+		AddonName:         name,
+		KubernetesVersion: k8sVersion,
+	})
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve %s version for kubernetes %s: %w", name, k8sVersion, err)
+	}
+	return version.Version, nil
+}
+
+// LoadAddonConfigs reads the eks:addons config object.
+func LoadAddonConfigs(ctx *pulumi.Context) (map[string]AddonConfig, error) {
+	raw, _ := ctx.GetConfig("eks:addons")
+	return ParseAddonConfigs(raw)
+}
+
+func createAddons(ctx *pulumi.Context, deployment *Deployment, cluster *eks.Cluster, nodeGroup pulumi.Resource, k8sVersion string, configs map[string]AddonConfig, opts ...pulumi.ResourceOption) (map[string]*awsEKS.Addon, error) {
+	addons := map[string]*awsEKS.Addon{}
+	for _, definition := range addonDefinitions {
+		config := configs[definition.Name]
+		if !config.enabled(definition) {
+			continue
+		}
+		version, err := resolveAddonVersion(ctx, definition.Name, k8sVersion, config.Version)
+		if err != nil {
+			return nil, err
+		}
+		configurationValues, err := config.configurationValues()
+		if err != nil {
+			return nil, fmt.Errorf("eks:addons %s: %w", definition.Name, err)
+		}
+		resolveConflictsOnCreate := config.ResolveConflictsOnCreate
+		if resolveConflictsOnCreate == "" {
+			resolveConflictsOnCreate = "OVERWRITE"
+		}
+		resolveConflictsOnUpdate := config.ResolveConflictsOnUpdate
+		if resolveConflictsOnUpdate == "" {
+			resolveConflictsOnUpdate = "PRESERVE"
+		}
+		dependencies := []pulumi.Resource{cluster}
+		if definition.NeedsNodes {
+			dependencies = append(dependencies, nodeGroup)
+		}
+		args := &awsEKS.AddonArgs{
+			AddonName:                pulumi.String(definition.Name),
+			AddonVersion:             pulumi.String(version),
+			ClusterName:              cluster.EksCluster.Name(),
+			ResolveConflictsOnCreate: pulumi.String(resolveConflictsOnCreate),
+			ResolveConflictsOnUpdate: pulumi.String(resolveConflictsOnUpdate),
+			Tags:                     deployment.tags(nil),
+		}
+		if configurationValues != "" {
+			args.ConfigurationValues = pulumi.String(configurationValues)
+		}
+		if definition.ServiceAccount != "" {
+			role, err := createServiceAccountRole(ctx, deployment, deployment.name("AddonRole", definition.Name), cluster,
+				definition.Namespace, definition.ServiceAccount, deployment.Partition.managedPolicyArns(definition.ManagedPolicies...),
+				childOptions(opts, pulumi.DependsOn([]pulumi.Resource{cluster}))...)
+			if err != nil {
+				return nil, err
+			}
+			args.ServiceAccountRoleArn = role.Arn
+			dependencies = append(dependencies, role)
+		}
+		addon, err := awsEKS.NewAddon(ctx, deployment.name("Addon", definition.Name, "WorkloadCluster"), args, childOptions(opts, pulumi.DependsOn(dependencies))...)
+		if err != nil {
+			return nil, err
+		}
+		addons[definition.Name] = addon
+	}
+	return addons, nil
+}
+
+func addonVersions(addons map[string]*awsEKS.Addon) pulumi.StringMap {
+	versions := pulumi.StringMap{}
+	for name, addon := range addons {
+		versions[name] = addon.AddonVersion
+	}
+	return versions
+}
@@ -0,0 +1,52 @@
+package workload
+
+import "testing"
+
+func TestParseAddonConfigs(t *testing.T) {
+	configs, err := ParseAddonConfigs(`{"coredns":{"version":"v1.11.1-eksbuild.4","configurationValues":{"replicaCount":3}},"amazon-cloudwatch-observability":{"enabled":true}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if configs["coredns"].Version != "v1.11.1-eksbuild.4" {
+		t.Errorf("unexpected coredns version %q", configs["coredns"].Version)
+	}
+	values, err := configs["coredns"].configurationValues()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values != `{"replicaCount":3}` {
+		t.Errorf("unexpected configuration values %s", values)
+	}
+	for _, definition := range addonDefinitions {
+		if !configs[definition.Name].enabled(definition) {
+			t.Errorf("expected %s to be enabled", definition.Name)
+		}
+	}
+}
+
+func TestParseAddonConfigsRejectsUnknownAddon(t *testing.T) {
+	if _, err := ParseAddonConfigs(`{"not-an-addon":{}}`); err == nil {
+		t.Error("expected error for unknown add-on")
+	}
+}
+
+func TestAddonConfigurationValuesEncodedString(t *testing.T) {
+	configs, err := ParseAddonConfigs(`{"vpc-cni":{"configurationValues":"{\"env\":{\"ENABLE_PREFIX_DELEGATION\":\"true\"}}"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := configs["vpc-cni"].configurationValues()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values != `{"env":{"ENABLE_PREFIX_DELEGATION":"true"}}` {
+		t.Errorf("unexpected configuration values %s", values)
+	}
+	configs, err = ParseAddonConfigs(`{"vpc-cni":{"configurationValues":"not json"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := configs["vpc-cni"].configurationValues(); err == nil {
+		t.Error("expected error for invalid configuration values")
+	}
+}
@@ -0,0 +1,45 @@
+package workload
+
+import (
+	"strings"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// XBEAM_WINDOWS_PRODUCT_CODE is the marketplace product of the Windows GPU worker image
+const XBEAM_WINDOWS_PRODUCT_CODE = "6c2ls17bo706uvbzvvx39aimt"
+
+// WINDOWS_AMI_VERSION_PLACEHOLDER is replaced with the Kubernetes version in worker:windowsAmiNamePattern
+const WINDOWS_AMI_VERSION_PLACEHOLDER = "{version}"
+
+// WindowsAmiFilters selects the Windows GPU worker images of the marketplace product. When namePattern is set, only
+// the images whose name matches it, with WINDOWS_AMI_VERSION_PLACEHOLDER replaced by k8sVersion. The preflight checks
+// look the image up with the same filters.
+func WindowsAmiFilters(namePattern string, k8sVersion string) []ec2.GetAmiFilter {
+	filters := []ec2.GetAmiFilter{
+		{
+			Name: "product-code",
+			Values: []string{
+				XBEAM_WINDOWS_PRODUCT_CODE,
+			},
+		},
+	}
+	if namePattern != "" {
+		filters = append(filters, ec2.GetAmiFilter{
+			Name:   "name",
+			Values: []string{strings.ReplaceAll(namePattern, WINDOWS_AMI_VERSION_PLACEHOLDER, k8sVersion)},
+		})
+	}
+	return filters
+}
+
+// LookupAMI finds the latest Windows GPU worker image, of the Kubernetes version when namePattern matches it.
+func LookupAMI(ctx *pulumi.Context, namePattern string, k8sVersion string) (*ec2.LookupAmiResult, error) {
+	mostRecent := true
+	ami, err := ec2.LookupAmi(ctx, &ec2.LookupAmiArgs{
+		MostRecent: &mostRecent,
+		Filters:    WindowsAmiFilters(namePattern, k8sVersion),
+	})
+	return ami, err
+}
@@ -0,0 +1,124 @@
+package workload
+
+import (
+	"errors"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/helm/v3"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const CLUSTER_AUTOSCALER_CHART_VERSION = "9.36.0"
+
+type ClusterAutoscalerArgs struct {
+	Deployment *Deployment
+	Cluster    *WorkloadCluster
+}
+
+// ClusterAutoscaler runs the Kubernetes cluster autoscaler with an IAM role to scale the node groups.
+type ClusterAutoscaler struct {
+	pulumi.ResourceState
+
+	Policy  *iam.Policy
+	Role    *iam.Role
+	Release *helm.Release
+}
+
+func NewClusterAutoscaler(ctx *pulumi.Context, name string, args *ClusterAutoscalerArgs, opts ...pulumi.ResourceOption) (*ClusterAutoscaler, error) {
+	if args == nil || args.Deployment == nil || args.Cluster == nil {
+		return nil, errors.New("ClusterAutoscaler requires a Deployment and a Cluster")
+	}
+	deployment := args.Deployment
+	cluster := args.Cluster
+	component := &ClusterAutoscaler{}
+	err := ctx.RegisterComponentResource(TYPE_CLUSTER_AUTOSCALER, name, component, opts...)
+	if err != nil {
+		return nil, err
+	}
+	childOpts := componentChildOptions(component)
+	clusterAutoscalerPolicyJSON, err := clusterAutoscalerPolicyDocument(deployment.Partition, deployment.Region, deployment.AccountId).JSON()
+	if err != nil {
+		return nil, err
+	}
+	component.Policy, err = iam.NewPolicy(ctx, deployment.name("AutoScalerPolicy", "WorkloadCluster"), &iam.PolicyArgs{
+		Description: pulumi.String("Allows the cluster autoscaler to access AWS resources"),
+		Name:        pulumi.String(deployment.resourceName(KIND_IAM_POLICY, "AutoScalerPolicy", "WorkloadCluster")),
+		Policy:      pulumi.String(clusterAutoscalerPolicyJSON),
+		Tags:        deployment.tags(nil),
+	}, childOptions(childOpts, pulumi.DependsOn([]pulumi.Resource{cluster.Cluster}))...)
+	if err != nil {
+		return nil, err
+	}
+	// Create Role for Cluster Autoscaler, only its service account can assume it
+	component.Role, err = createServiceAccountRole(ctx, deployment, deployment.name("AutoScalerRole", "WorkloadCluster"), cluster.Cluster,
+		"kube-system", "cluster-autoscaler", nil,
+		childOptions(childOpts, pulumi.DependsOn([]pulumi.Resource{cluster.Cluster, component.Policy}))...)
+	if err != nil {
+		return nil, err
+	}
+	policyAttachment, err := iam.NewPolicyAttachment(ctx, deployment.name("AutoScalerPolicyAttachment", "WorkloadCluster"), &iam.PolicyAttachmentArgs{
+		PolicyArn: component.Policy.Arn,
+		Roles:     pulumi.Array{component.Role.Name},
+	}, childOptions(childOpts, pulumi.DependsOn([]pulumi.Resource{component.Policy, component.Role}))...)
+	if err != nil {
+		return nil, err
+	}
+	serviceAccount, err := corev1.NewServiceAccount(ctx, name, &corev1.ServiceAccountArgs{
+		Metadata: metav1.ObjectMetaArgs{
+			Name:      pulumi.String("cluster-autoscaler"),
+			Namespace: pulumi.String("kube-system"),
+			Labels: pulumi.StringMap{
+				"app.kubernetes.io/name": pulumi.String("cluster-autoscaler"),
+			},
+			Annotations: pulumi.StringMap{
+				"eks.amazonaws.com/role-arn":               component.Role.Arn,
+				"eks.amazonaws.com/sts-regional-endpoints": pulumi.String("true"),
+			},
+		},
+	}, childOptions(childOpts, pulumi.Provider(cluster.Provider))...)
+	if err != nil {
+		return nil, err
+	}
+	// Create Cluster AutoScaler
+	component.Release, err = helm.NewRelease(ctx, name, &helm.ReleaseArgs{
+		Namespace: pulumi.String("kube-system"),
+		Name:      pulumi.String("cluster-autoscaler"),
+		RepositoryOpts: helm.RepositoryOptsArgs{
+			Repo: pulumi.String("https://kubernetes.github.io/autoscaler"),
+		},
+		Chart:   pulumi.String("cluster-autoscaler"),
+		Version: pulumi.String(CLUSTER_AUTOSCALER_CHART_VERSION),
+		Values: pulumi.Map{
+			"cloudProvider": pulumi.String("aws"),
+			"awsRegion":     pulumi.String(deployment.Region),
+			// The global STS endpoint only serves the commercial partition
+			"extraEnv": pulumi.Map{
+				"AWS_STS_REGIONAL_ENDPOINTS": pulumi.String("regional"),
+			},
+			"autoDiscovery": pulumi.Map{
+				"clusterName": cluster.Cluster.EksCluster.Name(),
+			},
+			"rbac": pulumi.Map{
+				"create": pulumi.Bool(true),
+				"serviceAccount": pulumi.Map{
+					"name":   pulumi.String("cluster-autoscaler"),
+					"create": pulumi.Bool(false),
+				},
+			},
+		},
+		WaitForJobs: pulumi.Bool(true),
+	}, childOptions(childOpts, pulumi.Provider(cluster.Provider), pulumi.DependsOn([]pulumi.Resource{cluster.Cluster, serviceAccount, policyAttachment}))...)
+	if err != nil {
+		return nil, err
+	}
+	err = ctx.RegisterResourceOutputs(component, pulumi.Map{
+		"policyArn": component.Policy.Arn,
+		"roleArn":   component.Role.Arn,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return component, nil
+}
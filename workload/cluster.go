@@ -0,0 +1,323 @@
+package workload
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
+	awsEKS "github.com/pulumi/pulumi-aws/sdk/v6/go/aws/eks"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
+	"github.com/pulumi/pulumi-eks/sdk/v2/go/eks"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// The system pool runs cluster services on small general purpose instances
+const (
+	SYSTEM_INSTANCE_TYPE = "t3.medium"
+	SYSTEM_POOL_MAX_SIZE = 3
+)
+
+type WorkloadClusterArgs struct {
+	Deployment *Deployment
+	Network    *WorkloadNetwork
+	// Version is the Kubernetes version of the control plane and the system pool
+	Version        string
+	AdminUsername  string
+	EndpointAccess *EndpointAccess
+	Logging        LoggingConfig
+	Encryption     ClusterEncryption
+	Addons         map[string]AddonConfig
+	// SystemReleaseVersion pins the system pool AMI release, nil keeps the current one
+	SystemReleaseVersion  pulumi.StringPtrInput
+	SystemMetadataOptions MetadataOptions
+	MaxUnavailable        int
+	// Tenants get their IAM role mapped to their group, NewTenant creates their namespaces
+	Tenants []TenantConfig
+}
+
+// WorkloadCluster is the EKS control plane with its roles, encryption, logging, add-ons and the system node pool
+// that runs cluster services. The worker roles are created here because the aws-auth mapping needs them.
+type WorkloadCluster struct {
+	pulumi.ResourceState
+
+	// Name is the physical EKS cluster name
+	Name    string
+	Version string
+	Cluster *eks.Cluster
+
+	ClusterRole       *iam.Role
+	SystemRole        *iam.Role
+	LinuxWorkerRole   *iam.Role
+	WindowsWorkerRole *iam.Role
+	// KeyArn is the customer managed key of secrets and worker volumes, nil for AWS managed keys
+	KeyArn  pulumi.StringPtrInput
+	Logging *ControlPlaneLogging
+
+	SystemNodeGroup *awsEKS.NodeGroup
+	Addons          map[string]*awsEKS.Addon
+	AddonVersions   pulumi.StringMap
+
+	Kubeconfig pulumi.StringOutput
+	// Provider deploys to the cluster, through the SSM tunnel when the endpoint is private only
+	Provider *kubernetes.Provider
+	// TunnelPort is the local port of the SSM tunnel kubeconfigs reach a private only endpoint through, 0 otherwise
+	TunnelPort  int
+	KubeDns     *corev1.Service
+	ApiJumpHost *ApiJumpHost
+	// SecurityGroupRules let the worker, node and cluster security groups reach each other
+	SecurityGroupRules pulumi.IDArrayOutput
+}
+
+func NewWorkloadCluster(ctx *pulumi.Context, name string, args *WorkloadClusterArgs, opts ...pulumi.ResourceOption) (*WorkloadCluster, error) {
+	if args == nil || args.Deployment == nil || args.Network == nil || args.EndpointAccess == nil {
+		return nil, errors.New("WorkloadCluster requires a Deployment, a Network and an EndpointAccess")
+	}
+	if _, _, err := parseKubernetesVersion(args.Version); err != nil {
+		return nil, err
+	}
+	deployment := args.Deployment
+	network := args.Network
+	component := &WorkloadCluster{
+		Name:    deployment.ClusterName(),
+		Version: args.Version,
+	}
+	err := ctx.RegisterComponentResource(TYPE_WORKLOAD_CLUSTER, name, component, opts...)
+	if err != nil {
+		return nil, err
+	}
+	childOpts := componentChildOptions(component)
+
+	component.ClusterRole, err = createClusterRole(ctx, deployment, deployment.name("ClusterRole"), childOpts...)
+	if err != nil {
+		return nil, err
+	}
+	component.SystemRole, err = createWorkerRole(ctx, deployment, deployment.name("SystemRole", "WorkloadCluster"), childOpts...)
+	if err != nil {
+		return nil, err
+	}
+	component.WindowsWorkerRole, err = createWorkerRole(ctx, deployment, deployment.name("WindowsWorkerRole", "WorkloadCluster"), childOpts...)
+	if err != nil {
+		return nil, err
+	}
+	component.LinuxWorkerRole, err = createWorkerRole(ctx, deployment, deployment.name("LinuxWorkerRole", "WorkloadCluster"), childOpts...)
+	if err != nil {
+		return nil, err
+	}
+	clusterInstanceProfile, err := iam.NewInstanceProfile(ctx, deployment.name("ClusterInstanceProfile"), &iam.InstanceProfileArgs{
+		Role: component.ClusterRole.Name,
+		Tags: deployment.tags(nil),
+	}, childOptions(childOpts, pulumi.DependsOn([]pulumi.Resource{component.ClusterRole}))...)
+	if err != nil {
+		return nil, err
+	}
+	component.KeyArn, err = setupClusterKey(ctx, deployment, args.Encryption,
+		[]*iam.Role{component.ClusterRole, component.SystemRole, component.LinuxWorkerRole, component.WindowsWorkerRole}, childOpts...)
+	if err != nil {
+		return nil, err
+	}
+	component.Logging, err = setupControlPlaneLogging(ctx, deployment, component.Name, args.Logging, childOpts...)
+	if err != nil {
+		return nil, err
+	}
+	falsePtr := new(bool)
+	truePtr := new(bool)
+	*truePtr = true
+	component.Cluster, err = eks.NewCluster(ctx, deployment.name("WorkloadCluster"), &eks.ClusterArgs{
+		CreateOidcProvider: pulumi.BoolPtr(true),
+		InstanceRoles: iam.RoleArray{
+			component.LinuxWorkerRole,
+			component.WindowsWorkerRole,
+			component.SystemRole,
+		},
+		Name:                         pulumi.String(component.Name),
+		EnabledClusterLogTypes:       pulumi.ToStringArray(component.Logging.LogTypes),
+		EncryptionConfigKeyArn:       component.KeyArn,
+		EndpointPrivateAccess:        pulumi.Bool(args.EndpointAccess.private()),
+		EndpointPublicAccess:         pulumi.Bool(args.EndpointAccess.public()),
+		PublicAccessCidrs:            args.EndpointAccess.publicAccessCidrs(),
+		NodeAssociatePublicIpAddress: falsePtr,
+		PrivateSubnetIds:             network.getPrivateSubnetIds(),
+		ProviderCredentialOpts:       eks.KubeconfigOptionsArgs{},
+		PublicSubnetIds:              network.getPublicSubnetIds(),
+		RoleMappings: append(eks.RoleMappingArray{
+			&eks.RoleMappingArgs{
+				Groups:   pulumi.StringArray{pulumi.String("system:bootstrappers"), pulumi.String("system:nodes"), pulumi.String("eks:kube-proxy-windows")},
+				RoleArn:  component.WindowsWorkerRole.Arn,
+				Username: pulumi.String("system:node:{{EC2PrivateDNSName}}"),
+			},
+		}, tenantRoleMappings(args.Tenants)...),
+		ServiceRole:          component.ClusterRole,
+		SkipDefaultNodeGroup: truePtr,
+		Tags: deployment.tags(pulumi.StringMap{
+			"ClusterName": pulumi.String(deployment.name("WorkloadCluster")),
+		}),
+		UseDefaultVpcCni: truePtr,
+		ClusterSecurityGroupTags: pulumi.StringMap{
+			"ClusterName": pulumi.String(deployment.name("ClusterSecurityGroup", "WorkloadCluster")),
+			"Type":        pulumi.String("ManagedSecurityGroup"),
+			"For":         pulumi.String("Cluster"),
+		},
+		NodeSecurityGroupTags: pulumi.StringMap{
+			"ClusterName": pulumi.String(deployment.name("WorkerSecurityGroup", "WorkloadCluster")),
+			"Type":        pulumi.String("ManagedSecurityGroup"),
+			"For":         pulumi.String("Node"),
+		},
+		UserMappings: eks.UserMappingArray{
+			&eks.UserMappingArgs{
+				Groups:   pulumi.StringArray{pulumi.String("system:masters")},
+				Username: pulumi.String(args.AdminUsername),
+				UserArn:  pulumi.String(deployment.Partition.iamUserArn(deployment.AccountId, args.AdminUsername)),
+			},
+		},
+		Version: pulumi.String(args.Version),
+		VpcId:   network.Vpc.ID(),
+	}, childOptions(childOpts, pulumi.DependsOn([]pulumi.Resource{network.Vpc, component.ClusterRole, clusterInstanceProfile,
+		component.SystemRole, component.LinuxWorkerRole, component.WindowsWorkerRole, component.Logging.LogGroup}))...)
+	if err != nil {
+		return nil, err
+	}
+	workerSecurityGroup := network.WorkerSecurityGroup
+	workerSecurityGroupId := network.WorkerSecurityGroup.ID()
+	nodeSecurityGroupId := securityGroupId(component.Cluster.NodeSecurityGroup)
+	clusterSecurityGroupId := securityGroupId(component.Cluster.ClusterSecurityGroup)
+	ruleIds := pulumi.IDArray{}
+	for _, rule := range []struct {
+		securityGroupId, fromSecurityGroupId pulumi.IDOutput
+		sgName, sourceName                   string
+	}{
+		{workerSecurityGroupId, nodeSecurityGroupId, "worker-sg", "node-sg"},
+		{nodeSecurityGroupId, workerSecurityGroupId, "node-sg", "worker-sg"},
+		{workerSecurityGroupId, clusterSecurityGroupId, "worker-sg", "cluster-sg"},
+		{clusterSecurityGroupId, workerSecurityGroupId, "cluster-sg", "worker-sg"},
+	} {
+		ruleId, err := allowFromSecurityGroup(ctx, deployment, rule.securityGroupId, rule.fromSecurityGroupId, rule.sgName, rule.sourceName, childOpts...)
+		if err != nil {
+			return nil, err
+		}
+		ruleIds = append(ruleIds, ruleId)
+	}
+	component.SecurityGroupRules = ruleIds.ToIDArrayOutput()
+	systemLaunchTemplate, err := createLaunchTemplate(ctx, deployment.name("SystemLaunchTemplate", "WorkloadCluster"), &ec2.LaunchTemplateArgs{
+		BlockDeviceMappings: ec2.LaunchTemplateBlockDeviceMappingArray{
+			&ec2.LaunchTemplateBlockDeviceMappingArgs{
+				DeviceName: pulumi.String("/dev/sda1"),
+				Ebs: &ec2.LaunchTemplateBlockDeviceMappingEbsArgs{
+					VolumeSize:          pulumi.Int(100),
+					VolumeType:          pulumi.String("gp3"),
+					DeleteOnTermination: pulumi.String("true"),
+					Encrypted:           pulumi.String("true"),
+					KmsKeyId:            component.KeyArn,
+				},
+			},
+		},
+		Name: pulumi.String(deployment.resourceName(KIND_LAUNCH_TEMPLATE, "SystemLaunchTemplate", "WorkloadCluster")),
+		TagSpecifications: ec2.LaunchTemplateTagSpecificationArray{
+			&ec2.LaunchTemplateTagSpecificationArgs{
+				ResourceType: pulumi.String("instance"),
+				Tags: deployment.tags(pulumi.StringMap{
+					"Name": pulumi.String(deployment.name("SystemLaunchTemplate", "WorkloadCluster")),
+				}),
+			},
+		},
+		VpcSecurityGroupIds: pulumi.StringArray{
+			workerSecurityGroup.ID(),
+		},
+		Tags: deployment.tags(nil),
+	}, args.SystemMetadataOptions, childOptions(childOpts, pulumi.DependsOn([]pulumi.Resource{workerSecurityGroup, component.Cluster}))...)
+	if err != nil {
+		return nil, err
+	}
+	component.SystemNodeGroup, err = awsEKS.NewNodeGroup(ctx, deployment.name("SystemNodeGroup", "WorkloadCluster"), &awsEKS.NodeGroupArgs{
+		NodeGroupName:  pulumi.String(deployment.resourceName(KIND_NODE_GROUP, "SystemNodeGroup", "WorkloadCluster")),
+		ClusterName:    component.Cluster.EksCluster.Name(),
+		NodeRoleArn:    component.SystemRole.Arn,
+		Version:        pulumi.String(args.Version),
+		ReleaseVersion: args.SystemReleaseVersion,
+		UpdateConfig: &awsEKS.NodeGroupUpdateConfigArgs{
+			MaxUnavailable: pulumi.Int(args.MaxUnavailable),
+		},
+		ScalingConfig: &awsEKS.NodeGroupScalingConfigArgs{
+			DesiredSize: pulumi.Int(1),
+			MaxSize:     pulumi.Int(SYSTEM_POOL_MAX_SIZE),
+			MinSize:     pulumi.Int(1),
+		},
+		SubnetIds: network.getPrivateSubnetIds(),
+		InstanceTypes: pulumi.StringArray{
+			pulumi.String(SYSTEM_INSTANCE_TYPE),
+		},
+		LaunchTemplate: &awsEKS.NodeGroupLaunchTemplateArgs{
+			Id:      systemLaunchTemplate.ID(),
+			Version: pulumi.String("$Latest"),
+		},
+		Labels: pulumi.StringMap{
+			"type": pulumi.String("system"),
+		},
+		Tags: deployment.tags(pulumi.StringMap{
+			"Name":     pulumi.String(deployment.name("SystemNodeGroup", "WorkloadCluster")),
+			"workload": pulumi.String("system"),
+		}),
+	}, childOptions(childOpts, pulumi.DependsOn([]pulumi.Resource{component.Cluster, component.SystemRole, systemLaunchTemplate}))...)
+	if err != nil {
+		return nil, err
+	}
+	component.Addons, err = createAddons(ctx, deployment, component.Cluster, component.SystemNodeGroup, args.Version, args.Addons, childOpts...)
+	if err != nil {
+		return nil, err
+	}
+	component.AddonVersions = addonVersions(component.Addons)
+	component.Kubeconfig = component.Cluster.Kubeconfig.ApplyT(func(kc interface{}) (string, error) {
+		bytes, err := json.Marshal(kc.(map[string]interface{}))
+		if err != nil {
+			return "", errors.New("failed to marshal kubeconfig")
+		}
+		return string(bytes), nil
+	}).(pulumi.StringOutput)
+	if args.EndpointAccess.private() {
+		component.ApiJumpHost, err = createApiJumpHost(ctx, deployment, &network.Network, component.Cluster, args.EndpointAccess.TunnelPort, childOpts...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	providerKubeconfig := component.Kubeconfig
+	if !args.EndpointAccess.public() {
+		// The private endpoint is only reachable through the SSM tunnel of the API jump host
+		tunnelPort := args.EndpointAccess.TunnelPort
+		component.TunnelPort = tunnelPort
+		providerKubeconfig = component.Cluster.Kubeconfig.ApplyT(func(kc interface{}) (string, error) {
+			content, err := tunnelKubeconfig(kc.(map[string]interface{}), tunnelPort)
+			if err != nil {
+				return "", err
+			}
+			bytes, err := json.Marshal(content)
+			if err != nil {
+				return "", errors.New("failed to marshal kubeconfig")
+			}
+			return string(bytes), nil
+		}).(pulumi.StringOutput)
+	}
+	// The provider was called k8sProvider before several clusters could share a stack, the aliases
+	// keep it and everything it manages in place
+	component.Provider, err = kubernetes.NewProvider(ctx, name+"-k8s", &kubernetes.ProviderArgs{
+		Kubeconfig: providerKubeconfig,
+	}, childOptions(childOpts, renamedChildAliases("k8sProvider"), pulumi.DependsOn([]pulumi.Resource{component.Cluster, component.SystemNodeGroup}))...)
+	if err != nil {
+		return nil, err
+	}
+	component.KubeDns, err = corev1.GetService(ctx, name+"-kube-dns", pulumi.ID("kube-system/kube-dns"), nil,
+		childOptions(childOpts, pulumi.Provider(component.Provider))...)
+	if err != nil {
+		return nil, err
+	}
+	err = ctx.RegisterResourceOutputs(component, pulumi.Map{
+		"name":            pulumi.String(component.Name),
+		"kubeconfig":      pulumi.ToSecret(component.Kubeconfig),
+		"systemNodeGroup": component.SystemNodeGroup.ID(),
+		"addons":          component.AddonVersions,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return component, nil
+}
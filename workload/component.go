@@ -0,0 +1,42 @@
+package workload
+
+import (
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const (
+	TYPE_WORKLOAD_NETWORK     = "xbeam:index:WorkloadNetwork"
+	TYPE_WORKLOAD_CLUSTER     = "xbeam:index:WorkloadCluster"
+	TYPE_GPU_NODE_POOL        = "xbeam:index:GpuNodePool"
+	TYPE_CLUSTER_AUTOSCALER   = "xbeam:index:ClusterAutoscaler"
+	TYPE_TENANT               = "xbeam:index:Tenant"
+	TYPE_NVIDIA_DEVICE_PLUGIN = "xbeam:index:NvidiaDevicePlugin"
+	TYPE_WINDOWS_GPU_SUPPORT  = "xbeam:index:WindowsGpuSupport"
+	TYPE_GPU_METRICS          = "xbeam:index:GpuMetrics"
+	TYPE_OBSERVABILITY        = "xbeam:index:Observability"
+	TYPE_CONTAINER_INSIGHTS   = "xbeam:index:ContainerInsights"
+)
+
+// childOptions appends resource specific options to the ones every resource of a component gets.
+// The result is a fresh slice, so callers can reuse opts for the next resource.
+func childOptions(opts []pulumi.ResourceOption, extra ...pulumi.ResourceOption) []pulumi.ResourceOption {
+	return append(append([]pulumi.ResourceOption{}, opts...), extra...)
+}
+
+// componentChildOptions parents a resource to its component. Stacks created before the components
+// existed have every resource at the root, the alias lets them move into the tree without replacement.
+func componentChildOptions(component pulumi.Resource) []pulumi.ResourceOption {
+	return []pulumi.ResourceOption{
+		pulumi.Parent(component),
+		pulumi.Aliases([]pulumi.Alias{{NoParent: pulumi.Bool(true)}}),
+	}
+}
+
+// renamedChildAliases keeps the state of a child whose logical name changed, both for stacks that
+// know it under its component and for those that still have it at the root.
+func renamedChildAliases(oldName string) pulumi.ResourceOption {
+	return pulumi.Aliases([]pulumi.Alias{
+		{Name: pulumi.String(oldName)},
+		{Name: pulumi.String(oldName), NoParent: pulumi.Bool(true)},
+	})
+}
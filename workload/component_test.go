@@ -0,0 +1,76 @@
+package workload
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+func TestWorkloadNetworkGroupsResources(t *testing.T) {
+	m := &mocks{}
+	deployment := NewDeployment("dev", "us-east-1", "123456789012", &Partition{Name: PARTITION_AWS, DnsSuffix: "amazonaws.com"})
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		_, err := NewWorkloadNetwork(ctx, "network", &WorkloadNetworkArgs{Deployment: deployment})
+		return err
+	}, pulumi.WithMocks("infra", "test", m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	components := m.byType(TYPE_WORKLOAD_NETWORK)
+	if len(components) != 1 {
+		t.Fatalf("expected 1 network component, got %d", len(components))
+	}
+	footprint := map[string]int{
+		"aws:ec2/vpc:Vpc":                         NetworkFootprint.Vpcs,
+		"aws:ec2/internetGateway:InternetGateway": NetworkFootprint.InternetGateways,
+		"aws:ec2/eip:Eip":                         NetworkFootprint.ElasticIps,
+		"aws:ec2/natGateway:NatGateway":           NetworkFootprint.NatGateways,
+	}
+	for typeToken, expected := range footprint {
+		if created := len(m.byType(typeToken)); created != expected {
+			t.Errorf("NetworkFootprint has %d %s, the network creates %d", expected, typeToken, created)
+		}
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	children := 0
+	for _, r := range m.resources {
+		if r.TypeToken == TYPE_WORKLOAD_NETWORK {
+			continue
+		}
+		children++
+		if !strings.HasSuffix(r.RegisterRPC.GetParent(), TYPE_WORKLOAD_NETWORK+"::network") {
+			t.Errorf("%s %s is not parented to the network component", r.TypeToken, r.Name)
+		}
+		if len(r.RegisterRPC.GetAliases()) == 0 {
+			t.Errorf("%s %s has no alias to its previous root level URN", r.TypeToken, r.Name)
+		}
+	}
+	// VPC, gateway, attachment, 3 route tables, 4 subnets, 3 routes, 2 EIPs, 2 NAT gateways,
+	// 4 route table associations, the worker security group and its internal rule
+	if children != 23 {
+		t.Errorf("expected 23 network resources, got %d", children)
+	}
+}
+
+func TestComponentsRequireDeployment(t *testing.T) {
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		if _, err := NewWorkloadNetwork(ctx, "network", &WorkloadNetworkArgs{}); err == nil {
+			t.Error("WorkloadNetwork without a Deployment must fail")
+		}
+		if _, err := NewWorkloadCluster(ctx, "cluster", &WorkloadClusterArgs{}); err == nil {
+			t.Error("WorkloadCluster without a Deployment must fail")
+		}
+		if _, err := NewGpuNodePool(ctx, "pool", nil); err == nil {
+			t.Error("GpuNodePool without args must fail")
+		}
+		if _, err := NewClusterAutoscaler(ctx, "autoscaler", &ClusterAutoscalerArgs{}); err == nil {
+			t.Error("ClusterAutoscaler without a Deployment must fail")
+		}
+		return nil
+	}, pulumi.WithMocks("infra", "test", &mocks{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
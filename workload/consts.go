@@ -0,0 +1,6 @@
+package workload
+
+const (
+	BASE_STACK_NAME = "workload"
+	K8S_VERSION     = "1.29"
+)
@@ -0,0 +1,379 @@
+package workload
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/cloudwatch"
+	appsv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apps/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/helm/v3"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	rbacv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/rbac/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const (
+	// CONTAINER_INSIGHTS_ADDON collects the Container Insights metrics of every node
+	CONTAINER_INSIGHTS_ADDON = "amazon-cloudwatch-observability"
+	// FLUENT_BIT_NAMESPACE is the Kubernetes namespace of the Fluent Bit DaemonSets that ship the logs
+	FLUENT_BIT_NAMESPACE = "fluent-bit"
+
+	AWS_FOR_FLUENT_BIT_CHART_VERSION = "0.1.32"
+	WINDOWS_FLUENT_BIT_IMAGE         = "public.ecr.aws/aws-observability/aws-for-fluent-bit:windowsservercore-latest"
+)
+
+// windowsEventChannels are the Windows Event Logs shipped from the Windows nodes
+var windowsEventChannels = []string{"System", "Application"}
+
+// containerInsightsLogGroupName is a log group of the cluster next to the ones of Container Insights.
+func containerInsightsLogGroupName(clusterName string, kind string) string {
+	return "/aws/containerinsights/" + clusterName + "/" + kind
+}
+
+// parseContainerInsights validates cluster:containerInsights.
+func parseContainerInsights(raw string) (bool, error) {
+	switch raw {
+	case "", "false":
+		return false, nil
+	case "true":
+		return true, nil
+	}
+	return false, errors.New("cluster:containerInsights must be true or false")
+}
+
+// LoadContainerInsights reads cluster:containerInsights.
+func LoadContainerInsights(ctx *pulumi.Context) (bool, error) {
+	raw, _ := ctx.GetConfig("cluster:containerInsights")
+	return parseContainerInsights(raw)
+}
+
+// EnableContainerInsights turns the CloudWatch observability add-on on in the eks:addons configs. Its container
+// logs are turned off unless configurationValues say otherwise, the Fluent Bit DaemonSets of ContainerInsights ship
+// them instead.
+func EnableContainerInsights(configs map[string]AddonConfig) error {
+	config := configs[CONTAINER_INSIGHTS_ADDON]
+	if config.Enabled != nil && !*config.Enabled {
+		return fmt.Errorf("cluster:containerInsights needs the %s add-on, which eks:addons disables", CONTAINER_INSIGHTS_ADDON)
+	}
+	enabled := true
+	config.Enabled = &enabled
+	if len(config.ConfigurationValues) == 0 {
+		config.ConfigurationValues = json.RawMessage(`{"containerLogs":{"enabled":false}}`)
+	}
+	configs[CONTAINER_INSIGHTS_ADDON] = config
+	return nil
+}
+
+// windowsFluentBitTemplate tails the container logs of a Windows node and reads its event logs. Fluent Bit runs as a
+// host process, so the files of the container are below CONTAINER_SANDBOX_MOUNT_POINT, and it asks the kubelet of the
+// node for the pod metadata.
+var windowsFluentBitTemplate = template.Must(template.New("windows-fluent-bit.conf").Parse(`[SERVICE]
+    Flush                     5
+    Log_Level                 info
+    Daemon                    off
+    storage.path              C:\var\fluent-bit\state\flb-storage\
+
+[INPUT]
+    Name                      tail
+    Tag                       application.*
+    Path                      C:\var\log\containers\*.log
+    Exclude_Path              C:\var\log\containers\fluent-bit*
+    multiline.parser          cri, docker
+    DB                        C:\var\fluent-bit\state\flb_container.db
+    Mem_Buf_Limit             50MB
+    Skip_Long_Lines           On
+    Refresh_Interval          10
+    Rotate_Wait               30
+    storage.type              filesystem
+    Read_from_Head            Off
+
+[INPUT]
+    Name                      winevtlog
+    Tag                       windows.events
+    Channels                  {{.Channels}}
+    Interval_Sec              5
+    DB                        C:\var\fluent-bit\state\flb_winevtlog.db
+
+[FILTER]
+    Name                      kubernetes
+    Match                     application.*
+    Kube_Tag_Prefix           application.C.var.log.containers.
+    Kube_CA_File              ${CONTAINER_SANDBOX_MOUNT_POINT}\var\run\secrets\kubernetes.io\serviceaccount\ca.crt
+    Kube_Token_File           ${CONTAINER_SANDBOX_MOUNT_POINT}\var\run\secrets\kubernetes.io\serviceaccount\token
+    Use_Kubelet               On
+    Kubelet_Port              10250
+    Merge_Log                 On
+    Keep_Log                  Off
+    Labels                    Off
+    Annotations               Off
+
+[FILTER]
+    Name                      record_modifier
+    Match                     *
+    Record                    ClusterName {{.ClusterName}}
+    Record                    NodeName ${NODE_NAME}
+    Record                    NodeGroup {{.NodeGroup}}
+
+[OUTPUT]
+    Name                      cloudwatch_logs
+    Match                     application.*
+    region                    {{.Region}}
+    log_group_name            {{.ApplicationLogGroup}}
+    log_stream_prefix         ${NODE_NAME}-
+    auto_create_group         false
+
+[OUTPUT]
+    Name                      cloudwatch_logs
+    Match                     windows.events
+    region                    {{.Region}}
+    log_group_name            {{.WindowsEventsLogGroup}}
+    log_stream_prefix         ${NODE_NAME}-
+    auto_create_group         false
+`))
+
+// renderWindowsFluentBitConfig returns the Fluent Bit config of the Windows GPU nodes.
+func renderWindowsFluentBitConfig(region string, clusterName string) (string, error) {
+	return render(windowsFluentBitTemplate, struct {
+		Region, ClusterName, NodeGroup, Channels   string
+		ApplicationLogGroup, WindowsEventsLogGroup string
+	}{
+		Region:                region,
+		ClusterName:           clusterName,
+		NodeGroup:             POOL_WINDOWS,
+		Channels:              strings.Join(windowsEventChannels, ","),
+		ApplicationLogGroup:   containerInsightsLogGroupName(clusterName, "application"),
+		WindowsEventsLogGroup: containerInsightsLogGroupName(clusterName, "windows-events"),
+	})
+}
+
+type ContainerInsightsArgs struct {
+	Deployment *Deployment
+	Cluster    *WorkloadCluster
+	// LogRetentionDays keeps the container and event logs, 0 forever
+	LogRetentionDays int
+}
+
+// ContainerInsights ships the logs of the cluster to CloudWatch Logs, next to the Container Insights metrics of the
+// CloudWatch observability add-on: the container logs of every node into the application log group, and the System
+// and Application event logs of the Windows GPU nodes into the windows-events log group. Both Fluent Bit DaemonSets
+// share a service account with an IRSA role allowed to write the logs.
+type ContainerInsights struct {
+	pulumi.ResourceState
+
+	Namespace             *corev1.Namespace
+	ApplicationLogGroup   *cloudwatch.LogGroup
+	WindowsEventsLogGroup *cloudwatch.LogGroup
+	LinuxFluentBit        *helm.Release
+	WindowsFluentBit      *appsv1.DaemonSet
+}
+
+func NewContainerInsights(ctx *pulumi.Context, name string, args *ContainerInsightsArgs, opts ...pulumi.ResourceOption) (*ContainerInsights, error) {
+	if args == nil || args.Deployment == nil || args.Cluster == nil {
+		return nil, errors.New("ContainerInsights requires a Deployment and a Cluster")
+	}
+	if !validLogRetention(args.LogRetentionDays) {
+		return nil, fmt.Errorf("%d days is not a CloudWatch Logs retention period", args.LogRetentionDays)
+	}
+	deployment := args.Deployment
+	cluster := args.Cluster
+	clusterName := cluster.Name
+	windowsConfig, err := renderWindowsFluentBitConfig(deployment.Region, clusterName)
+	if err != nil {
+		return nil, err
+	}
+	component := &ContainerInsights{}
+	err = ctx.RegisterComponentResource(TYPE_CONTAINER_INSIGHTS, name, component, opts...)
+	if err != nil {
+		return nil, err
+	}
+	childOpts := []pulumi.ResourceOption{pulumi.Parent(component)}
+	k8sOpts := childOptions(childOpts, pulumi.Provider(cluster.Provider))
+	component.ApplicationLogGroup, err = cloudwatch.NewLogGroup(ctx, deployment.name("ApplicationLogGroup", "WorkloadCluster"), &cloudwatch.LogGroupArgs{
+		Name:            pulumi.String(containerInsightsLogGroupName(clusterName, "application")),
+		RetentionInDays: pulumi.Int(args.LogRetentionDays),
+		Tags:            deployment.tags(nil),
+	}, childOpts...)
+	if err != nil {
+		return nil, err
+	}
+	component.WindowsEventsLogGroup, err = cloudwatch.NewLogGroup(ctx, deployment.name("WindowsEventsLogGroup", "WorkloadCluster"), &cloudwatch.LogGroupArgs{
+		Name:            pulumi.String(containerInsightsLogGroupName(clusterName, "windows-events")),
+		RetentionInDays: pulumi.Int(args.LogRetentionDays),
+		Tags:            deployment.tags(nil),
+	}, childOpts...)
+	if err != nil {
+		return nil, err
+	}
+	role, err := createServiceAccountRole(ctx, deployment, deployment.name("FluentBitRole"), cluster.Cluster,
+		FLUENT_BIT_NAMESPACE, "fluent-bit", deployment.Partition.managedPolicyArns("CloudWatchAgentServerPolicy"),
+		childOptions(childOpts, pulumi.DependsOn([]pulumi.Resource{cluster.Cluster}))...)
+	if err != nil {
+		return nil, err
+	}
+	component.Namespace, err = corev1.NewNamespace(ctx, name, &corev1.NamespaceArgs{
+		Metadata: metav1.ObjectMetaArgs{Name: pulumi.String(FLUENT_BIT_NAMESPACE)},
+	}, k8sOpts...)
+	if err != nil {
+		return nil, err
+	}
+	serviceAccount, err := corev1.NewServiceAccount(ctx, name+"-fluent-bit", &corev1.ServiceAccountArgs{
+		Metadata: metav1.ObjectMetaArgs{
+			Name:      pulumi.String("fluent-bit"),
+			Namespace: pulumi.String(FLUENT_BIT_NAMESPACE),
+			Annotations: pulumi.StringMap{
+				"eks.amazonaws.com/role-arn":               role.Arn,
+				"eks.amazonaws.com/sts-regional-endpoints": pulumi.String("true"),
+			},
+		},
+	}, childOptions(k8sOpts, pulumi.DependsOn([]pulumi.Resource{component.Namespace}))...)
+	if err != nil {
+		return nil, err
+	}
+	logGroups := []pulumi.Resource{serviceAccount, component.ApplicationLogGroup, component.WindowsEventsLogGroup}
+	component.LinuxFluentBit, err = helm.NewRelease(ctx, name+"-aws-for-fluent-bit", &helm.ReleaseArgs{
+		Namespace: pulumi.String(FLUENT_BIT_NAMESPACE),
+		Name:      pulumi.String("aws-for-fluent-bit"),
+		RepositoryOpts: helm.RepositoryOptsArgs{
+			Repo: pulumi.String("https://aws.github.io/eks-charts"),
+		},
+		Chart:   pulumi.String("aws-for-fluent-bit"),
+		Version: pulumi.String(AWS_FOR_FLUENT_BIT_CHART_VERSION),
+		Values: pulumi.Map{
+			"serviceAccount": pulumi.Map{
+				"create": pulumi.Bool(false),
+				"name":   pulumi.String("fluent-bit"),
+			},
+			"nodeSelector": pulumi.StringMap{"kubernetes.io/os": pulumi.String("linux")},
+			"tolerations":  gpuTolerations(),
+			"cloudWatchLogs": pulumi.Map{
+				"enabled":         pulumi.Bool(true),
+				"region":          pulumi.String(deployment.Region),
+				"logGroupName":    pulumi.String(containerInsightsLogGroupName(clusterName, "application")),
+				"logStreamPrefix": pulumi.String("fluent-bit-"),
+				"autoCreateGroup": pulumi.Bool(false),
+			},
+			"cloudWatch":    pulumi.Map{"enabled": pulumi.Bool(false)},
+			"firehose":      pulumi.Map{"enabled": pulumi.Bool(false)},
+			"kinesis":       pulumi.Map{"enabled": pulumi.Bool(false)},
+			"elasticsearch": pulumi.Map{"enabled": pulumi.Bool(false)},
+		},
+	}, childOptions(k8sOpts, pulumi.DependsOn(logGroups))...)
+	if err != nil {
+		return nil, err
+	}
+	component.WindowsFluentBit, err = createWindowsFluentBit(ctx, name+"-fluent-bit-windows", windowsConfig, serviceAccount, childOptions(k8sOpts, pulumi.DependsOn(logGroups))...)
+	if err != nil {
+		return nil, err
+	}
+	err = ctx.RegisterResourceOutputs(component, pulumi.Map{
+		"applicationLogGroup":   component.ApplicationLogGroup.Name,
+		"windowsEventsLogGroup": component.WindowsEventsLogGroup.Name,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return component, nil
+}
+
+// createWindowsFluentBit runs Fluent Bit as a host process on the Windows GPU nodes, which can read the event logs
+// of the node. The kubelet of the node answers the pod metadata queries of the kubernetes filter.
+func createWindowsFluentBit(ctx *pulumi.Context, name string, config string, serviceAccount *corev1.ServiceAccount, opts ...pulumi.ResourceOption) (*appsv1.DaemonSet, error) {
+	clusterRole, err := rbacv1.NewClusterRole(ctx, name, &rbacv1.ClusterRoleArgs{
+		Rules: rbacv1.PolicyRuleArray{
+			rbacv1.PolicyRuleArgs{
+				ApiGroups: pulumi.StringArray{pulumi.String("")},
+				Resources: pulumi.ToStringArray([]string{"namespaces", "pods", "nodes", "nodes/proxy"}),
+				Verbs:     pulumi.ToStringArray([]string{"get", "list", "watch"}),
+			},
+		},
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	_, err = rbacv1.NewClusterRoleBinding(ctx, name, &rbacv1.ClusterRoleBindingArgs{
+		RoleRef: rbacv1.RoleRefArgs{
+			ApiGroup: pulumi.String("rbac.authorization.k8s.io"),
+			Kind:     pulumi.String("ClusterRole"),
+			Name:     clusterRole.Metadata.Name().Elem(),
+		},
+		Subjects: rbacv1.SubjectArray{
+			rbacv1.SubjectArgs{
+				Kind:      pulumi.String("ServiceAccount"),
+				Name:      pulumi.String("fluent-bit"),
+				Namespace: pulumi.String(FLUENT_BIT_NAMESPACE),
+			},
+		},
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	configMap, err := corev1.NewConfigMap(ctx, name+"-config", &corev1.ConfigMapArgs{
+		Metadata: metav1.ObjectMetaArgs{
+			Name:      pulumi.String("fluent-bit-windows-config"),
+			Namespace: pulumi.String(FLUENT_BIT_NAMESPACE),
+		},
+		Data: pulumi.StringMap{"fluent-bit.conf": pulumi.String(config)},
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	command := `New-Item -ItemType Directory -Force -Path C:\var\fluent-bit\state | Out-Null; ` +
+		`& "$env:CONTAINER_SANDBOX_MOUNT_POINT\fluent-bit\bin\fluent-bit.exe" -c "$env:CONTAINER_SANDBOX_MOUNT_POINT\fluent-bit\configuration\fluent-bit.conf"`
+	labels := pulumi.StringMap{"app.kubernetes.io/name": pulumi.String("fluent-bit-windows")}
+	return appsv1.NewDaemonSet(ctx, name, &appsv1.DaemonSetArgs{
+		Metadata: metav1.ObjectMetaArgs{
+			Name:      pulumi.String("fluent-bit-windows"),
+			Namespace: pulumi.String(FLUENT_BIT_NAMESPACE),
+			Labels:    labels,
+		},
+		Spec: appsv1.DaemonSetSpecArgs{
+			Selector: metav1.LabelSelectorArgs{MatchLabels: labels},
+			Template: corev1.PodTemplateSpecArgs{
+				// The checksum rolls Fluent Bit when its config changes
+				Metadata: metav1.ObjectMetaArgs{
+					Labels:      labels,
+					Annotations: pulumi.StringMap{"xbeam/config-checksum": pulumi.String(nameHash(config))},
+				},
+				Spec: corev1.PodSpecArgs{
+					ServiceAccountName: serviceAccount.Metadata.Name().Elem(),
+					NodeSelector:       windowsGpuNodeSelector(),
+					Tolerations:        gpuTolerationArray(),
+					HostNetwork:        pulumi.Bool(true),
+					SecurityContext:    hostProcessSecurityContext(),
+					Containers: corev1.ContainerArray{
+						corev1.ContainerArgs{
+							Name:    pulumi.String("fluent-bit"),
+							Image:   pulumi.String(WINDOWS_FLUENT_BIT_IMAGE),
+							Command: pulumi.StringArray{pulumi.String("powershell.exe"), pulumi.String("-NoProfile"), pulumi.String("-NonInteractive"), pulumi.String("-Command"), pulumi.String(command)},
+							Env: corev1.EnvVarArray{
+								corev1.EnvVarArgs{
+									Name: pulumi.String("NODE_NAME"),
+									ValueFrom: corev1.EnvVarSourceArgs{
+										FieldRef: corev1.ObjectFieldSelectorArgs{FieldPath: pulumi.String("spec.nodeName")},
+									},
+								},
+							},
+							Resources: corev1.ResourceRequirementsArgs{
+								Requests: pulumi.StringMap{"cpu": pulumi.String("100m"), "memory": pulumi.String("128Mi")},
+								Limits:   pulumi.StringMap{"cpu": pulumi.String("500m"), "memory": pulumi.String("512Mi")},
+							},
+							VolumeMounts: corev1.VolumeMountArray{
+								corev1.VolumeMountArgs{Name: pulumi.String("config"), MountPath: pulumi.String("/fluent-bit/configuration/")},
+							},
+						},
+					},
+					Volumes: corev1.VolumeArray{
+						corev1.VolumeArgs{
+							Name:      pulumi.String("config"),
+							ConfigMap: corev1.ConfigMapVolumeSourceArgs{Name: configMap.Metadata.Name()},
+						},
+					},
+				},
+			},
+		},
+	}, opts...)
+}
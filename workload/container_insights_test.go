@@ -0,0 +1,57 @@
+package workload
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWindowsFluentBitConfigGolden(t *testing.T) {
+	config, err := renderWindowsFluentBitConfig("us-east-1", "dev-eks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "containerinsights/windows-fluent-bit.conf", config)
+	for _, want := range []string{"Channels                  System,Application", "/aws/containerinsights/dev-eks/windows-events"} {
+		if !strings.Contains(config, want) {
+			t.Errorf("expected %q in the Windows Fluent Bit config", want)
+		}
+	}
+}
+
+func TestParseContainerInsights(t *testing.T) {
+	for raw, want := range map[string]bool{"": false, "false": false, "true": true} {
+		if enabled, err := parseContainerInsights(raw); err != nil || enabled != want {
+			t.Errorf("%q: expected %v, got %v %v", raw, want, enabled, err)
+		}
+	}
+	if _, err := parseContainerInsights("yes"); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestEnableContainerInsights(t *testing.T) {
+	configs := map[string]AddonConfig{}
+	if err := EnableContainerInsights(configs); err != nil {
+		t.Fatal(err)
+	}
+	config := configs[CONTAINER_INSIGHTS_ADDON]
+	if config.Enabled == nil || !*config.Enabled {
+		t.Error("expected the add-on to be enabled")
+	}
+	if values, _ := config.configurationValues(); values != `{"containerLogs":{"enabled":false}}` {
+		t.Errorf("expected the add-on container logs to be off, got %s", values)
+	}
+
+	configs, _ = ParseAddonConfigs(`{"amazon-cloudwatch-observability": {"configurationValues": {"containerLogs": {"enabled": true}}}}`)
+	if err := EnableContainerInsights(configs); err != nil {
+		t.Fatal(err)
+	}
+	if values, _ := configs[CONTAINER_INSIGHTS_ADDON].configurationValues(); values != `{"containerLogs":{"enabled":true}}` {
+		t.Errorf("expected the configured values to be kept, got %s", values)
+	}
+
+	configs, _ = ParseAddonConfigs(`{"amazon-cloudwatch-observability": {"enabled": false}}`)
+	if err := EnableContainerInsights(configs); err == nil {
+		t.Error("expected an error when eks:addons disables the add-on")
+	}
+}
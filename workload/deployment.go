@@ -0,0 +1,68 @@
+package workload
+
+import (
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// Deployment carries everything that identifies one workload deployment: where it runs and how its
+// resources are named and tagged. It is passed explicitly so several clusters can live in one program.
+type Deployment struct {
+	Stack     string
+	Region    string
+	AccountId string
+	Partition *Partition
+	Names     *Namer
+	Tags      map[string]string
+}
+
+func NewDeployment(stack string, region string, accountId string, partition *Partition) *Deployment {
+	return &Deployment{
+		Stack:     stack,
+		Region:    region,
+		AccountId: accountId,
+		Partition: partition,
+		Names:     newNamer(),
+		Tags: map[string]string{
+			"Project": BASE_STACK_NAME,
+			"Stack":   stack,
+		},
+	}
+}
+
+// name builds workload-<args>-<region>-<stack>, the logical name of every resource of the deployment.
+func (d *Deployment) name(args ...string) string {
+	name := BASE_STACK_NAME
+	for _, arg := range args {
+		name += "-" + arg
+	}
+	name += "-" + d.Region
+	name += "-" + d.Stack
+	return name
+}
+
+// resourceName is name fitted to the naming rules of the resource kind.
+func (d *Deployment) resourceName(kind ResourceKind, args ...string) string {
+	return d.Names.name(kind, d.name(args...))
+}
+
+// autoName returns an explicit physical name only when autonaming the logical name would exceed the limit.
+func (d *Deployment) autoName(kind ResourceKind, args ...string) pulumi.StringPtrInput {
+	return pulumi.StringPtrFromPtr(d.Names.autoName(kind, d.name(args...)))
+}
+
+// tags merges the deployment tags into the resource specific ones.
+func (d *Deployment) tags(tags pulumi.StringMap) pulumi.StringMap {
+	merged := pulumi.StringMap{}
+	for key, value := range d.Tags {
+		merged[key] = pulumi.String(value)
+	}
+	for key, value := range tags {
+		merged[key] = value
+	}
+	return merged
+}
+
+// ClusterName is the physical name of the deployment's EKS cluster.
+func (d *Deployment) ClusterName() string {
+	return d.resourceName(KIND_EKS_CLUSTER, "WorkloadCluster")
+}
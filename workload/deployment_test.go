@@ -0,0 +1,80 @@
+package workload
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+func TestDeploymentName(t *testing.T) {
+	deployment := NewDeployment("dev", "us-east-1", "123456789012", &Partition{Name: PARTITION_AWS, DnsSuffix: "amazonaws.com"})
+	if got := deployment.name("SystemRole", "WorkloadCluster"); got != "workload-SystemRole-WorkloadCluster-us-east-1-dev" {
+		t.Errorf("unexpected name %s", got)
+	}
+	if got := deployment.resourceName(KIND_TARGET_GROUP, "ApiTargetGroup"); len(got) > namingRules[KIND_TARGET_GROUP].MaxLength {
+		t.Errorf("resource name %s exceeds the target group limit", got)
+	}
+}
+
+func TestDeploymentTags(t *testing.T) {
+	deployment := NewDeployment("dev", "us-east-1", "123456789012", &Partition{Name: PARTITION_AWS, DnsSuffix: "amazonaws.com"})
+	tags := deployment.tags(pulumi.StringMap{
+		"Name":  pulumi.String("node"),
+		"Stack": pulumi.String("override"),
+	})
+	if len(tags) != 3 {
+		t.Fatalf("expected 3 tags, got %d", len(tags))
+	}
+	if tags["Project"] != pulumi.String(BASE_STACK_NAME) {
+		t.Errorf("missing Project tag")
+	}
+	if tags["Stack"] != pulumi.String("override") {
+		t.Errorf("resource tags must take precedence over deployment tags")
+	}
+}
+
+// Two deployments in one program must not share names or collide in each other's namer.
+func TestTwoDeploymentsInOneProgram(t *testing.T) {
+	m := &mocks{}
+	partition := &Partition{Name: PARTITION_AWS, DnsSuffix: "amazonaws.com"}
+	deployments := []*Deployment{
+		NewDeployment("dev", "us-east-1", "123456789012", partition),
+		NewDeployment("dev", "eu-west-1", "123456789012", partition),
+	}
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		for _, deployment := range deployments {
+			network := new(Network)
+			if err := setupEKSNetwork(ctx, deployment, network); err != nil {
+				return err
+			}
+			if _, err := createWorkerSecurityGroup(ctx, deployment, network.Vpc); err != nil {
+				return err
+			}
+			if _, err := createWorkerRole(ctx, deployment, deployment.name("LinuxWorkerRole", "WorkloadCluster")); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, pulumi.WithMocks("infra", "test", m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, deployment := range deployments {
+		if err := deployment.Names.Duplicates(); err != nil {
+			t.Error(err)
+		}
+	}
+	subnets := m.byType("aws:ec2/subnet:Subnet")
+	if len(subnets) != 8 {
+		t.Fatalf("expected 8 subnets, got %d", len(subnets))
+	}
+	for _, subnet := range subnets {
+		zone := subnet.Inputs["availabilityZone"].StringValue()
+		for _, deployment := range deployments {
+			if strings.HasSuffix(subnet.Name, "-"+deployment.Region+"-dev") && !strings.HasPrefix(zone, deployment.Region) {
+				t.Errorf("%s: availability zone %s is not in %s", subnet.Name, zone, deployment.Region)
+			}
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package workload
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/helm/v3"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"gopkg.in/yaml.v3"
+)
+
+// The chart deploys GPU feature discovery and node feature discovery along with the device plugin
+const NVIDIA_DEVICE_PLUGIN_CHART_VERSION = "0.14.5"
+
+// timeSlicing is the sharing section of the device plugin config, see
+// https://github.com/NVIDIA/k8s-device-plugin#shared-access-to-gpus-with-cuda-time-slicing
+type timeSlicing struct {
+	Version string `yaml:"version"`
+	Sharing struct {
+		TimeSlicing struct {
+			RenameByDefault            bool                  `yaml:"renameByDefault"`
+			FailRequestsGreaterThanOne bool                  `yaml:"failRequestsGreaterThanOne"`
+			Resources                  []timeSlicingResource `yaml:"resources"`
+		} `yaml:"timeSlicing"`
+	} `yaml:"sharing"`
+}
+
+type timeSlicingResource struct {
+	Name     string `yaml:"name"`
+	Replicas int    `yaml:"replicas"`
+}
+
+// renderTimeSlicingConfig returns the device plugin config that advertises every GPU as replicas nvidia.com/gpu.
+// A pod asking for more than one replica would not get more GPU time, so such requests fail.
+func renderTimeSlicingConfig(replicas int) (string, error) {
+	if replicas < 2 {
+		return "", fmt.Errorf("time slicing needs at least 2 replicas, got %d", replicas)
+	}
+	config := timeSlicing{Version: "v1"}
+	config.Sharing.TimeSlicing.FailRequestsGreaterThanOne = true
+	config.Sharing.TimeSlicing.Resources = []timeSlicingResource{{Name: GPU_RESOURCE, Replicas: replicas}}
+	bytes, err := yaml.Marshal(config)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+// parseTimeSlicingReplicas reads worker:gpuTimeSlicingReplicas, 0 or 1 do not share the GPUs.
+func parseTimeSlicingReplicas(raw string) (int, error) {
+	if raw == "" {
+		return 0, nil
+	}
+	replicas, err := strconv.Atoi(raw)
+	if err != nil || replicas < 0 {
+		return 0, errors.New("worker:gpuTimeSlicingReplicas must be a non-negative integer")
+	}
+	return replicas, nil
+}
+
+// LoadTimeSlicingReplicas reads the number of pods that share a Linux GPU.
+func LoadTimeSlicingReplicas(ctx *pulumi.Context) (int, error) {
+	raw, _ := ctx.GetConfig("worker:gpuTimeSlicingReplicas")
+	return parseTimeSlicingReplicas(raw)
+}
+
+type NvidiaDevicePluginArgs struct {
+	Cluster *WorkloadCluster
+	// TimeSlicingReplicas shares every GPU between that many pods when above 1
+	TimeSlicingReplicas int
+}
+
+// NvidiaDevicePlugin advertises nvidia.com/gpu on the Linux GPU nodes and labels them with the GPU model, memory
+// and driver found by GPU feature discovery.
+type NvidiaDevicePlugin struct {
+	pulumi.ResourceState
+
+	Release *helm.Release
+}
+
+func NewNvidiaDevicePlugin(ctx *pulumi.Context, name string, args *NvidiaDevicePluginArgs, opts ...pulumi.ResourceOption) (*NvidiaDevicePlugin, error) {
+	if args == nil || args.Cluster == nil {
+		return nil, errors.New("NvidiaDevicePlugin requires a Cluster")
+	}
+	cluster := args.Cluster
+	values := pulumi.Map{
+		"affinity":    gpuNodeAffinity("linux"),
+		"tolerations": gpuTolerations(),
+		"gfd": pulumi.Map{
+			"enabled": pulumi.Bool(true),
+		},
+		// The node feature discovery master runs on the system pool, its workers on every Linux node
+		"nfd": pulumi.Map{
+			"master": pulumi.Map{
+				"nodeSelector": pulumi.StringMap{"type": pulumi.String("system")},
+			},
+			"worker": pulumi.Map{
+				"nodeSelector": pulumi.StringMap{"kubernetes.io/os": pulumi.String("linux")},
+				"tolerations":  gpuTolerations(),
+			},
+		},
+	}
+	if args.TimeSlicingReplicas > 1 {
+		config, err := renderTimeSlicingConfig(args.TimeSlicingReplicas)
+		if err != nil {
+			return nil, err
+		}
+		values["config"] = pulumi.Map{
+			"default": pulumi.String("time-slicing"),
+			"map": pulumi.StringMap{
+				"time-slicing": pulumi.String(config),
+			},
+		}
+	}
+	component := &NvidiaDevicePlugin{}
+	err := ctx.RegisterComponentResource(TYPE_NVIDIA_DEVICE_PLUGIN, name, component, opts...)
+	if err != nil {
+		return nil, err
+	}
+	component.Release, err = helm.NewRelease(ctx, name, &helm.ReleaseArgs{
+		Namespace: pulumi.String("kube-system"),
+		Name:      pulumi.String("nvidia-device-plugin"),
+		RepositoryOpts: helm.RepositoryOptsArgs{
+			Repo: pulumi.String("https://nvidia.github.io/k8s-device-plugin"),
+		},
+		Chart:   pulumi.String("nvidia-device-plugin"),
+		Version: pulumi.String(NVIDIA_DEVICE_PLUGIN_CHART_VERSION),
+		Values:  values,
+	}, pulumi.Parent(component), pulumi.Provider(cluster.Provider), pulumi.DependsOn([]pulumi.Resource{cluster.Cluster}))
+	if err != nil {
+		return nil, err
+	}
+	err = ctx.RegisterResourceOutputs(component, pulumi.Map{
+		"release":             component.Release.Name,
+		"timeSlicingReplicas": pulumi.Int(args.TimeSlicingReplicas),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return component, nil
+}
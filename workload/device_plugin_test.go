@@ -0,0 +1,27 @@
+package workload
+
+import "testing"
+
+func TestTimeSlicingConfigGolden(t *testing.T) {
+	config, err := renderTimeSlicingConfig(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "deviceplugin/time-slicing.yaml", config)
+	if _, err := renderTimeSlicingConfig(1); err == nil {
+		t.Error("expected a single replica to fail")
+	}
+}
+
+func TestParseTimeSlicingReplicas(t *testing.T) {
+	for raw, want := range map[string]int{"": 0, "0": 0, "4": 4} {
+		if replicas, err := parseTimeSlicingReplicas(raw); err != nil || replicas != want {
+			t.Errorf("%q: expected %d, got %d %v", raw, want, replicas, err)
+		}
+	}
+	for _, raw := range []string{"-1", "two"} {
+		if _, err := parseTimeSlicingReplicas(raw); err == nil {
+			t.Errorf("%q: expected an error", raw)
+		}
+	}
+}
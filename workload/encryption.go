@@ -0,0 +1,104 @@
+package workload
+
+import (
+	"errors"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/kms"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+var keyUserActions = []string{
+	"kms:Encrypt",
+	"kms:Decrypt",
+	"kms:ReEncrypt*",
+	"kms:GenerateDataKey*",
+	"kms:DescribeKey",
+}
+
+func keyAdministratorStatement(partition *Partition, accountId string) PolicyStatement {
+	statement := allowStatement([]string{"kms:*"}, "*")
+	statement.Sid = "EnableAccountAdministration"
+	statement.Principal = &PolicyPrincipal{AWS: []string{partition.accountRootArn(accountId)}}
+	return statement
+}
+
+// clusterKeyPolicyDocument grants the cluster and node roles, plus the autoscaling service-linked role that
+// launches managed node group instances, use of the key for secrets envelope encryption and EBS volumes.
+func clusterKeyPolicyDocument(partition *Partition, accountId string, roleArns []string) PolicyDocument {
+	principals := append([]string{partition.autoscalingServiceLinkedRoleArn(accountId)}, roleArns...)
+	use := allowStatement(keyUserActions, "*")
+	use.Sid = "AllowClusterAndNodeUse"
+	use.Principal = &PolicyPrincipal{AWS: principals}
+	grant := allowStatement([]string{"kms:CreateGrant", "kms:ListGrants", "kms:RevokeGrant"}, "*")
+	grant.Sid = "AllowAttachmentOfPersistentResources"
+	grant.Principal = &PolicyPrincipal{AWS: principals}
+	grant.Condition = PolicyCondition{
+		"Bool": {
+			"kms:GrantIsForAWSResource": {"true"},
+		},
+	}
+	return newPolicyDocument(keyAdministratorStatement(partition, accountId), use, grant)
+}
+
+// ClusterEncryption selects the customer managed key for Kubernetes secrets and worker root volumes:
+// an existing key, one created by the stack, or neither to fall back to AWS managed keys.
+type ClusterEncryption struct {
+	KmsKeyArn    string
+	CreateKmsKey bool
+}
+
+func NewClusterEncryption(kmsKeyArn string, createKmsKey bool) (ClusterEncryption, error) {
+	if kmsKeyArn != "" && createKmsKey {
+		return ClusterEncryption{}, errors.New("eks:kmsKeyArn and eks:createKmsKey are mutually exclusive")
+	}
+	return ClusterEncryption{
+		KmsKeyArn:    kmsKeyArn,
+		CreateKmsKey: createKmsKey,
+	}, nil
+}
+
+// LoadClusterEncryption reads eks:kmsKeyArn and eks:createKmsKey.
+func LoadClusterEncryption(ctx *pulumi.Context) (ClusterEncryption, error) {
+	keyArn, _ := ctx.GetConfig("eks:kmsKeyArn")
+	createKey, _ := ctx.GetConfig("eks:createKmsKey")
+	return NewClusterEncryption(keyArn, createKey == "true")
+}
+
+// setupClusterKey returns the key selected by encryption, creating it when requested.
+// A nil result means encryption falls back to AWS managed keys.
+func setupClusterKey(ctx *pulumi.Context, deployment *Deployment, encryption ClusterEncryption, roles []*iam.Role, opts ...pulumi.ResourceOption) (pulumi.StringPtrInput, error) {
+	if encryption.KmsKeyArn != "" {
+		return pulumi.String(encryption.KmsKeyArn), nil
+	}
+	if !encryption.CreateKmsKey {
+		return nil, nil
+	}
+	roleArns := pulumi.StringArray{}
+	dependencies := []pulumi.Resource{}
+	for _, role := range roles {
+		roleArns = append(roleArns, role.Arn)
+		dependencies = append(dependencies, role)
+	}
+	keyPolicy := roleArns.ToStringArrayOutput().ApplyT(func(arns []string) (string, error) {
+		return clusterKeyPolicyDocument(deployment.Partition, deployment.AccountId, arns).JSON()
+	}).(pulumi.StringOutput)
+	key, err := kms.NewKey(ctx, deployment.name("ClusterKey", "WorkloadCluster"), &kms.KeyArgs{
+		Description:          pulumi.String("Encrypts Kubernetes secrets and worker volumes of " + deployment.name("WorkloadCluster")),
+		EnableKeyRotation:    pulumi.Bool(true),
+		DeletionWindowInDays: pulumi.Int(7),
+		Policy:               keyPolicy,
+		Tags:                 deployment.tags(nil),
+	}, childOptions(opts, pulumi.DependsOn(dependencies))...)
+	if err != nil {
+		return nil, err
+	}
+	_, err = kms.NewAlias(ctx, deployment.name("ClusterKeyAlias", "WorkloadCluster"), &kms.AliasArgs{
+		Name:        pulumi.String(deployment.Names.name(KIND_KMS_ALIAS, "alias/"+deployment.name("WorkloadCluster"))),
+		TargetKeyId: key.KeyId,
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return key.Arn, nil
+}
@@ -0,0 +1,29 @@
+package workload
+
+import "testing"
+
+func TestClusterKeyPolicyDocument(t *testing.T) {
+	partition := &Partition{Name: PARTITION_AWS_US_GOV, DnsSuffix: "amazonaws.com"}
+	doc := clusterKeyPolicyDocument(partition, "123456789012", []string{"arn:aws-us-gov:iam::123456789012:role/worker"})
+	if len(doc.Statement) != 3 {
+		t.Fatalf("expected 3 statements, got %d", len(doc.Statement))
+	}
+	if got := doc.Statement[0].Principal.AWS[0]; got != "arn:aws-us-gov:iam::123456789012:root" {
+		t.Errorf("unexpected administrator %s", got)
+	}
+	for _, statement := range doc.Statement[1:] {
+		principals := statement.Principal.AWS
+		if len(principals) != 2 {
+			t.Fatalf("%s: expected 2 principals, got %v", statement.Sid, principals)
+		}
+		if principals[0] != "arn:aws-us-gov:iam::123456789012:role/aws-service-role/autoscaling.amazonaws.com/AWSServiceRoleForAutoScaling" {
+			t.Errorf("%s: missing autoscaling service-linked role, got %v", statement.Sid, principals)
+		}
+		if principals[1] != "arn:aws-us-gov:iam::123456789012:role/worker" {
+			t.Errorf("%s: missing node role, got %v", statement.Sid, principals)
+		}
+	}
+	if got := doc.Statement[2].Condition["Bool"]["kms:GrantIsForAWSResource"]; len(got) != 1 || got[0] != "true" {
+		t.Errorf("grant statement must be limited to AWS resources, got %v", got)
+	}
+}
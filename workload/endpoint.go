@@ -0,0 +1,243 @@
+package workload
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
+	"github.com/pulumi/pulumi-eks/sdk/v2/go/eks"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const (
+	ENDPOINT_ACCESS_PUBLIC  = "public"
+	ENDPOINT_ACCESS_PRIVATE = "private"
+	ENDPOINT_ACCESS_BOTH    = "both"
+
+	DEFAULT_API_TUNNEL_PORT = 8443
+)
+
+// EndpointAccess is the cluster:endpointAccess configuration of the Kubernetes API endpoint.
+type EndpointAccess struct {
+	Mode              string
+	PublicAccessCidrs []string
+	TunnelPort        int
+}
+
+func (e *EndpointAccess) private() bool {
+	return e.Mode == ENDPOINT_ACCESS_PRIVATE || e.Mode == ENDPOINT_ACCESS_BOTH
+}
+
+func (e *EndpointAccess) public() bool {
+	return e.Mode == ENDPOINT_ACCESS_PUBLIC || e.Mode == ENDPOINT_ACCESS_BOTH
+}
+
+// publicAccessCidrs is nil when no allowlist is configured so that EKS keeps its 0.0.0.0/0 default.
+func (e *EndpointAccess) publicAccessCidrs() pulumi.StringArrayInput {
+	if len(e.PublicAccessCidrs) == 0 {
+		return nil
+	}
+	return pulumi.ToStringArray(e.PublicAccessCidrs)
+}
+
+// NewEndpointAccess validates an endpoint configuration. An empty mode is public, a zero tunnel port
+// uses DEFAULT_API_TUNNEL_PORT.
+func NewEndpointAccess(mode string, publicAccessCidrs []string, tunnelPort int) (*EndpointAccess, error) {
+	access := &EndpointAccess{
+		Mode:              mode,
+		PublicAccessCidrs: publicAccessCidrs,
+		TunnelPort:        tunnelPort,
+	}
+	if access.Mode == "" {
+		access.Mode = ENDPOINT_ACCESS_PUBLIC
+	}
+	if access.TunnelPort == 0 {
+		access.TunnelPort = DEFAULT_API_TUNNEL_PORT
+	}
+	if !access.private() && !access.public() {
+		return nil, fmt.Errorf("cluster:endpointAccess must be one of %s, %s or %s", ENDPOINT_ACCESS_PUBLIC, ENDPOINT_ACCESS_PRIVATE, ENDPOINT_ACCESS_BOTH)
+	}
+	if len(access.PublicAccessCidrs) > 0 && !access.public() {
+		return nil, errors.New("cluster:publicAccessCidrs requires a public endpoint")
+	}
+	for _, cidr := range access.PublicAccessCidrs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q in cluster:publicAccessCidrs", cidr)
+		}
+	}
+	if access.TunnelPort < 1 || access.TunnelPort > 65535 {
+		return nil, errors.New("cluster:apiTunnelPort must be a valid port")
+	}
+	return access, nil
+}
+
+func parseEndpointAccess(mode string, rawCidrs string, rawTunnelPort string) (*EndpointAccess, error) {
+	var cidrs []string
+	if rawCidrs != "" {
+		if err := json.Unmarshal([]byte(rawCidrs), &cidrs); err != nil {
+			return nil, fmt.Errorf("invalid cluster:publicAccessCidrs: %w", err)
+		}
+	}
+	tunnelPort := 0
+	if rawTunnelPort != "" {
+		port, err := strconv.Atoi(rawTunnelPort)
+		if err != nil || port < 1 {
+			return nil, errors.New("cluster:apiTunnelPort must be a valid port")
+		}
+		tunnelPort = port
+	}
+	return NewEndpointAccess(mode, cidrs, tunnelPort)
+}
+
+// LoadEndpointAccess reads cluster:endpointAccess, cluster:publicAccessCidrs and cluster:apiTunnelPort.
+func LoadEndpointAccess(ctx *pulumi.Context) (*EndpointAccess, error) {
+	mode, _ := ctx.GetConfig("cluster:endpointAccess")
+	cidrs, _ := ctx.GetConfig("cluster:publicAccessCidrs")
+	tunnelPort, _ := ctx.GetConfig("cluster:apiTunnelPort")
+	return parseEndpointAccess(mode, cidrs, tunnelPort)
+}
+
+// tunnelKubeconfig points the kubeconfig at the local end of an SSM port forwarding session
+// while keeping TLS verification against the private endpoint's host name. The kubeconfig is shared with the
+// other readers of the cluster output, so the clusters are rewritten in a copy.
+func tunnelKubeconfig(kubeconfig map[string]interface{}, localPort int) (map[string]interface{}, error) {
+	clusters, ok := kubeconfig["clusters"].([]interface{})
+	if !ok || len(clusters) == 0 {
+		return nil, errors.New("kubeconfig has no clusters")
+	}
+	tunneled := make([]interface{}, 0, len(clusters))
+	for _, entry := range clusters {
+		named, ok := entry.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("kubeconfig cluster entry is malformed")
+		}
+		cluster, ok := named["cluster"].(map[string]interface{})
+		if !ok {
+			return nil, errors.New("kubeconfig cluster entry is malformed")
+		}
+		server, err := url.Parse(fmt.Sprint(cluster["server"]))
+		if err != nil || server.Hostname() == "" {
+			return nil, errors.New("kubeconfig cluster server is malformed")
+		}
+		tunneledCluster := copyMap(cluster)
+		tunneledCluster["tls-server-name"] = server.Hostname()
+		tunneledCluster["server"] = "https://127.0.0.1:" + strconv.Itoa(localPort)
+		tunneledEntry := copyMap(named)
+		tunneledEntry["cluster"] = tunneledCluster
+		tunneled = append(tunneled, tunneledEntry)
+	}
+	result := copyMap(kubeconfig)
+	result["clusters"] = tunneled
+	return result, nil
+}
+
+// copyMap returns a shallow copy of m.
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(m))
+	for key, value := range m {
+		copied[key] = value
+	}
+	return copied
+}
+
+// ApiJumpHost is the SSM managed instance used to reach a private API endpoint.
+type ApiJumpHost struct {
+	Instance *ec2.Instance
+	// TunnelCommand starts the port forwarding session kubeconfig files of private clusters point at
+	TunnelCommand pulumi.StringOutput
+}
+
+// createApiJumpHost creates an SSM managed instance without inbound rules that can forward a local port
+// to the private API endpoint, so no bastion or VPN is needed to reach a private only cluster.
+func createApiJumpHost(ctx *pulumi.Context, deployment *Deployment, network *Network, cluster *eks.Cluster, tunnelPort int, opts ...pulumi.ResourceOption) (*ApiJumpHost, error) {
+	imageId, err := lookupSSMParameter(ctx, "/aws/service/ami-amazon-linux-latest/al2023-ami-kernel-default-x86_64")
+	if err != nil {
+		return nil, err
+	}
+	assumeRolePolicy, err := newPolicyDocument(serviceTrustStatement(deployment.Partition.servicePrincipal("ec2"))).JSON()
+	if err != nil {
+		return nil, err
+	}
+	role, err := iam.NewRole(ctx, deployment.name("ApiJumpHostRole"), &iam.RoleArgs{
+		Name:              deployment.autoName(KIND_IAM_ROLE, "ApiJumpHostRole"),
+		AssumeRolePolicy:  pulumi.String(assumeRolePolicy),
+		ManagedPolicyArns: pulumi.ToStringArray(deployment.Partition.managedPolicyArns("AmazonSSMManagedInstanceCore")),
+		Tags:              deployment.tags(nil),
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	instanceProfile, err := iam.NewInstanceProfile(ctx, deployment.name("ApiJumpHostInstanceProfile"), &iam.InstanceProfileArgs{
+		Role: role.Name,
+		Tags: deployment.tags(nil),
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	securityGroup, err := ec2.NewSecurityGroup(ctx, deployment.name("ApiJumpHostSecurityGroup"), &ec2.SecurityGroupArgs{
+		Description: pulumi.String("SSM managed API jump host, no inbound access"),
+		VpcId:       network.Vpc.ID(),
+		Egress: ec2.SecurityGroupEgressArray{
+			&ec2.SecurityGroupEgressArgs{
+				CidrBlocks:  pulumi.StringArray{pulumi.String("0.0.0.0/0")},
+				Description: pulumi.String("Allow all outbound traffic"),
+				FromPort:    pulumi.Int(0),
+				ToPort:      pulumi.Int(0),
+				Protocol:    pulumi.String("-1"),
+			},
+		},
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	_, err = ec2.NewSecurityGroupRule(ctx, deployment.name("AllowApiFromJumpHost"), &ec2.SecurityGroupRuleArgs{
+		Description:           pulumi.String("Allow the API jump host to reach the private endpoint"),
+		FromPort:              pulumi.Int(443),
+		ToPort:                pulumi.Int(443),
+		Protocol:              pulumi.String("tcp"),
+		SecurityGroupId:       cluster.EksCluster.VpcConfig().ClusterSecurityGroupId().Elem(),
+		SourceSecurityGroupId: securityGroup.ID(),
+		Type:                  pulumi.String("ingress"),
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	jumpHost, err := ec2.NewInstance(ctx, deployment.name("ApiJumpHost"), &ec2.InstanceArgs{
+		Ami:                 pulumi.String(imageId),
+		InstanceType:        pulumi.String("t3.micro"),
+		SubnetId:            network.PrivateSubnets[0].ID(),
+		IamInstanceProfile:  instanceProfile.Name,
+		VpcSecurityGroupIds: pulumi.StringArray{securityGroup.ID()},
+		MetadataOptions: &ec2.InstanceMetadataOptionsArgs{
+			HttpTokens:              pulumi.String("required"),
+			HttpPutResponseHopLimit: pulumi.Int(1),
+		},
+		RootBlockDevice: &ec2.InstanceRootBlockDeviceArgs{
+			Encrypted: pulumi.Bool(true),
+		},
+		Tags: deployment.tags(pulumi.StringMap{
+			"Name": pulumi.String(deployment.name("ApiJumpHost")),
+		}),
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	tunnelCommand := pulumi.Sprintf(
+		"aws ssm start-session --region %s --target %s --document-name AWS-StartPortForwardingSessionToRemoteHost --parameters host=%s,portNumber=443,localPortNumber=%d",
+		deployment.Region, jumpHost.ID(), cluster.EksCluster.Endpoint().ApplyT(func(endpoint string) string {
+			server, err := url.Parse(endpoint)
+			if err != nil {
+				return endpoint
+			}
+			return server.Hostname()
+		}).(pulumi.StringOutput), tunnelPort)
+	return &ApiJumpHost{
+		Instance:      jumpHost,
+		TunnelCommand: tunnelCommand,
+	}, nil
+}
@@ -0,0 +1,83 @@
+package workload
+
+import "testing"
+
+func TestParseEndpointAccess(t *testing.T) {
+	tests := []struct {
+		mode    string
+		cidrs   string
+		private bool
+		public  bool
+		ok      bool
+	}{
+		{"", "", false, true, true},
+		{"public", `["203.0.113.0/24"]`, false, true, true},
+		{"private", "", true, false, true},
+		{"both", `["203.0.113.10/32"]`, true, true, true},
+		{"private", `["203.0.113.0/24"]`, false, false, false},
+		{"public", `["not-a-cidr"]`, false, false, false},
+		{"internal", "", false, false, false},
+	}
+	for _, test := range tests {
+		access, err := parseEndpointAccess(test.mode, test.cidrs, "")
+		if !test.ok {
+			if err == nil {
+				t.Errorf("%q %s: expected error", test.mode, test.cidrs)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q %s: unexpected error %v", test.mode, test.cidrs, err)
+			continue
+		}
+		if access.private() != test.private || access.public() != test.public {
+			t.Errorf("%q: private=%v public=%v", test.mode, access.private(), access.public())
+		}
+	}
+}
+
+func TestParseEndpointAccessTunnelPort(t *testing.T) {
+	access, err := parseEndpointAccess("private", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if access.TunnelPort != DEFAULT_API_TUNNEL_PORT {
+		t.Errorf("unexpected default tunnel port %d", access.TunnelPort)
+	}
+	if _, err := parseEndpointAccess("private", "", "70000"); err == nil {
+		t.Error("expected error for invalid tunnel port")
+	}
+}
+
+func TestTunnelKubeconfig(t *testing.T) {
+	kubeconfig := map[string]interface{}{
+		"clusters": []interface{}{
+			map[string]interface{}{
+				"name": "kubernetes",
+				"cluster": map[string]interface{}{
+					"server":                     "https://ABCDEF.gr7.us-east-1.eks.amazonaws.com",
+					"certificate-authority-data": "Q0E=",
+				},
+			},
+		},
+	}
+	got, err := tunnelKubeconfig(kubeconfig, 8443)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster := got["clusters"].([]interface{})[0].(map[string]interface{})["cluster"].(map[string]interface{})
+	if cluster["server"] != "https://127.0.0.1:8443" {
+		t.Errorf("unexpected server %v", cluster["server"])
+	}
+	if cluster["tls-server-name"] != "ABCDEF.gr7.us-east-1.eks.amazonaws.com" {
+		t.Errorf("unexpected tls-server-name %v", cluster["tls-server-name"])
+	}
+	// The exported kubeconfig is built from the same map and must keep the private endpoint
+	original := kubeconfig["clusters"].([]interface{})[0].(map[string]interface{})["cluster"].(map[string]interface{})
+	if original["server"] != "https://ABCDEF.gr7.us-east-1.eks.amazonaws.com" || original["tls-server-name"] != nil {
+		t.Errorf("the input kubeconfig was modified: %v", original)
+	}
+	if _, err := tunnelKubeconfig(map[string]interface{}{}, 8443); err == nil {
+		t.Error("expected error for kubeconfig without clusters")
+	}
+}
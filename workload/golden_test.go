@@ -0,0 +1,33 @@
+package workload
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// go test ./workload -update rewrites the golden files with the current output
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// assertGolden compares got with testdata/<name>.
+func assertGolden(t *testing.T, name string, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v, run go test ./workload -update to create it", err)
+	}
+	if got != string(want) {
+		t.Errorf("%s changed, run go test ./workload -update if that is intended\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
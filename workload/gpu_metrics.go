@@ -0,0 +1,573 @@
+package workload
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"text/template"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/cloudwatch"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apiextensions"
+	appsv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apps/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/helm/v3"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	rbacv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/rbac/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"gopkg.in/yaml.v3"
+)
+
+// Where the GPU metrics go, worker:gpuMetrics. Empty deploys no exporters.
+const (
+	GPU_METRICS_PROMETHEUS = "prometheus"
+	GPU_METRICS_CLOUDWATCH = "cloudwatch"
+)
+
+const (
+	// GPU_METRICS_NAMESPACE is the Kubernetes namespace of the exporters and the CloudWatch agent
+	GPU_METRICS_NAMESPACE = "gpu-metrics"
+	// GPU_METRICS_CLOUDWATCH_NAMESPACE is the CloudWatch namespace of the scraped metrics
+	GPU_METRICS_CLOUDWATCH_NAMESPACE = "XBeam/GPU"
+
+	DCGM_EXPORTER_CHART_VERSION = "3.4.0"
+	DCGM_EXPORTER_PORT          = 9400
+	WINDOWS_GPU_EXPORTER_PORT   = 9835
+	CLOUDWATCH_AGENT_IMAGE      = "public.ecr.aws/cloudwatch-agent/cloudwatch-agent:1.300034.0b498"
+)
+
+// gpuMetric is a metric both exporters publish, the Windows exporter uses the DCGM names where they exist.
+type gpuMetric struct {
+	Name  string
+	Title string
+	// Unit is the CloudWatch unit, empty for none
+	Unit string
+	// Stat aggregates the nodes of a node group on the dashboard
+	Stat string
+}
+
+var gpuMetrics = []gpuMetric{
+	{"DCGM_FI_DEV_GPU_UTIL", "GPU utilization (%)", "Percent", "Average"},
+	{"DCGM_FI_DEV_FB_USED", "GPU memory used (MiB)", "Megabytes", "Average"},
+	{"DCGM_FI_DEV_ENC_UTIL", "Encoder utilization (%)", "Percent", "Average"},
+	{"nvidia_encoder_sessions", "Encoder sessions", "Count", "Sum"},
+	{"DCGM_FI_DEV_GPU_TEMP", "GPU temperature (°C)", "", "Maximum"},
+}
+
+// gpuExporters are the scrape jobs, one per GPU pool. The pool is the NodeGroup dimension of the metrics.
+var gpuExporters = []struct {
+	Job  string
+	Pool string
+	Port int
+}{
+	{"dcgm-exporter", POOL_LINUX, DCGM_EXPORTER_PORT},
+	{"windows-gpu-exporter", POOL_WINDOWS, WINDOWS_GPU_EXPORTER_PORT},
+}
+
+// parseGpuMetricsSink validates worker:gpuMetrics.
+func parseGpuMetricsSink(raw string) (string, error) {
+	switch raw {
+	case "", GPU_METRICS_PROMETHEUS, GPU_METRICS_CLOUDWATCH:
+		return raw, nil
+	}
+	return "", fmt.Errorf("worker:gpuMetrics must be %s or %s", GPU_METRICS_PROMETHEUS, GPU_METRICS_CLOUDWATCH)
+}
+
+// LoadGpuMetricsSink reads worker:gpuMetrics, empty when the GPU metrics are off.
+func LoadGpuMetricsSink(ctx *pulumi.Context) (string, error) {
+	raw, _ := ctx.GetConfig("worker:gpuMetrics")
+	return parseGpuMetricsSink(raw)
+}
+
+// The Windows exporter serves the nvidia-smi readings of every GPU of the node in the Prometheus text format.
+var windowsGpuExporterTemplate = template.Must(template.New("windows-gpu-exporter").Parse(`$ErrorActionPreference = 'Stop'
+$smi = Join-Path $env:SystemRoot 'System32\nvidia-smi.exe'
+$metrics = [ordered]@{
+    'DCGM_FI_DEV_GPU_UTIL'       = @('utilization.gpu', 'GPU utilization in percent')
+    'DCGM_FI_DEV_FB_USED'        = @('memory.used', 'GPU memory used in MiB')
+    'DCGM_FI_DEV_ENC_UTIL'       = @('utilization.encoder', 'Encoder utilization in percent')
+    'nvidia_encoder_sessions'    = @('encoder.stats.sessionCount', 'Active encoder sessions')
+    'DCGM_FI_DEV_GPU_TEMP'       = @('temperature.gpu', 'GPU temperature in degrees Celsius')
+}
+$names = @($metrics.Keys)
+$fields = @('index', 'uuid', 'name') + ($names | ForEach-Object { $metrics[$_][0] })
+function Get-Metrics {
+    $lines = & $smi "--query-gpu=$($fields -join ',')" --format=csv,noheader,nounits
+    $output = [System.Text.StringBuilder]::new()
+    for ($i = 0; $i -lt $names.Count; $i++) {
+        $name = $names[$i]
+        [void]$output.AppendLine("# HELP $name $($metrics[$name][1])")
+        [void]$output.AppendLine("# TYPE $name gauge")
+        foreach ($line in $lines) {
+            $values = $line -split ',\s*'
+            # Readings the GPU or driver does not support are [N/A]
+            if ($values[3 + $i] -match '^[0-9.]+$') {
+                [void]$output.AppendLine("${name}{gpu=""$($values[0])"",UUID=""$($values[1])"",modelName=""$($values[2])"",Hostname=""$env:COMPUTERNAME""} $($values[3 + $i])")
+            }
+        }
+    }
+    $output.ToString()
+}
+$listener = [System.Net.HttpListener]::new()
+$listener.Prefixes.Add('http://+:{{.Port}}/')
+$listener.Start()
+while ($listener.IsListening) {
+    $context = $listener.GetContext()
+    try {
+        $body = [System.Text.Encoding]::UTF8.GetBytes((Get-Metrics))
+        $context.Response.ContentType = 'text/plain; version=0.0.4'
+        $context.Response.OutputStream.Write($body, 0, $body.Length)
+    } catch {
+        $context.Response.StatusCode = 500
+    } finally {
+        $context.Response.Close()
+    }
+}
+`))
+
+// renderWindowsGpuExporter returns the PowerShell script of the Windows exporter.
+func renderWindowsGpuExporter(port int) (string, error) {
+	return render(windowsGpuExporterTemplate, struct{ Port int }{port})
+}
+
+// renderGpuScrapeConfig returns the Prometheus scrape config of the CloudWatch agent. Every series gets the cluster,
+// the node group and the node as labels, the dimensions of the metrics.
+func renderGpuScrapeConfig(clusterName string) (string, error) {
+	jobs := []interface{}{}
+	for _, exporter := range gpuExporters {
+		jobs = append(jobs, map[string]interface{}{
+			"job_name":        exporter.Job,
+			"scrape_interval": "1m",
+			"kubernetes_sd_configs": []interface{}{map[string]interface{}{
+				"role":       "pod",
+				"namespaces": map[string]interface{}{"names": []string{GPU_METRICS_NAMESPACE}},
+			}},
+			"relabel_configs": []interface{}{
+				map[string]interface{}{
+					"source_labels": []string{"__meta_kubernetes_pod_label_app_kubernetes_io_name", "__meta_kubernetes_pod_container_port_number"},
+					"action":        "keep",
+					"regex":         fmt.Sprintf("%s;%d", exporter.Job, exporter.Port),
+				},
+				map[string]interface{}{"target_label": "ClusterName", "replacement": clusterName},
+				map[string]interface{}{"target_label": "NodeGroup", "replacement": exporter.Pool},
+				map[string]interface{}{"source_labels": []string{"__meta_kubernetes_pod_node_name"}, "target_label": "NodeName"},
+			},
+		})
+	}
+	bytes, err := yaml.Marshal(map[string]interface{}{
+		"global":         map[string]interface{}{"scrape_interval": "1m", "scrape_timeout": "10s"},
+		"scrape_configs": jobs,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+// renderCloudWatchAgentConfig returns the agent config that turns the scraped GPU metrics into CloudWatch metrics.
+func renderCloudWatchAgentConfig(region string, clusterName string) (string, error) {
+	selectors := []string{}
+	units := map[string]string{}
+	for _, metric := range gpuMetrics {
+		selectors = append(selectors, "^"+metric.Name+"$")
+		if metric.Unit != "" {
+			units[metric.Name] = metric.Unit
+		}
+	}
+	jobs := ""
+	for _, exporter := range gpuExporters {
+		if jobs != "" {
+			jobs += "|"
+		}
+		jobs += exporter.Job
+	}
+	config := map[string]interface{}{
+		"agent": map[string]interface{}{"region": region},
+		"logs": map[string]interface{}{
+			"metrics_collected": map[string]interface{}{
+				"prometheus": map[string]interface{}{
+					"cluster_name":           clusterName,
+					"log_group_name":         gpuMetricsLogGroupName(clusterName),
+					"prometheus_config_path": "/etc/prometheusconfig/prometheus.yaml",
+					"emf_processor": map[string]interface{}{
+						"metric_declaration_dedup": true,
+						"metric_namespace":         GPU_METRICS_CLOUDWATCH_NAMESPACE,
+						"metric_unit":              units,
+						"metric_declaration": []interface{}{map[string]interface{}{
+							"source_labels":    []string{"job"},
+							"label_matcher":    "^(" + jobs + ")$",
+							"dimensions":       [][]string{{"ClusterName", "NodeGroup"}, {"ClusterName", "NodeGroup", "NodeName"}},
+							"metric_selectors": selectors,
+						}},
+					},
+				},
+			},
+			"force_flush_interval": 5,
+		},
+	}
+	bytes, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(bytes) + "\n", nil
+}
+
+// gpuMetricsLogGroupName is the log group the CloudWatch agent writes the embedded metric format events into.
+func gpuMetricsLogGroupName(clusterName string) string {
+	return "/aws/containerinsights/" + clusterName + "/prometheus"
+}
+
+// renderGpuDashboard returns the body of a dashboard with a graph per metric and a line per GPU node group.
+func renderGpuDashboard(region string, clusterName string) (string, error) {
+	widgets := []interface{}{}
+	for i, metric := range gpuMetrics {
+		lines := []interface{}{}
+		for _, exporter := range gpuExporters {
+			lines = append(lines, []interface{}{
+				GPU_METRICS_CLOUDWATCH_NAMESPACE, metric.Name, "ClusterName", clusterName, "NodeGroup", exporter.Pool,
+				map[string]interface{}{"label": exporter.Pool, "stat": metric.Stat},
+			})
+		}
+		widgets = append(widgets, map[string]interface{}{
+			"type":   "metric",
+			"x":      (i % 2) * 12,
+			"y":      (i / 2) * 6,
+			"width":  12,
+			"height": 6,
+			"properties": map[string]interface{}{
+				"title":   metric.Title,
+				"region":  region,
+				"view":    "timeSeries",
+				"period":  60,
+				"metrics": lines,
+			},
+		})
+	}
+	bytes, err := json.MarshalIndent(map[string]interface{}{"widgets": widgets}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(bytes) + "\n", nil
+}
+
+type GpuMetricsArgs struct {
+	Deployment *Deployment
+	Cluster    *WorkloadCluster
+	// Sink is GPU_METRICS_PROMETHEUS or GPU_METRICS_CLOUDWATCH
+	Sink string
+	// LogRetentionDays keeps the metric events of the CloudWatch agent, 0 forever
+	LogRetentionDays int
+	// After holds the resources the exporters wait for, such as the release that installs the Prometheus Operator
+	After []pulumi.Resource
+}
+
+// GpuMetrics exports the GPU readings of both GPU pools: DCGM exporter on Linux, an nvidia-smi exporter on Windows.
+// With Prometheus, ServiceMonitors labelled xbeam/metrics=gpu let the Prometheus Operator scrape them. With CloudWatch,
+// an agent on the system pool scrapes them into the XBeam/GPU namespace and a dashboard graphs them per node group.
+type GpuMetrics struct {
+	pulumi.ResourceState
+
+	Namespace       *corev1.Namespace
+	DcgmExporter    *helm.Release
+	WindowsExporter *appsv1.DaemonSet
+	// Agent, LogGroup and Dashboard are only created for CloudWatch
+	Agent     *appsv1.Deployment
+	LogGroup  *cloudwatch.LogGroup
+	Dashboard *cloudwatch.Dashboard
+}
+
+func NewGpuMetrics(ctx *pulumi.Context, name string, args *GpuMetricsArgs, opts ...pulumi.ResourceOption) (*GpuMetrics, error) {
+	if args == nil || args.Deployment == nil || args.Cluster == nil {
+		return nil, errors.New("GpuMetrics requires a Deployment and a Cluster")
+	}
+	if args.Sink != GPU_METRICS_PROMETHEUS && args.Sink != GPU_METRICS_CLOUDWATCH {
+		return nil, fmt.Errorf("GpuMetrics sink must be %s or %s", GPU_METRICS_PROMETHEUS, GPU_METRICS_CLOUDWATCH)
+	}
+	if !validLogRetention(args.LogRetentionDays) {
+		return nil, fmt.Errorf("%d days is not a CloudWatch Logs retention period", args.LogRetentionDays)
+	}
+	deployment := args.Deployment
+	cluster := args.Cluster
+	prometheus := args.Sink == GPU_METRICS_PROMETHEUS
+	windowsExporter, err := renderWindowsGpuExporter(WINDOWS_GPU_EXPORTER_PORT)
+	if err != nil {
+		return nil, err
+	}
+	component := &GpuMetrics{}
+	err = ctx.RegisterComponentResource(TYPE_GPU_METRICS, name, component, opts...)
+	if err != nil {
+		return nil, err
+	}
+	childOpts := []pulumi.ResourceOption{pulumi.Parent(component)}
+	k8sOpts := childOptions(childOpts, pulumi.Provider(cluster.Provider))
+	component.Namespace, err = corev1.NewNamespace(ctx, name, &corev1.NamespaceArgs{
+		Metadata: metav1.ObjectMetaArgs{Name: pulumi.String(GPU_METRICS_NAMESPACE)},
+	}, k8sOpts...)
+	if err != nil {
+		return nil, err
+	}
+	namespacedOpts := childOptions(k8sOpts, pulumi.DependsOn(append([]pulumi.Resource{component.Namespace}, args.After...)))
+	component.DcgmExporter, err = helm.NewRelease(ctx, name+"-dcgm-exporter", &helm.ReleaseArgs{
+		Namespace: pulumi.String(GPU_METRICS_NAMESPACE),
+		Name:      pulumi.String("dcgm-exporter"),
+		RepositoryOpts: helm.RepositoryOptsArgs{
+			Repo: pulumi.String("https://nvidia.github.io/dcgm-exporter/helm-charts"),
+		},
+		Chart:   pulumi.String("dcgm-exporter"),
+		Version: pulumi.String(DCGM_EXPORTER_CHART_VERSION),
+		Values: pulumi.Map{
+			"affinity":    gpuNodeAffinity("linux"),
+			"tolerations": gpuTolerations(),
+			"serviceMonitor": pulumi.Map{
+				"enabled":          pulumi.Bool(prometheus),
+				"interval":         pulumi.String("1m"),
+				"additionalLabels": pulumi.StringMap{"xbeam/metrics": pulumi.String("gpu")},
+				"relabelings": pulumi.Array{
+					pulumi.Map{"targetLabel": pulumi.String("NodeGroup"), "replacement": pulumi.String(POOL_LINUX)},
+				},
+			},
+		},
+	}, namespacedOpts...)
+	if err != nil {
+		return nil, err
+	}
+	labels := pulumi.StringMap{"app.kubernetes.io/name": pulumi.String("windows-gpu-exporter")}
+	component.WindowsExporter, err = appsv1.NewDaemonSet(ctx, name+"-windows-exporter", &appsv1.DaemonSetArgs{
+		Metadata: metav1.ObjectMetaArgs{
+			Name:      pulumi.String("windows-gpu-exporter"),
+			Namespace: pulumi.String(GPU_METRICS_NAMESPACE),
+			Labels:    labels,
+		},
+		Spec: appsv1.DaemonSetSpecArgs{
+			Selector: metav1.LabelSelectorArgs{MatchLabels: labels},
+			Template: corev1.PodTemplateSpecArgs{
+				Metadata: metav1.ObjectMetaArgs{Labels: labels},
+				Spec: corev1.PodSpecArgs{
+					NodeSelector:    windowsGpuNodeSelector(),
+					Tolerations:     gpuTolerationArray(),
+					HostNetwork:     pulumi.Bool(true),
+					SecurityContext: hostProcessSecurityContext(),
+					Containers: corev1.ContainerArray{
+						corev1.ContainerArgs{
+							Name:    pulumi.String("exporter"),
+							Image:   pulumi.String(HOST_PROCESS_IMAGE),
+							Command: pulumi.StringArray{pulumi.String("powershell.exe"), pulumi.String("-NoProfile"), pulumi.String("-NonInteractive"), pulumi.String("-Command"), pulumi.String(windowsExporter)},
+							Ports: corev1.ContainerPortArray{
+								corev1.ContainerPortArgs{Name: pulumi.String("metrics"), ContainerPort: pulumi.Int(WINDOWS_GPU_EXPORTER_PORT)},
+							},
+						},
+					},
+				},
+			},
+		},
+	}, namespacedOpts...)
+	if err != nil {
+		return nil, err
+	}
+	if prometheus {
+		err = component.createServiceMonitor(ctx, name, labels, namespacedOpts...)
+	} else {
+		err = component.createCloudWatchAgent(ctx, name, deployment, cluster, args.LogRetentionDays, childOpts, namespacedOpts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	outputs := pulumi.Map{
+		"sink":      pulumi.String(args.Sink),
+		"namespace": pulumi.String(GPU_METRICS_NAMESPACE),
+	}
+	if component.Dashboard != nil {
+		outputs["dashboard"] = component.Dashboard.DashboardName
+	}
+	err = ctx.RegisterResourceOutputs(component, outputs)
+	if err != nil {
+		return nil, err
+	}
+	return component, nil
+}
+
+// createServiceMonitor lets the Prometheus Operator scrape the Windows exporter through a headless service.
+func (component *GpuMetrics) createServiceMonitor(ctx *pulumi.Context, name string, labels pulumi.StringMap, opts ...pulumi.ResourceOption) error {
+	service, err := corev1.NewService(ctx, name+"-windows-exporter", &corev1.ServiceArgs{
+		Metadata: metav1.ObjectMetaArgs{
+			Name:      pulumi.String("windows-gpu-exporter"),
+			Namespace: pulumi.String(GPU_METRICS_NAMESPACE),
+			Labels:    labels,
+		},
+		Spec: corev1.ServiceSpecArgs{
+			ClusterIP: pulumi.String("None"),
+			Selector:  labels,
+			Ports: corev1.ServicePortArray{
+				corev1.ServicePortArgs{Name: pulumi.String("metrics"), Port: pulumi.Int(WINDOWS_GPU_EXPORTER_PORT)},
+			},
+		},
+	}, opts...)
+	if err != nil {
+		return err
+	}
+	_, err = apiextensions.NewCustomResource(ctx, name+"-windows-exporter", &apiextensions.CustomResourceArgs{
+		ApiVersion: pulumi.String("monitoring.coreos.com/v1"),
+		Kind:       pulumi.String("ServiceMonitor"),
+		Metadata: metav1.ObjectMetaArgs{
+			Name:      pulumi.String("windows-gpu-exporter"),
+			Namespace: pulumi.String(GPU_METRICS_NAMESPACE),
+			Labels:    pulumi.StringMap{"xbeam/metrics": pulumi.String("gpu")},
+		},
+		OtherFields: kubernetes.UntypedArgs{
+			"spec": map[string]interface{}{
+				"selector": map[string]interface{}{"matchLabels": map[string]interface{}{"app.kubernetes.io/name": "windows-gpu-exporter"}},
+				"endpoints": []interface{}{map[string]interface{}{
+					"port":        "metrics",
+					"interval":    "1m",
+					"relabelings": []interface{}{map[string]interface{}{"targetLabel": "NodeGroup", "replacement": POOL_WINDOWS}},
+				}},
+			},
+		},
+	}, childOptions(opts, pulumi.DependsOn([]pulumi.Resource{service}))...)
+	return err
+}
+
+// createCloudWatchAgent runs a CloudWatch agent on the system pool that scrapes the exporters, with an IRSA role
+// allowed to put the metrics, and the dashboard of the metrics.
+func (component *GpuMetrics) createCloudWatchAgent(ctx *pulumi.Context, name string, deployment *Deployment, cluster *WorkloadCluster, retentionDays int, childOpts []pulumi.ResourceOption, k8sOpts []pulumi.ResourceOption) error {
+	clusterName := cluster.Name
+	scrapeConfig, err := renderGpuScrapeConfig(clusterName)
+	if err != nil {
+		return err
+	}
+	agentConfig, err := renderCloudWatchAgentConfig(deployment.Region, clusterName)
+	if err != nil {
+		return err
+	}
+	dashboard, err := renderGpuDashboard(deployment.Region, clusterName)
+	if err != nil {
+		return err
+	}
+	component.LogGroup, err = cloudwatch.NewLogGroup(ctx, deployment.name("GpuMetricsLogGroup", "WorkloadCluster"), &cloudwatch.LogGroupArgs{
+		Name:            pulumi.String(gpuMetricsLogGroupName(clusterName)),
+		RetentionInDays: pulumi.Int(retentionDays),
+		Tags:            deployment.tags(nil),
+	}, childOpts...)
+	if err != nil {
+		return err
+	}
+	role, err := createServiceAccountRole(ctx, deployment, deployment.name("GpuMetricsAgentRole"), cluster.Cluster,
+		GPU_METRICS_NAMESPACE, "cloudwatch-agent", deployment.Partition.managedPolicyArns("CloudWatchAgentServerPolicy"),
+		childOptions(childOpts, pulumi.DependsOn([]pulumi.Resource{cluster.Cluster}))...)
+	if err != nil {
+		return err
+	}
+	serviceAccount, err := corev1.NewServiceAccount(ctx, name+"-cloudwatch-agent", &corev1.ServiceAccountArgs{
+		Metadata: metav1.ObjectMetaArgs{
+			Name:      pulumi.String("cloudwatch-agent"),
+			Namespace: pulumi.String(GPU_METRICS_NAMESPACE),
+			Annotations: pulumi.StringMap{
+				"eks.amazonaws.com/role-arn":               role.Arn,
+				"eks.amazonaws.com/sts-regional-endpoints": pulumi.String("true"),
+			},
+		},
+	}, k8sOpts...)
+	if err != nil {
+		return err
+	}
+	clusterRole, err := rbacv1.NewClusterRole(ctx, name+"-cloudwatch-agent", &rbacv1.ClusterRoleArgs{
+		Rules: rbacv1.PolicyRuleArray{
+			rbacv1.PolicyRuleArgs{
+				ApiGroups: pulumi.StringArray{pulumi.String("")},
+				Resources: pulumi.ToStringArray([]string{"nodes", "nodes/proxy", "services", "endpoints", "pods"}),
+				Verbs:     pulumi.ToStringArray([]string{"get", "list", "watch"}),
+			},
+		},
+	}, k8sOpts...)
+	if err != nil {
+		return err
+	}
+	_, err = rbacv1.NewClusterRoleBinding(ctx, name+"-cloudwatch-agent", &rbacv1.ClusterRoleBindingArgs{
+		RoleRef: rbacv1.RoleRefArgs{
+			ApiGroup: pulumi.String("rbac.authorization.k8s.io"),
+			Kind:     pulumi.String("ClusterRole"),
+			Name:     clusterRole.Metadata.Name().Elem(),
+		},
+		Subjects: rbacv1.SubjectArray{
+			rbacv1.SubjectArgs{
+				Kind:      pulumi.String("ServiceAccount"),
+				Name:      pulumi.String("cloudwatch-agent"),
+				Namespace: pulumi.String(GPU_METRICS_NAMESPACE),
+			},
+		},
+	}, childOptions(k8sOpts, pulumi.DependsOn([]pulumi.Resource{serviceAccount}))...)
+	if err != nil {
+		return err
+	}
+	configMaps := map[string]*corev1.ConfigMap{}
+	for _, config := range []struct{ name, key, content string }{
+		{"prometheus-cwagentconfig", "cwagentconfig.json", agentConfig},
+		{"prometheus-config", "prometheus.yaml", scrapeConfig},
+	} {
+		configMaps[config.name], err = corev1.NewConfigMap(ctx, name+"-"+config.name, &corev1.ConfigMapArgs{
+			Metadata: metav1.ObjectMetaArgs{
+				Name:      pulumi.String(config.name),
+				Namespace: pulumi.String(GPU_METRICS_NAMESPACE),
+			},
+			Data: pulumi.StringMap{config.key: pulumi.String(config.content)},
+		}, k8sOpts...)
+		if err != nil {
+			return err
+		}
+	}
+	labels := pulumi.StringMap{"app.kubernetes.io/name": pulumi.String("cloudwatch-agent")}
+	component.Agent, err = appsv1.NewDeployment(ctx, name+"-cloudwatch-agent", &appsv1.DeploymentArgs{
+		Metadata: metav1.ObjectMetaArgs{
+			Name:      pulumi.String("cloudwatch-agent"),
+			Namespace: pulumi.String(GPU_METRICS_NAMESPACE),
+			Labels:    labels,
+		},
+		Spec: appsv1.DeploymentSpecArgs{
+			Replicas: pulumi.Int(1),
+			Selector: metav1.LabelSelectorArgs{MatchLabels: labels},
+			Template: corev1.PodTemplateSpecArgs{
+				// The checksum rolls the agent when its config changes
+				Metadata: metav1.ObjectMetaArgs{
+					Labels:      labels,
+					Annotations: pulumi.StringMap{"xbeam/config-checksum": pulumi.String(nameHash(agentConfig + scrapeConfig))},
+				},
+				Spec: corev1.PodSpecArgs{
+					ServiceAccountName: serviceAccount.Metadata.Name().Elem(),
+					NodeSelector:       pulumi.StringMap{"type": pulumi.String("system")},
+					Containers: corev1.ContainerArray{
+						corev1.ContainerArgs{
+							Name:  pulumi.String("cloudwatch-agent"),
+							Image: pulumi.String(CLOUDWATCH_AGENT_IMAGE),
+							Resources: corev1.ResourceRequirementsArgs{
+								Requests: pulumi.StringMap{"cpu": pulumi.String("100m"), "memory": pulumi.String("256Mi")},
+								Limits:   pulumi.StringMap{"cpu": pulumi.String("500m"), "memory": pulumi.String("512Mi")},
+							},
+							VolumeMounts: corev1.VolumeMountArray{
+								corev1.VolumeMountArgs{Name: pulumi.String("prometheus-cwagentconfig"), MountPath: pulumi.String("/etc/cwagentconfig")},
+								corev1.VolumeMountArgs{Name: pulumi.String("prometheus-config"), MountPath: pulumi.String("/etc/prometheusconfig")},
+							},
+						},
+					},
+					Volumes: corev1.VolumeArray{
+						corev1.VolumeArgs{
+							Name:      pulumi.String("prometheus-cwagentconfig"),
+							ConfigMap: corev1.ConfigMapVolumeSourceArgs{Name: configMaps["prometheus-cwagentconfig"].Metadata.Name()},
+						},
+						corev1.VolumeArgs{
+							Name:      pulumi.String("prometheus-config"),
+							ConfigMap: corev1.ConfigMapVolumeSourceArgs{Name: configMaps["prometheus-config"].Metadata.Name()},
+						},
+					},
+				},
+			},
+		},
+	}, childOptions(k8sOpts, pulumi.DependsOn([]pulumi.Resource{component.LogGroup}))...)
+	if err != nil {
+		return err
+	}
+	component.Dashboard, err = cloudwatch.NewDashboard(ctx, deployment.name("GpuDashboard", "WorkloadCluster"), &cloudwatch.DashboardArgs{
+		DashboardName: pulumi.String(deployment.name("GpuDashboard", "WorkloadCluster")),
+		DashboardBody: pulumi.String(dashboard),
+	}, childOpts...)
+	return err
+}
@@ -0,0 +1,85 @@
+package workload
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestGpuMetricsGolden(t *testing.T) {
+	exporter, err := renderWindowsGpuExporter(WINDOWS_GPU_EXPORTER_PORT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "gpumetrics/windows-gpu-exporter.ps1", exporter)
+	checkPowerShell(t, "<powershell>"+exporter+"</powershell>")
+	scrapeConfig, err := renderGpuScrapeConfig("dev-eks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "gpumetrics/prometheus.yaml", scrapeConfig)
+	agentConfig, err := renderCloudWatchAgentConfig("us-east-1", "dev-eks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "gpumetrics/cwagentconfig.json", agentConfig)
+	dashboard, err := renderGpuDashboard("us-east-1", "dev-eks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "gpumetrics/dashboard.json", dashboard)
+}
+
+// Every metric of the dashboard is published by the exporters and kept by the agent, per node group.
+func TestGpuDashboardMatchesAgent(t *testing.T) {
+	agentConfig, _ := renderCloudWatchAgentConfig("us-east-1", "dev-eks")
+	dashboard, _ := renderGpuDashboard("us-east-1", "dev-eks")
+	exporter, _ := renderWindowsGpuExporter(WINDOWS_GPU_EXPORTER_PORT)
+	var body struct {
+		Widgets []struct {
+			Properties struct {
+				Metrics [][]interface{} `json:"metrics"`
+			} `json:"properties"`
+		} `json:"widgets"`
+	}
+	if err := json.Unmarshal([]byte(dashboard), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Widgets) != len(gpuMetrics) {
+		t.Fatalf("expected a widget per metric, got %d", len(body.Widgets))
+	}
+	for _, widget := range body.Widgets {
+		pools := []string{}
+		for _, line := range widget.Properties.Metrics {
+			name := line[1].(string)
+			if line[0] != GPU_METRICS_CLOUDWATCH_NAMESPACE || !strings.Contains(agentConfig, `"^`+name+`$"`) || !strings.Contains(exporter, "'"+name+"'") {
+				t.Errorf("%s is not published in %s", name, GPU_METRICS_CLOUDWATCH_NAMESPACE)
+			}
+			pools = append(pools, line[5].(string))
+		}
+		if strings.Join(pools, ",") != POOL_LINUX+","+POOL_WINDOWS {
+			t.Errorf("expected a line per GPU pool, got %v", pools)
+		}
+	}
+	scrapeConfig, _ := renderGpuScrapeConfig("dev-eks")
+	var config map[string]interface{}
+	if err := yaml.Unmarshal([]byte(scrapeConfig), &config); err != nil {
+		t.Fatal(err)
+	}
+	if jobs := config["scrape_configs"].([]interface{}); len(jobs) != len(gpuExporters) {
+		t.Errorf("expected a scrape job per exporter, got %d", len(jobs))
+	}
+}
+
+func TestParseGpuMetricsSink(t *testing.T) {
+	for _, raw := range []string{"", GPU_METRICS_PROMETHEUS, GPU_METRICS_CLOUDWATCH} {
+		if sink, err := parseGpuMetricsSink(raw); err != nil || sink != raw {
+			t.Errorf("%q: unexpected %q %v", raw, sink, err)
+		}
+	}
+	if _, err := parseGpuMetricsSink("datadog"); err == nil {
+		t.Error("expected an unknown sink to fail")
+	}
+}
@@ -0,0 +1,339 @@
+package workload
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/pulumi/pulumi-eks/sdk/v2/go/eks"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	networkingv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/networking/v1"
+	rbacv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/rbac/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// GPU_RESOURCE is the extended resource the NVIDIA device plugin advertises
+const GPU_RESOURCE = "nvidia.com/gpu"
+
+// Container defaults of a tenant namespace without limits in its config
+const (
+	DEFAULT_TENANT_CPU_LIMIT      = "1"
+	DEFAULT_TENANT_MEMORY_LIMIT   = "2Gi"
+	DEFAULT_TENANT_CPU_REQUEST    = "250m"
+	DEFAULT_TENANT_MEMORY_REQUEST = "512Mi"
+)
+
+var (
+	dns1123Label = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+	quantity     = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?(m|k|M|G|T|P|E|Ki|Mi|Gi|Ti|Pi|Ei)?$`)
+)
+
+// Namespaces of the cluster services and of the ones the stack installs, no tenant may take them over
+var reservedNamespaces = []string{
+	"default", "kube-system", "kube-public", "kube-node-lease", "amazon-cloudwatch",
+	OBSERVABILITY_NAMESPACE, GPU_METRICS_NAMESPACE, FLUENT_BIT_NAMESPACE,
+}
+
+// TenantQuota caps the requests of a tenant namespace, empty fields are not limited.
+type TenantQuota struct {
+	Cpu    string `json:"cpu"`
+	Memory string `json:"memory"`
+	Gpus   *int   `json:"gpus"`
+	Pods   *int   `json:"pods"`
+}
+
+// TenantLimits are the default limits and requests of the containers that set none.
+type TenantLimits struct {
+	Cpu           string `json:"cpu"`
+	Memory        string `json:"memory"`
+	RequestCpu    string `json:"requestCpu"`
+	RequestMemory string `json:"requestMemory"`
+}
+
+// TenantConfig is an entry of the cluster:tenants config list. The name is the namespace of the tenant, the
+// members of the IAM role are bound to it.
+type TenantConfig struct {
+	Name    string       `json:"name"`
+	RoleArn string       `json:"roleArn"`
+	Quota   TenantQuota  `json:"quota"`
+	Limits  TenantLimits `json:"limits"`
+	// EgressCidrs are the networks outside the cluster the pods of the tenant may reach
+	EgressCidrs []string `json:"egressCidrs"`
+}
+
+// ParseTenantConfigs decodes a cluster:tenants JSON list.
+func ParseTenantConfigs(raw string) ([]TenantConfig, error) {
+	tenants := []TenantConfig{}
+	if raw == "" {
+		return tenants, nil
+	}
+	decoder := json.NewDecoder(strings.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&tenants); err != nil {
+		return nil, fmt.Errorf("invalid cluster:tenants: %w", err)
+	}
+	names := map[string]bool{}
+	for i := range tenants {
+		tenant := &tenants[i]
+		if err := tenant.validate(); err != nil {
+			return nil, fmt.Errorf("invalid tenant %q in cluster:tenants: %w", tenant.Name, err)
+		}
+		if names[tenant.Name] {
+			return nil, errors.New("duplicate tenant in cluster:tenants: " + tenant.Name)
+		}
+		names[tenant.Name] = true
+		tenant.Limits.defaults()
+	}
+	return tenants, nil
+}
+
+// LoadTenantConfigs reads the cluster:tenants config list.
+func LoadTenantConfigs(ctx *pulumi.Context) ([]TenantConfig, error) {
+	raw, _ := ctx.GetConfig("cluster:tenants")
+	return ParseTenantConfigs(raw)
+}
+
+func (t *TenantConfig) validate() error {
+	if len(t.Name) > 63 || !dns1123Label.MatchString(t.Name) {
+		return errors.New("the name must be a DNS label of at most 63 characters")
+	}
+	for _, reserved := range reservedNamespaces {
+		if t.Name == reserved {
+			return errors.New("the namespace is reserved for the cluster services")
+		}
+	}
+	if !strings.HasPrefix(t.RoleArn, "arn:") || !strings.Contains(t.RoleArn, ":role/") {
+		return errors.New("roleArn must be the ARN of an IAM role")
+	}
+	for _, field := range []struct{ name, value string }{
+		{"quota.cpu", t.Quota.Cpu},
+		{"quota.memory", t.Quota.Memory},
+		{"limits.cpu", t.Limits.Cpu},
+		{"limits.memory", t.Limits.Memory},
+		{"limits.requestCpu", t.Limits.RequestCpu},
+		{"limits.requestMemory", t.Limits.RequestMemory},
+	} {
+		if field.value != "" && !quantity.MatchString(field.value) {
+			return fmt.Errorf("%s must be a Kubernetes quantity", field.name)
+		}
+	}
+	for _, field := range []struct {
+		name  string
+		value *int
+	}{{"quota.gpus", t.Quota.Gpus}, {"quota.pods", t.Quota.Pods}} {
+		if field.value != nil && *field.value < 0 {
+			return fmt.Errorf("%s must not be negative", field.name)
+		}
+	}
+	for _, cidr := range t.EgressCidrs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("invalid CIDR %q in egressCidrs", cidr)
+		}
+	}
+	return nil
+}
+
+func (l *TenantLimits) defaults() {
+	for _, field := range []struct {
+		value    *string
+		fallback string
+	}{
+		{&l.Cpu, DEFAULT_TENANT_CPU_LIMIT},
+		{&l.Memory, DEFAULT_TENANT_MEMORY_LIMIT},
+		{&l.RequestCpu, DEFAULT_TENANT_CPU_REQUEST},
+		{&l.RequestMemory, DEFAULT_TENANT_MEMORY_REQUEST},
+	} {
+		if *field.value == "" {
+			*field.value = field.fallback
+		}
+	}
+}
+
+// group is the Kubernetes group aws-auth maps the role of the tenant to.
+func (t TenantConfig) group() string {
+	return "xbeam:tenant:" + t.Name
+}
+
+// hard lists the quota of the namespace. Extended resources are only limited by their requests.
+func (q TenantQuota) hard() map[string]string {
+	hard := map[string]string{}
+	if q.Cpu != "" {
+		hard["requests.cpu"] = q.Cpu
+	}
+	if q.Memory != "" {
+		hard["requests.memory"] = q.Memory
+	}
+	if q.Gpus != nil {
+		hard["requests."+GPU_RESOURCE] = fmt.Sprint(*q.Gpus)
+	}
+	if q.Pods != nil {
+		hard["pods"] = fmt.Sprint(*q.Pods)
+	}
+	return hard
+}
+
+// tenantRoleMappings map the IAM role of every tenant to its group.
+func tenantRoleMappings(tenants []TenantConfig) eks.RoleMappingArray {
+	mappings := eks.RoleMappingArray{}
+	for _, tenant := range tenants {
+		mappings = append(mappings, &eks.RoleMappingArgs{
+			Groups:   pulumi.StringArray{pulumi.String(tenant.group())},
+			RoleArn:  pulumi.String(tenant.RoleArn),
+			Username: pulumi.String("xbeam-tenant-" + tenant.Name + ":{{SessionName}}"),
+		})
+	}
+	return mappings
+}
+
+// tenantRoleRules let a tenant run workloads in its namespace. The quota, limit range and network policies stay
+// read only, so the tenant cannot lift its own limits.
+var tenantRoleRules = []struct {
+	apiGroups, resources, verbs []string
+}{
+	{[]string{""}, []string{"pods", "pods/log", "pods/exec", "pods/portforward", "services", "endpoints", "configmaps", "secrets",
+		"persistentvolumeclaims", "serviceaccounts"}, []string{"*"}},
+	{[]string{""}, []string{"events", "resourcequotas", "limitranges"}, []string{"get", "list", "watch"}},
+	{[]string{"apps"}, []string{"deployments", "statefulsets", "daemonsets", "replicasets"}, []string{"*"}},
+	{[]string{"batch"}, []string{"jobs", "cronjobs"}, []string{"*"}},
+	{[]string{"autoscaling"}, []string{"horizontalpodautoscalers"}, []string{"*"}},
+	{[]string{"policy"}, []string{"poddisruptionbudgets"}, []string{"*"}},
+	{[]string{"networking.k8s.io"}, []string{"networkpolicies"}, []string{"get", "list", "watch"}},
+}
+
+func createTenantNamespace(ctx *pulumi.Context, name string, tenant TenantConfig, opts ...pulumi.ResourceOption) (*corev1.Namespace, error) {
+	return corev1.NewNamespace(ctx, name, &corev1.NamespaceArgs{
+		Metadata: metav1.ObjectMetaArgs{
+			Name: pulumi.String(tenant.Name),
+			Labels: pulumi.StringMap{
+				"xbeam/tenant": pulumi.String(tenant.Name),
+			},
+		},
+	}, opts...)
+}
+
+func createTenantResourceQuota(ctx *pulumi.Context, name string, tenant TenantConfig, opts ...pulumi.ResourceOption) (*corev1.ResourceQuota, error) {
+	return corev1.NewResourceQuota(ctx, name+"-quota", &corev1.ResourceQuotaArgs{
+		Metadata: metav1.ObjectMetaArgs{
+			Name:      pulumi.String("tenant-quota"),
+			Namespace: pulumi.String(tenant.Name),
+		},
+		Spec: corev1.ResourceQuotaSpecArgs{
+			Hard: pulumi.ToStringMap(tenant.Quota.hard()),
+		},
+	}, opts...)
+}
+
+func createTenantLimitRange(ctx *pulumi.Context, name string, tenant TenantConfig, opts ...pulumi.ResourceOption) (*corev1.LimitRange, error) {
+	return corev1.NewLimitRange(ctx, name+"-limits", &corev1.LimitRangeArgs{
+		Metadata: metav1.ObjectMetaArgs{
+			Name:      pulumi.String("tenant-limits"),
+			Namespace: pulumi.String(tenant.Name),
+		},
+		Spec: corev1.LimitRangeSpecArgs{
+			Limits: corev1.LimitRangeItemArray{
+				corev1.LimitRangeItemArgs{
+					Type: pulumi.String("Container"),
+					Default: pulumi.StringMap{
+						"cpu":    pulumi.String(tenant.Limits.Cpu),
+						"memory": pulumi.String(tenant.Limits.Memory),
+					},
+					DefaultRequest: pulumi.StringMap{
+						"cpu":    pulumi.String(tenant.Limits.RequestCpu),
+						"memory": pulumi.String(tenant.Limits.RequestMemory),
+					},
+				},
+			},
+		},
+	}, opts...)
+}
+
+// createTenantNetworkPolicy denies all traffic of the namespace but the one between its own pods, DNS and the
+// egress CIDRs of the tenant.
+func createTenantNetworkPolicy(ctx *pulumi.Context, name string, tenant TenantConfig, opts ...pulumi.ResourceOption) (*networkingv1.NetworkPolicy, error) {
+	sameNamespace := networkingv1.NetworkPolicyPeerArray{
+		networkingv1.NetworkPolicyPeerArgs{PodSelector: metav1.LabelSelectorArgs{}},
+	}
+	egress := networkingv1.NetworkPolicyEgressRuleArray{
+		networkingv1.NetworkPolicyEgressRuleArgs{To: sameNamespace},
+		networkingv1.NetworkPolicyEgressRuleArgs{
+			To: networkingv1.NetworkPolicyPeerArray{
+				networkingv1.NetworkPolicyPeerArgs{
+					NamespaceSelector: metav1.LabelSelectorArgs{
+						MatchLabels: pulumi.StringMap{"kubernetes.io/metadata.name": pulumi.String("kube-system")},
+					},
+					PodSelector: metav1.LabelSelectorArgs{
+						MatchLabels: pulumi.StringMap{"k8s-app": pulumi.String("kube-dns")},
+					},
+				},
+			},
+			Ports: networkingv1.NetworkPolicyPortArray{
+				networkingv1.NetworkPolicyPortArgs{Protocol: pulumi.String("UDP"), Port: pulumi.Int(53)},
+				networkingv1.NetworkPolicyPortArgs{Protocol: pulumi.String("TCP"), Port: pulumi.Int(53)},
+			},
+		},
+	}
+	if len(tenant.EgressCidrs) > 0 {
+		peers := networkingv1.NetworkPolicyPeerArray{}
+		for _, cidr := range tenant.EgressCidrs {
+			peers = append(peers, networkingv1.NetworkPolicyPeerArgs{IpBlock: networkingv1.IPBlockArgs{Cidr: pulumi.String(cidr)}})
+		}
+		egress = append(egress, networkingv1.NetworkPolicyEgressRuleArgs{To: peers})
+	}
+	return networkingv1.NewNetworkPolicy(ctx, name+"-default-deny", &networkingv1.NetworkPolicyArgs{
+		Metadata: metav1.ObjectMetaArgs{
+			Name:      pulumi.String("default-deny"),
+			Namespace: pulumi.String(tenant.Name),
+		},
+		Spec: networkingv1.NetworkPolicySpecArgs{
+			PodSelector: metav1.LabelSelectorArgs{},
+			PolicyTypes: pulumi.StringArray{pulumi.String("Ingress"), pulumi.String("Egress")},
+			Ingress: networkingv1.NetworkPolicyIngressRuleArray{
+				networkingv1.NetworkPolicyIngressRuleArgs{From: sameNamespace},
+			},
+			Egress: egress,
+		},
+	}, opts...)
+}
+
+func createTenantRole(ctx *pulumi.Context, name string, tenant TenantConfig, opts ...pulumi.ResourceOption) (*rbacv1.Role, error) {
+	rules := rbacv1.PolicyRuleArray{}
+	for _, rule := range tenantRoleRules {
+		rules = append(rules, rbacv1.PolicyRuleArgs{
+			ApiGroups: pulumi.ToStringArray(rule.apiGroups),
+			Resources: pulumi.ToStringArray(rule.resources),
+			Verbs:     pulumi.ToStringArray(rule.verbs),
+		})
+	}
+	return rbacv1.NewRole(ctx, name+"-role", &rbacv1.RoleArgs{
+		Metadata: metav1.ObjectMetaArgs{
+			Name:      pulumi.String("tenant"),
+			Namespace: pulumi.String(tenant.Name),
+		},
+		Rules: rules,
+	}, opts...)
+}
+
+func createTenantRoleBinding(ctx *pulumi.Context, name string, tenant TenantConfig, role *rbacv1.Role, opts ...pulumi.ResourceOption) (*rbacv1.RoleBinding, error) {
+	return rbacv1.NewRoleBinding(ctx, name+"-role-binding", &rbacv1.RoleBindingArgs{
+		Metadata: metav1.ObjectMetaArgs{
+			Name:      pulumi.String("tenant"),
+			Namespace: pulumi.String(tenant.Name),
+		},
+		RoleRef: rbacv1.RoleRefArgs{
+			ApiGroup: pulumi.String("rbac.authorization.k8s.io"),
+			Kind:     pulumi.String("Role"),
+			Name:     role.Metadata.Name().Elem(),
+		},
+		Subjects: rbacv1.SubjectArray{
+			rbacv1.SubjectArgs{
+				ApiGroup: pulumi.String("rbac.authorization.k8s.io"),
+				Kind:     pulumi.String("Group"),
+				Name:     pulumi.String(tenant.group()),
+			},
+		},
+	}, opts...)
+}
@@ -0,0 +1,76 @@
+package workload
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTenantConfigs(t *testing.T) {
+	tenants, err := ParseTenantConfigs(`[
+		{"name":"team-a","roleArn":"arn:aws:iam::123456789012:role/team-a","quota":{"cpu":"16","memory":"64Gi","gpus":2,"pods":40},
+		 "limits":{"cpu":"2"},"egressCidrs":["0.0.0.0/0"]},
+		{"name":"team-b","roleArn":"arn:aws-us-gov:iam::123456789012:role/team-b"}
+	]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tenants) != 2 {
+		t.Fatalf("expected two tenants, got %d", len(tenants))
+	}
+	expected := map[string]string{"requests.cpu": "16", "requests.memory": "64Gi", "requests.nvidia.com/gpu": "2", "pods": "40"}
+	if hard := tenants[0].Quota.hard(); !reflect.DeepEqual(hard, expected) {
+		t.Errorf("expected quota %v, got %v", expected, hard)
+	}
+	if limits := tenants[0].Limits; limits.Cpu != "2" || limits.Memory != DEFAULT_TENANT_MEMORY_LIMIT || limits.RequestCpu != DEFAULT_TENANT_CPU_REQUEST {
+		t.Errorf("expected the limits to be completed with the defaults, got %+v", limits)
+	}
+	if hard := tenants[1].Quota.hard(); len(hard) != 0 {
+		t.Errorf("expected no quota without a quota config, got %v", hard)
+	}
+	if tenants[1].group() != "xbeam:tenant:team-b" {
+		t.Errorf("unexpected group %s", tenants[1].group())
+	}
+	if tenants, err := ParseTenantConfigs(""); err != nil || len(tenants) != 0 {
+		t.Errorf("expected no tenants, got %v %v", tenants, err)
+	}
+}
+
+func TestParseTenantConfigsRejectsInvalidTenants(t *testing.T) {
+	role := `"roleArn":"arn:aws:iam::123456789012:role/team"`
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{`[{"name":"Team_A",` + role + `}]`, "DNS label"},
+		{`[{"name":"kube-system",` + role + `}]`, "reserved"},
+		{`[{"name":"monitoring",` + role + `}]`, "reserved"},
+		{`[{"name":"gpu-metrics",` + role + `}]`, "reserved"},
+		{`[{"name":"fluent-bit",` + role + `}]`, "reserved"},
+		{`[{"name":"team-a","roleArn":"arn:aws:iam::123456789012:user/team"}]`, "IAM role"},
+		{`[{"name":"team-a",` + role + `,"quota":{"memory":"64 GB"}}]`, "quota.memory"},
+		{`[{"name":"team-a",` + role + `,"quota":{"gpus":-1}}]`, "quota.gpus"},
+		{`[{"name":"team-a",` + role + `,"egressCidrs":["10.0.0.0"]}]`, "egressCidrs"},
+		{`[{"name":"team-a",` + role + `},{"name":"team-a",` + role + `}]`, "duplicate"},
+		{`[{"name":"team-a",` + role + `,"quotas":{}}]`, "unknown field"},
+	}
+	for _, test := range tests {
+		_, err := ParseTenantConfigs(test.raw)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: expected an error about %s, got %v", test.raw, test.want, err)
+		}
+	}
+}
+
+func TestTenantRoleRulesKeepLimitsReadOnly(t *testing.T) {
+	for _, rule := range tenantRoleRules {
+		for _, resource := range rule.resources {
+			switch resource {
+			case "resourcequotas", "limitranges", "networkpolicies":
+				if !reflect.DeepEqual(rule.verbs, []string{"get", "list", "watch"}) {
+					t.Errorf("tenants must not change %s, got verbs %v", resource, rule.verbs)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package workload
+
+import (
+	"errors"
+)
+
+// KubeconfigArgs describe a kubeconfig that authenticates with aws eks get-token.
+type KubeconfigArgs struct {
+	ClusterName string
+	Endpoint    string
+	// CertificateAuthority is the base64 encoded CA data of the cluster
+	CertificateAuthority string
+	Region               string
+	// ContextName names the cluster, user and context entries, the cluster name when empty
+	ContextName string
+	// RoleArn is assumed by aws eks get-token when set
+	RoleArn string
+	// Profile is the AWS CLI profile of aws eks get-token when set
+	Profile string
+	// Namespace is the default namespace of the context when set
+	Namespace string
+	// TunnelPort points the kubeconfig at the SSM tunnel of a private endpoint when set
+	TunnelPort int
+}
+
+// NewKubeconfig returns the kubeconfig as an object, ready to be merged into another kubeconfig or marshalled.
+func NewKubeconfig(args KubeconfigArgs) (map[string]interface{}, error) {
+	if args.ClusterName == "" || args.Endpoint == "" || args.CertificateAuthority == "" || args.Region == "" {
+		return nil, errors.New("the kubeconfig needs the cluster name, endpoint, certificate authority and region")
+	}
+	name := args.ContextName
+	if name == "" {
+		name = args.ClusterName
+	}
+	command := []interface{}{"--region", args.Region, "eks", "get-token", "--cluster-name", args.ClusterName, "--output", "json"}
+	if args.RoleArn != "" {
+		command = append(command, "--role-arn", args.RoleArn)
+	}
+	exec := map[string]interface{}{
+		"apiVersion":         "client.authentication.k8s.io/v1beta1",
+		"command":            "aws",
+		"args":               command,
+		"interactiveMode":    "Never",
+		"provideClusterInfo": false,
+	}
+	if args.Profile != "" {
+		exec["env"] = []interface{}{map[string]interface{}{"name": "AWS_PROFILE", "value": args.Profile}}
+	}
+	context := map[string]interface{}{"cluster": name, "user": name}
+	if args.Namespace != "" {
+		context["namespace"] = args.Namespace
+	}
+	kubeconfig := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Config",
+		"clusters": []interface{}{map[string]interface{}{
+			"name": name,
+			"cluster": map[string]interface{}{
+				"server":                     args.Endpoint,
+				"certificate-authority-data": args.CertificateAuthority,
+			},
+		}},
+		"users": []interface{}{map[string]interface{}{
+			"name": name,
+			"user": map[string]interface{}{"exec": exec},
+		}},
+		"contexts": []interface{}{map[string]interface{}{
+			"name":    name,
+			"context": context,
+		}},
+		"current-context": name,
+	}
+	if args.TunnelPort != 0 {
+		return tunnelKubeconfig(kubeconfig, args.TunnelPort)
+	}
+	return kubeconfig, nil
+}
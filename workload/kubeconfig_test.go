@@ -0,0 +1,51 @@
+package workload
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func testKubeconfigArgs() KubeconfigArgs {
+	return KubeconfigArgs{
+		ClusterName:          "dev-eks",
+		Endpoint:             "https://0123456789ABCDEF.gr7.us-east-1.eks.amazonaws.com",
+		CertificateAuthority: "LS0tLS1CRUdJTiBDRVJUSUZJQ0FURS0tLS0tCg==",
+		Region:               "us-east-1",
+	}
+}
+
+func kubeconfigJSON(t *testing.T, args KubeconfigArgs) string {
+	t.Helper()
+	kubeconfig, err := NewKubeconfig(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bytes, err := json.MarshalIndent(kubeconfig, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(bytes) + "\n"
+}
+
+func TestNewKubeconfigGolden(t *testing.T) {
+	assertGolden(t, "kubeconfig/default.json", kubeconfigJSON(t, testKubeconfigArgs()))
+	args := testKubeconfigArgs()
+	args.ContextName = "xbeam-dev"
+	args.RoleArn = "arn:aws:iam::123456789012:role/eks-admin"
+	args.Profile = "xbeam"
+	args.TunnelPort = DEFAULT_API_TUNNEL_PORT
+	assertGolden(t, "kubeconfig/role-profile-tunnel.json", kubeconfigJSON(t, args))
+	args = testKubeconfigArgs()
+	args.ContextName = "dev-eks-team-a"
+	args.RoleArn = "arn:aws:iam::123456789012:role/team-a"
+	args.Namespace = "team-a"
+	assertGolden(t, "kubeconfig/tenant.json", kubeconfigJSON(t, args))
+}
+
+func TestNewKubeconfigRequiresCluster(t *testing.T) {
+	args := testKubeconfigArgs()
+	args.Region = ""
+	if _, err := NewKubeconfig(args); err == nil {
+		t.Error("expected a kubeconfig without a region to fail")
+	}
+}
@@ -0,0 +1,77 @@
+package workload
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const (
+	POOL_SYSTEM  = "system"
+	POOL_LINUX   = "linux"
+	POOL_WINDOWS = "windows"
+
+	// Pods use IRSA, so only host network processes need the node's metadata endpoint
+	DEFAULT_METADATA_HOP_LIMIT = 1
+)
+
+// MetadataOptions is the instance metadata service configuration of a worker pool. IMDSv2 is always required.
+type MetadataOptions struct {
+	HopLimit int
+	Tags     bool
+}
+
+// DefaultMetadataOptions is used for pools without worker:metadata configuration.
+func DefaultMetadataOptions() MetadataOptions {
+	return MetadataOptions{
+		HopLimit: DEFAULT_METADATA_HOP_LIMIT,
+	}
+}
+
+func parseMetadataHopLimit(key string, raw string) (int, error) {
+	hopLimit, err := strconv.Atoi(raw)
+	if err != nil || hopLimit < 1 || hopLimit > 64 {
+		return 0, fmt.Errorf("%s must be a number between 1 and 64", key)
+	}
+	return hopLimit, nil
+}
+
+// LoadMetadataOptions reads worker:metadataHopLimit and worker:metadataTags, overridden per pool by
+// worker:<pool>MetadataHopLimit and worker:<pool>MetadataTags.
+func LoadMetadataOptions(ctx *pulumi.Context, pool string) (MetadataOptions, error) {
+	options := DefaultMetadataOptions()
+	for _, prefix := range []string{"worker:metadata", "worker:" + pool + "Metadata"} {
+		if raw, ok := ctx.GetConfig(prefix + "HopLimit"); ok {
+			hopLimit, err := parseMetadataHopLimit(prefix+"HopLimit", raw)
+			if err != nil {
+				return options, err
+			}
+			options.HopLimit = hopLimit
+		}
+		if raw, ok := ctx.GetConfig(prefix + "Tags"); ok {
+			options.Tags = raw == "true"
+		}
+	}
+	return options, nil
+}
+
+func (m MetadataOptions) launchTemplateArgs() *ec2.LaunchTemplateMetadataOptionsArgs {
+	instanceMetadataTags := "disabled"
+	if m.Tags {
+		instanceMetadataTags = "enabled"
+	}
+	return &ec2.LaunchTemplateMetadataOptionsArgs{
+		HttpEndpoint:            pulumi.String("enabled"),
+		HttpTokens:              pulumi.String("required"),
+		HttpPutResponseHopLimit: pulumi.Int(m.HopLimit),
+		InstanceMetadataTags:    pulumi.String(instanceMetadataTags),
+	}
+}
+
+// createLaunchTemplate is used for every worker launch template so none of them can be created with IMDSv1 enabled.
+func createLaunchTemplate(ctx *pulumi.Context, name string, args *ec2.LaunchTemplateArgs, metadata MetadataOptions, opts ...pulumi.ResourceOption) (*ec2.LaunchTemplate, error) {
+	args.MetadataOptions = metadata.launchTemplateArgs()
+	return ec2.NewLaunchTemplate(ctx, name, args, opts...)
+}
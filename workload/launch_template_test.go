@@ -0,0 +1,65 @@
+package workload
+
+import (
+	"testing"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+func TestLaunchTemplatesRequireIMDSv2(t *testing.T) {
+	t.Setenv(pulumi.EnvConfig, `{"worker:metadataHopLimit":"2","worker:windowsMetadataHopLimit":"1","worker:linuxMetadataTags":"true"}`)
+	m := &mocks{}
+	want := map[string]MetadataOptions{
+		POOL_SYSTEM:  {HopLimit: 2},
+		POOL_LINUX:   {HopLimit: 2, Tags: true},
+		POOL_WINDOWS: {HopLimit: 1},
+	}
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		for _, pool := range []string{POOL_SYSTEM, POOL_LINUX, POOL_WINDOWS} {
+			options, err := LoadMetadataOptions(ctx, pool)
+			if err != nil {
+				return err
+			}
+			if options != want[pool] {
+				t.Errorf("%s: got %+v, want %+v", pool, options, want[pool])
+			}
+			_, err = createLaunchTemplate(ctx, pool, &ec2.LaunchTemplateArgs{}, options)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}, pulumi.WithMocks("infra", "test", m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	templates := m.byType("aws:ec2/launchTemplate:LaunchTemplate")
+	if len(templates) != 3 {
+		t.Fatalf("expected 3 launch templates, got %d", len(templates))
+	}
+	for _, template := range templates {
+		metadata := template.Inputs["metadataOptions"].ObjectValue()
+		if got := metadata["httpTokens"].StringValue(); got != "required" {
+			t.Errorf("%s: httpTokens = %s, want required", template.Name, got)
+		}
+		if got := int(metadata["httpPutResponseHopLimit"].NumberValue()); got != want[template.Name].HopLimit {
+			t.Errorf("%s: hop limit = %d, want %d", template.Name, got, want[template.Name].HopLimit)
+		}
+		wantTags := "disabled"
+		if want[template.Name].Tags {
+			wantTags = "enabled"
+		}
+		if got := metadata["instanceMetadataTags"].StringValue(); got != wantTags {
+			t.Errorf("%s: instanceMetadataTags = %s, want %s", template.Name, got, wantTags)
+		}
+	}
+}
+
+func TestParseMetadataHopLimit(t *testing.T) {
+	for _, raw := range []string{"0", "65", "two"} {
+		if _, err := parseMetadataHopLimit("worker:metadataHopLimit", raw); err == nil {
+			t.Errorf("expected error for hop limit %q", raw)
+		}
+	}
+}
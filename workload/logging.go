@@ -0,0 +1,275 @@
+package workload
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/cloudwatch"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/kinesis"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/kms"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+var clusterLogTypes = []string{"api", "audit", "authenticator", "controllerManager", "scheduler"}
+var defaultClusterLogTypes = []string{"api", "audit", "authenticator"}
+
+// Retention values accepted by CloudWatch Logs, 0 keeps logs forever
+var logRetentionDays = []int{0, 1, 3, 5, 7, 14, 30, 60, 90, 120, 150, 180, 365, 400, 545, 731, 1096, 1827, 2192, 2557, 2922, 3288, 3653}
+
+const DEFAULT_LOG_RETENTION_DAYS = 90
+
+// ControlPlaneLogging is the stack managed log group EKS writes control plane logs into.
+type ControlPlaneLogging struct {
+	LogTypes []string
+	LogGroup *cloudwatch.LogGroup
+}
+
+// LoggingConfig is the control plane logging configuration, an empty KmsKeyArn creates a key for the log group.
+type LoggingConfig struct {
+	LogTypes          []string
+	RetentionDays     int
+	KmsKeyArn         string
+	AuditLogBucketArn string
+}
+
+// NewLoggingConfig validates a logging configuration, nil logTypes selects the default log types.
+func NewLoggingConfig(logTypes []string, retentionDays int, kmsKeyArn string, auditLogBucketArn string) (LoggingConfig, error) {
+	if logTypes == nil {
+		logTypes = defaultClusterLogTypes
+	}
+	for _, logType := range logTypes {
+		if !containsString(clusterLogTypes, logType) {
+			return LoggingConfig{}, fmt.Errorf("unsupported log type %q in eks:logTypes", logType)
+		}
+	}
+	if !validLogRetention(retentionDays) {
+		return LoggingConfig{}, fmt.Errorf("eks:logRetentionDays %d is not a CloudWatch Logs retention period", retentionDays)
+	}
+	if auditLogBucketArn != "" && !containsString(logTypes, "audit") {
+		return LoggingConfig{}, errors.New("eks:auditLogBucketArn requires audit in eks:logTypes")
+	}
+	return LoggingConfig{
+		LogTypes:          logTypes,
+		RetentionDays:     retentionDays,
+		KmsKeyArn:         kmsKeyArn,
+		AuditLogBucketArn: auditLogBucketArn,
+	}, nil
+}
+
+// LoadLoggingConfig reads eks:logTypes, eks:logRetentionDays, eks:logKmsKeyArn and eks:auditLogBucketArn.
+func LoadLoggingConfig(ctx *pulumi.Context) (LoggingConfig, error) {
+	rawLogTypes, _ := ctx.GetConfig("eks:logTypes")
+	logTypes, err := parseClusterLogTypes(rawLogTypes)
+	if err != nil {
+		return LoggingConfig{}, err
+	}
+	rawRetention, _ := ctx.GetConfig("eks:logRetentionDays")
+	retention, err := parseLogRetentionDays(rawRetention)
+	if err != nil {
+		return LoggingConfig{}, err
+	}
+	keyArn, _ := ctx.GetConfig("eks:logKmsKeyArn")
+	bucketArn, _ := ctx.GetConfig("eks:auditLogBucketArn")
+	return NewLoggingConfig(logTypes, retention, keyArn, bucketArn)
+}
+
+func parseClusterLogTypes(raw string) ([]string, error) {
+	if raw == "" {
+		return defaultClusterLogTypes, nil
+	}
+	logTypes := []string{}
+	if err := json.Unmarshal([]byte(raw), &logTypes); err != nil {
+		return nil, fmt.Errorf("invalid eks:logTypes: %w", err)
+	}
+	for _, logType := range logTypes {
+		if !containsString(clusterLogTypes, logType) {
+			return nil, fmt.Errorf("unsupported log type %q in eks:logTypes", logType)
+		}
+	}
+	return logTypes, nil
+}
+
+func parseLogRetentionDays(raw string) (int, error) {
+	if raw == "" {
+		return DEFAULT_LOG_RETENTION_DAYS, nil
+	}
+	days, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, errors.New("eks:logRetentionDays must be a number")
+	}
+	if !validLogRetention(days) {
+		return 0, fmt.Errorf("eks:logRetentionDays %d is not a CloudWatch Logs retention period", days)
+	}
+	return days, nil
+}
+
+func validLogRetention(days int) bool {
+	for _, allowed := range logRetentionDays {
+		if days == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// logsKeyPolicyDocument lets the account administer the key and CloudWatch Logs use it for the cluster log group only.
+func logsKeyPolicyDocument(partition *Partition, region string, accountId string, logGroupName string) PolicyDocument {
+	logs := allowStatement([]string{
+		"kms:Encrypt*",
+		"kms:Decrypt*",
+		"kms:ReEncrypt*",
+		"kms:GenerateDataKey*",
+		"kms:Describe*",
+	}, "*")
+	logs.Principal = &PolicyPrincipal{Service: []string{partition.regionalServicePrincipal("logs", region)}}
+	logs.Condition = PolicyCondition{
+		"ArnEquals": {
+			"kms:EncryptionContext:aws:logs:arn": {"arn:" + partition.Name + ":logs:" + region + ":" + accountId + ":log-group:" + logGroupName},
+		},
+	}
+	return newPolicyDocument(keyAdministratorStatement(partition, accountId), logs)
+}
+
+// setupControlPlaneLogging pre-creates the /aws/eks/<cluster>/cluster log group so that retention and
+// encryption are managed by the stack, and optionally archives audit logs to S3 through Firehose.
+// The cluster must depend on the returned log group, otherwise EKS creates its own unmanaged one.
+func setupControlPlaneLogging(ctx *pulumi.Context, deployment *Deployment, clusterName string, config LoggingConfig, opts ...pulumi.ResourceOption) (*ControlPlaneLogging, error) {
+	logGroupName := "/aws/eks/" + clusterName + "/cluster"
+	var kmsKeyArn pulumi.StringPtrInput
+	if config.KmsKeyArn != "" {
+		kmsKeyArn = pulumi.String(config.KmsKeyArn)
+	} else {
+		keyPolicy, err := logsKeyPolicyDocument(deployment.Partition, deployment.Region, deployment.AccountId, logGroupName).JSON()
+		if err != nil {
+			return nil, err
+		}
+		key, err := kms.NewKey(ctx, deployment.name("ControlPlaneLogsKey", "WorkloadCluster"), &kms.KeyArgs{
+			Description:          pulumi.String("Encrypts the control plane logs of " + clusterName),
+			EnableKeyRotation:    pulumi.Bool(true),
+			DeletionWindowInDays: pulumi.Int(7),
+			Policy:               pulumi.String(keyPolicy),
+			Tags:                 deployment.tags(nil),
+		}, opts...)
+		if err != nil {
+			return nil, err
+		}
+		kmsKeyArn = key.Arn
+	}
+	logGroup, err := cloudwatch.NewLogGroup(ctx, deployment.name("ControlPlaneLogGroup", "WorkloadCluster"), &cloudwatch.LogGroupArgs{
+		Name:            pulumi.String(logGroupName),
+		RetentionInDays: pulumi.Int(config.RetentionDays),
+		KmsKeyId:        kmsKeyArn,
+		Tags: deployment.tags(pulumi.StringMap{
+			"ClusterName": pulumi.String(clusterName),
+		}),
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if config.AuditLogBucketArn != "" {
+		err = archiveAuditLogs(ctx, deployment, logGroup, config.AuditLogBucketArn, opts...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &ControlPlaneLogging{
+		LogTypes: config.LogTypes,
+		LogGroup: logGroup,
+	}, nil
+}
+
+func archiveAuditLogs(ctx *pulumi.Context, deployment *Deployment, logGroup *cloudwatch.LogGroup, bucketArn string, opts ...pulumi.ResourceOption) error {
+	firehoseTrust, err := newPolicyDocument(serviceTrustStatement(deployment.Partition.servicePrincipal("firehose"))).JSON()
+	if err != nil {
+		return err
+	}
+	firehoseRole, err := iam.NewRole(ctx, deployment.name("AuditLogFirehoseRole"), &iam.RoleArgs{
+		Name:             deployment.autoName(KIND_IAM_ROLE, "AuditLogFirehoseRole"),
+		AssumeRolePolicy: pulumi.String(firehoseTrust),
+		Tags:             deployment.tags(nil),
+	}, opts...)
+	if err != nil {
+		return err
+	}
+	firehosePolicy, err := newPolicyDocument(allowStatement([]string{
+		"s3:AbortMultipartUpload",
+		"s3:GetBucketLocation",
+		"s3:GetObject",
+		"s3:ListBucket",
+		"s3:ListBucketMultipartUploads",
+		"s3:PutObject",
+	}, bucketArn, bucketArn+"/*")).JSON()
+	if err != nil {
+		return err
+	}
+	firehoseRolePolicy, err := iam.NewRolePolicy(ctx, deployment.name("AuditLogFirehosePolicy"), &iam.RolePolicyArgs{
+		Role:   firehoseRole.ID(),
+		Policy: pulumi.String(firehosePolicy),
+	}, opts...)
+	if err != nil {
+		return err
+	}
+	// Subscription records arrive gzip compressed already, so Firehose stores them as they are
+	stream, err := kinesis.NewFirehoseDeliveryStream(ctx, deployment.name("AuditLogStream"), &kinesis.FirehoseDeliveryStreamArgs{
+		Name:        deployment.autoName(KIND_FIREHOSE_STREAM, "AuditLogStream"),
+		Destination: pulumi.String("extended_s3"),
+		ExtendedS3Configuration: &kinesis.FirehoseDeliveryStreamExtendedS3ConfigurationArgs{
+			BucketArn:         pulumi.String(bucketArn),
+			RoleArn:           firehoseRole.Arn,
+			Prefix:            pulumi.String("eks-audit/" + deployment.name("WorkloadCluster") + "/"),
+			ErrorOutputPrefix: pulumi.String("eks-audit-errors/" + deployment.name("WorkloadCluster") + "/"),
+			CompressionFormat: pulumi.String("UNCOMPRESSED"),
+		},
+		Tags: deployment.tags(nil),
+	}, childOptions(opts, pulumi.DependsOn([]pulumi.Resource{firehoseRolePolicy}))...)
+	if err != nil {
+		return err
+	}
+	logsTrustStatement := serviceTrustStatement(deployment.Partition.regionalServicePrincipal("logs", deployment.Region))
+	logsTrustStatement.Condition = PolicyCondition{
+		"StringLike": {
+			"aws:SourceArn": {"arn:" + deployment.Partition.Name + ":logs:" + deployment.Region + ":" + deployment.AccountId + ":*"},
+		},
+	}
+	logsTrust, err := newPolicyDocument(logsTrustStatement).JSON()
+	if err != nil {
+		return err
+	}
+	logsRole, err := iam.NewRole(ctx, deployment.name("AuditLogSubscriptionRole"), &iam.RoleArgs{
+		Name:             deployment.autoName(KIND_IAM_ROLE, "AuditLogSubscriptionRole"),
+		AssumeRolePolicy: pulumi.String(logsTrust),
+		Tags:             deployment.tags(nil),
+	}, opts...)
+	if err != nil {
+		return err
+	}
+	logsRolePolicy, err := iam.NewRolePolicy(ctx, deployment.name("AuditLogSubscriptionPolicy"), &iam.RolePolicyArgs{
+		Role: logsRole.ID(),
+		Policy: stream.Arn.ApplyT(func(arn string) (string, error) {
+			return newPolicyDocument(allowStatement([]string{"firehose:PutRecord", "firehose:PutRecordBatch"}, arn)).JSON()
+		}).(pulumi.StringOutput),
+	}, opts...)
+	if err != nil {
+		return err
+	}
+	_, err = cloudwatch.NewLogSubscriptionFilter(ctx, deployment.name("AuditLogSubscriptionFilter"), &cloudwatch.LogSubscriptionFilterArgs{
+		LogGroup:       logGroup.Name,
+		Name:           pulumi.String("eks-audit-archive"),
+		FilterPattern:  pulumi.String(`{ $.apiVersion = "audit.k8s.io/v1" }`),
+		DestinationArn: stream.Arn,
+		RoleArn:        logsRole.Arn,
+	}, childOptions(opts, pulumi.DependsOn([]pulumi.Resource{logsRolePolicy}))...)
+	return err
+}
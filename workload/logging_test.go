@@ -0,0 +1,59 @@
+package workload
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseClusterLogTypes(t *testing.T) {
+	logTypes, err := parseClusterLogTypes("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(logTypes, defaultClusterLogTypes) {
+		t.Errorf("got %v, want defaults %v", logTypes, defaultClusterLogTypes)
+	}
+	logTypes, err = parseClusterLogTypes(`["audit","scheduler"]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(logTypes, []string{"audit", "scheduler"}) {
+		t.Errorf("unexpected log types %v", logTypes)
+	}
+	if _, err := parseClusterLogTypes(`["kubelet"]`); err == nil {
+		t.Error("expected error for unsupported log type")
+	}
+}
+
+func TestParseLogRetentionDays(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want int
+		ok   bool
+	}{
+		{"", DEFAULT_LOG_RETENTION_DAYS, true},
+		{"365", 365, true},
+		{"0", 0, true},
+		{"100", 0, false},
+		{"forever", 0, false},
+	}
+	for _, test := range tests {
+		got, err := parseLogRetentionDays(test.raw)
+		if (err == nil) != test.ok || got != test.want {
+			t.Errorf("parseLogRetentionDays(%q) = %d, %v", test.raw, got, err)
+		}
+	}
+}
+
+func TestLogsKeyPolicyDocument(t *testing.T) {
+	partition := &Partition{Name: PARTITION_AWS_CN, DnsSuffix: "amazonaws.com.cn"}
+	doc := logsKeyPolicyDocument(partition, "cn-north-1", "123456789012", "/aws/eks/example/cluster")
+	logs := doc.Statement[1]
+	if logs.Principal.Service[0] != "logs.cn-north-1.amazonaws.com.cn" {
+		t.Errorf("unexpected logs principal %v", logs.Principal.Service)
+	}
+	want := "arn:aws-cn:logs:cn-north-1:123456789012:log-group:/aws/eks/example/cluster"
+	if got := logs.Condition["ArnEquals"]["kms:EncryptionContext:aws:logs:arn"][0]; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,37 @@
+package workload
+
+import (
+	"sync"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// mocks records every registered resource and echoes inputs back as outputs.
+type mocks struct {
+	lock      sync.Mutex
+	resources []pulumi.MockResourceArgs
+}
+
+func (m *mocks) NewResource(args pulumi.MockResourceArgs) (string, resource.PropertyMap, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.resources = append(m.resources, args)
+	return args.Name + "_id", args.Inputs, nil
+}
+
+func (m *mocks) Call(args pulumi.MockCallArgs) (resource.PropertyMap, error) {
+	return args.Args, nil
+}
+
+func (m *mocks) byType(typeToken string) []pulumi.MockResourceArgs {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	found := []pulumi.MockResourceArgs{}
+	for _, r := range m.resources {
+		if r.TypeToken == typeToken {
+			found = append(found, r)
+		}
+	}
+	return found
+}
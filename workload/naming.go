@@ -0,0 +1,151 @@
+package workload
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type ResourceKind string
+
+const (
+	KIND_EKS_CLUSTER      ResourceKind = "eks-cluster"
+	KIND_NODE_GROUP       ResourceKind = "node-group"
+	KIND_IAM_ROLE         ResourceKind = "iam-role"
+	KIND_IAM_POLICY       ResourceKind = "iam-policy"
+	KIND_LAUNCH_TEMPLATE  ResourceKind = "launch-template"
+	KIND_SECURITY_GROUP   ResourceKind = "security-group"
+	KIND_TARGET_GROUP     ResourceKind = "target-group"
+	KIND_KMS_ALIAS        ResourceKind = "kms-alias"
+	KIND_FIREHOSE_STREAM  ResourceKind = "firehose-stream"
+	KIND_INSTANCE_PROFILE ResourceKind = "instance-profile"
+
+	NAME_HASH_LENGTH = 8
+	// Pulumi autonaming appends a dash and 7 random characters to the logical name
+	AUTONAME_SUFFIX_LENGTH = 8
+)
+
+// NamingRule is the AWS limit on a physical name of one resource kind.
+type NamingRule struct {
+	MaxLength int
+	// Invalid matches the characters the resource kind does not accept, they are replaced by a dash
+	Invalid *regexp.Regexp
+	// Edges are characters a name may not start or end with
+	Edges string
+}
+
+var namingRules = map[ResourceKind]NamingRule{
+	KIND_EKS_CLUSTER:      {MaxLength: 100, Invalid: regexp.MustCompile(`[^A-Za-z0-9_-]`), Edges: "-_"},
+	KIND_NODE_GROUP:       {MaxLength: 63, Invalid: regexp.MustCompile(`[^A-Za-z0-9_-]`), Edges: "-_"},
+	KIND_IAM_ROLE:         {MaxLength: 64, Invalid: regexp.MustCompile(`[^A-Za-z0-9+=,.@_-]`)},
+	KIND_IAM_POLICY:       {MaxLength: 128, Invalid: regexp.MustCompile(`[^A-Za-z0-9+=,.@_-]`)},
+	KIND_INSTANCE_PROFILE: {MaxLength: 128, Invalid: regexp.MustCompile(`[^A-Za-z0-9+=,.@_-]`)},
+	KIND_LAUNCH_TEMPLATE:  {MaxLength: 128, Invalid: regexp.MustCompile(`[^A-Za-z0-9().\/_-]`)},
+	KIND_SECURITY_GROUP:   {MaxLength: 255, Invalid: regexp.MustCompile(`[^A-Za-z0-9 ._\-:/()#,@\[\]+=&;{}!$*]`)},
+	KIND_TARGET_GROUP:     {MaxLength: 32, Invalid: regexp.MustCompile(`[^A-Za-z0-9-]`), Edges: "-"},
+	KIND_KMS_ALIAS:        {MaxLength: 250, Invalid: regexp.MustCompile(`[^A-Za-z0-9/_-]`)},
+	KIND_FIREHOSE_STREAM:  {MaxLength: 64, Invalid: regexp.MustCompile(`[^A-Za-z0-9_.-]`)},
+}
+
+func nameHash(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return hex.EncodeToString(sum[:])[:NAME_HASH_LENGTH]
+}
+
+// fitName makes name valid for the rule. Names that are invalid or too long keep as much of the
+// readable prefix as possible followed by a hash of the original name, so the result is stable between
+// runs and "a.b" and "a-b" do not collide after sanitizing.
+func fitName(rule NamingRule, name string) string {
+	if len(name) <= rule.MaxLength && !rule.Invalid.MatchString(name) && strings.Trim(name, rule.Edges) == name {
+		return name
+	}
+	prefix := rule.Invalid.ReplaceAllString(name, "-")
+	prefix = strings.TrimLeft(prefix, rule.Edges)
+	if len(prefix) > rule.MaxLength-1-NAME_HASH_LENGTH {
+		prefix = prefix[:rule.MaxLength-1-NAME_HASH_LENGTH]
+	}
+	prefix = strings.TrimRight(prefix, "-_.")
+	if prefix == "" {
+		return nameHash(name)
+	}
+	return prefix + "-" + nameHash(name)
+}
+
+// Namer hands out physical names and remembers them to detect two resources ending up with the same name.
+type Namer struct {
+	lock sync.Mutex
+	// names maps kind and physical name to the full names it was produced from
+	names map[ResourceKind]map[string]map[string]bool
+}
+
+func newNamer() *Namer {
+	return &Namer{
+		names: map[ResourceKind]map[string]map[string]bool{},
+	}
+}
+
+func (n *Namer) record(kind ResourceKind, fitted string, full string) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if n.names[kind] == nil {
+		n.names[kind] = map[string]map[string]bool{}
+	}
+	if n.names[kind][fitted] == nil {
+		n.names[kind][fitted] = map[string]bool{}
+	}
+	n.names[kind][fitted][full] = true
+}
+
+// name returns the physical name for full, which is usually built with Deployment.name.
+func (n *Namer) name(kind ResourceKind, full string) string {
+	rule, ok := namingRules[kind]
+	if !ok {
+		panic("no naming rule for " + string(kind))
+	}
+	fitted := fitName(rule, full)
+	n.record(kind, fitted, full)
+	return fitted
+}
+
+// autoName returns nil when Pulumi autonaming of the logical name fits the limit, and a fitted
+// explicit name otherwise. Existing short names therefore never change.
+func (n *Namer) autoName(kind ResourceKind, logicalName string) *string {
+	rule, ok := namingRules[kind]
+	if !ok {
+		panic("no naming rule for " + string(kind))
+	}
+	if len(logicalName)+AUTONAME_SUFFIX_LENGTH <= rule.MaxLength && !rule.Invalid.MatchString(logicalName) {
+		return nil
+	}
+	name := n.name(kind, logicalName)
+	return &name
+}
+
+// Duplicates reports every physical name that was produced for different inputs of the same kind.
+func (n *Namer) Duplicates() error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	collisions := []string{}
+	for kind, names := range n.names {
+		for fitted, sources := range names {
+			if len(sources) < 2 {
+				continue
+			}
+			fullNames := []string{}
+			for full := range sources {
+				fullNames = append(fullNames, full)
+			}
+			sort.Strings(fullNames)
+			collisions = append(collisions, fmt.Sprintf("%s %q is used by %s", kind, fitted, strings.Join(fullNames, ", ")))
+		}
+	}
+	if len(collisions) == 0 {
+		return nil
+	}
+	sort.Strings(collisions)
+	return fmt.Errorf("duplicate resource names: %s", strings.Join(collisions, "; "))
+}
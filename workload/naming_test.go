@@ -0,0 +1,122 @@
+package workload
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFitNameKeepsValidNames(t *testing.T) {
+	for kind, rule := range namingRules {
+		name := "workload-Example-us-east-1-dev"
+		if len(name) > rule.MaxLength {
+			name = name[:rule.MaxLength]
+		}
+		if got := fitName(rule, name); got != name {
+			t.Errorf("%s: valid name %q changed to %q", kind, name, got)
+		}
+	}
+}
+
+func TestFitNameRespectsRulesForAllLengths(t *testing.T) {
+	for kind, rule := range namingRules {
+		for length := 1; length <= 300; length++ {
+			name := strings.Repeat("workload-WindowsLaunchTemplate.WorkloadCluster_us-east-1:dev ", 5)[:length]
+			got := fitName(rule, name)
+			if len(got) > rule.MaxLength {
+				t.Fatalf("%s: %d character name fitted to %d characters, limit %d", kind, length, len(got), rule.MaxLength)
+			}
+			if rule.Invalid.MatchString(got) {
+				t.Fatalf("%s: fitted name %q contains invalid characters", kind, got)
+			}
+			if rule.Edges != "" && strings.Trim(got, rule.Edges) != got {
+				t.Fatalf("%s: fitted name %q starts or ends with one of %q", kind, got, rule.Edges)
+			}
+			if got != fitName(rule, name) {
+				t.Fatalf("%s: fitting %q is not deterministic", kind, name)
+			}
+		}
+	}
+}
+
+func TestFitNameTruncatesWithHash(t *testing.T) {
+	rule := namingRules[KIND_NODE_GROUP]
+	name := "workload-WindowsNodeGroup-WorkloadCluster-ap-southeast-2-production-eu"
+	got := fitName(rule, name)
+	if len(got) != rule.MaxLength {
+		t.Errorf("expected %d characters, got %d (%s)", rule.MaxLength, len(got), got)
+	}
+	if !strings.HasPrefix(got, "workload-WindowsNodeGroup-WorkloadCluster-ap-southeast") {
+		t.Errorf("readable prefix lost: %s", got)
+	}
+	if !strings.HasSuffix(got, "-"+nameHash(name)) {
+		t.Errorf("missing hash suffix: %s", got)
+	}
+	other := fitName(rule, name+"2")
+	if other == got {
+		t.Errorf("names differing after the limit must not collide: %s", got)
+	}
+}
+
+func TestFitNameTargetGroup(t *testing.T) {
+	rule := namingRules[KIND_TARGET_GROUP]
+	got := fitName(rule, "workload-Ingress_TG-us-east-1-dev")
+	if len(got) > 32 || strings.ContainsAny(got, "_") || strings.HasPrefix(got, "-") || strings.HasSuffix(got, "-") {
+		t.Errorf("invalid target group name %q", got)
+	}
+}
+
+func TestFitNameSanitizedNamesDoNotCollide(t *testing.T) {
+	rule := namingRules[KIND_NODE_GROUP]
+	dotted := fitName(rule, "workload.pool")
+	dashed := fitName(rule, "workload-pool")
+	if dotted == dashed {
+		t.Errorf("sanitized name %q collides with %q", dotted, dashed)
+	}
+	if dashed != "workload-pool" {
+		t.Errorf("valid name changed to %q", dashed)
+	}
+}
+
+func TestFitNameOnlyInvalidCharacters(t *testing.T) {
+	rule := namingRules[KIND_NODE_GROUP]
+	got := fitName(rule, "***")
+	if got != nameHash("***") {
+		t.Errorf("expected bare hash, got %q", got)
+	}
+}
+
+func TestNamerDetectsDuplicates(t *testing.T) {
+	namer := newNamer()
+	first := namer.name(KIND_NODE_GROUP, "workload-pool")
+	if again := namer.name(KIND_NODE_GROUP, "workload-pool"); again != first {
+		t.Errorf("same input produced %q and %q", first, again)
+	}
+	namer.name(KIND_IAM_ROLE, "workload-pool")
+	if err := namer.Duplicates(); err != nil {
+		t.Errorf("unexpected duplicates: %v", err)
+	}
+	// Force a collision by recording a different source for an existing name
+	namer.record(KIND_NODE_GROUP, first, "workload_pool")
+	err := namer.Duplicates()
+	if err == nil {
+		t.Fatal("expected duplicate error")
+	}
+	if !strings.Contains(err.Error(), "workload-pool, workload_pool") {
+		t.Errorf("duplicate error does not name both sources: %v", err)
+	}
+}
+
+func TestNamerAutoName(t *testing.T) {
+	namer := newNamer()
+	if got := namer.autoName(KIND_IAM_ROLE, "workload-ClusterRole-us-east-1-dev"); got != nil {
+		t.Errorf("short name should be left to autonaming, got %q", *got)
+	}
+	long := "workload-WindowsWorkerRole-WorkloadCluster-us-east-1-production"
+	got := namer.autoName(KIND_IAM_ROLE, long)
+	if got == nil {
+		t.Fatal("expected explicit name for long role")
+	}
+	if len(*got) > 64 {
+		t.Errorf("role name %q exceeds 64 characters", *got)
+	}
+}
@@ -0,0 +1,109 @@
+package workload
+
+import (
+	"errors"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// NetworkFootprint is what setupEKSNetwork creates, preflight checks it against the account limits
+var NetworkFootprint = struct {
+	Vpcs             int
+	InternetGateways int
+	ElasticIps       int
+	NatGateways      int
+}{
+	Vpcs:             1,
+	InternetGateways: 1,
+	ElasticIps:       2,
+	NatGateways:      2,
+}
+
+// NetworkAvailabilityZones are the zones with one public and one private subnet each
+func NetworkAvailabilityZones(region string) []string {
+	return []string{region + "a", region + "b"}
+}
+
+type Network struct {
+	Vpc            *ec2.Vpc
+	PublicSubnets  []*ec2.Subnet
+	PrivateSubnets []*ec2.Subnet
+	// ResourceIds are the IDs of every network resource by name, exported as stack outputs
+	ResourceIds map[string]pulumi.IDOutput
+}
+
+func (n *Network) getSubnetIds() pulumi.StringArray {
+	subnets := pulumi.StringArray{}
+	for _, subnet := range n.PublicSubnets {
+		subnets = append(subnets, subnet.ID())
+	}
+	for _, subnet := range n.PrivateSubnets {
+		subnets = append(subnets, subnet.ID())
+	}
+	return subnets
+}
+func (n *Network) getPublicSubnetIds() pulumi.StringArray {
+	subnets := pulumi.StringArray{}
+	for _, subnet := range n.PublicSubnets {
+		subnets = append(subnets, subnet.ID())
+	}
+	return subnets
+}
+func (n *Network) getPrivateSubnetIds() pulumi.StringArray {
+	subnets := pulumi.StringArray{}
+	for _, subnet := range n.PrivateSubnets {
+		subnets = append(subnets, subnet.ID())
+	}
+	return subnets
+}
+
+type WorkloadNetworkArgs struct {
+	Deployment *Deployment
+}
+
+// WorkloadNetwork is the VPC with two public and two private subnets the cluster runs in,
+// plus the security group shared by all worker nodes.
+type WorkloadNetwork struct {
+	pulumi.ResourceState
+	Network
+
+	WorkerSecurityGroup *ec2.SecurityGroup
+	// InternalSecurityGroupRule lets workers reach each other on every port
+	InternalSecurityGroupRule pulumi.IDOutput
+}
+
+func NewWorkloadNetwork(ctx *pulumi.Context, name string, args *WorkloadNetworkArgs, opts ...pulumi.ResourceOption) (*WorkloadNetwork, error) {
+	if args == nil || args.Deployment == nil {
+		return nil, errors.New("WorkloadNetwork requires a Deployment")
+	}
+	deployment := args.Deployment
+	component := &WorkloadNetwork{}
+	err := ctx.RegisterComponentResource(TYPE_WORKLOAD_NETWORK, name, component, opts...)
+	if err != nil {
+		return nil, err
+	}
+	childOpts := componentChildOptions(component)
+	err = setupEKSNetwork(ctx, deployment, &component.Network, childOpts...)
+	if err != nil {
+		return nil, err
+	}
+	component.WorkerSecurityGroup, err = createWorkerSecurityGroup(ctx, deployment, component.Vpc, childOpts...)
+	if err != nil {
+		return nil, err
+	}
+	component.InternalSecurityGroupRule, err = allowFromSecurityGroup(ctx, deployment, component.WorkerSecurityGroup.ID(), component.WorkerSecurityGroup.ID(), "worker-sg", "worker-sg", childOpts...)
+	if err != nil {
+		return nil, err
+	}
+	err = ctx.RegisterResourceOutputs(component, pulumi.Map{
+		"vpcId":                 component.Vpc.ID(),
+		"publicSubnetIds":       component.getPublicSubnetIds(),
+		"privateSubnetIds":      component.getPrivateSubnetIds(),
+		"workerSecurityGroupId": component.WorkerSecurityGroup.ID(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return component, nil
+}
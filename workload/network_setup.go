@@ -1,17 +1,12 @@
-package main
+package workload
 
 import (
-	"errors"
 	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
 )
 
 // Ref : https://s3.us-west-2.amazonaws.com/amazon-eks/cloudformation/2020-10-29/amazon-eks-vpc-private-subnets.yaml
-func setupEKSNetwork(ctx *pulumi.Context, network *Network) error {
-	region, ok := ctx.GetConfig("aws:region")
-	if !ok {
-		return errors.New("missing required configuration 'aws:region'")
-	}
+func setupEKSNetwork(ctx *pulumi.Context, deployment *Deployment, network *Network, opts ...pulumi.ResourceOption) error {
 
 	/*
 		Parameters:
@@ -287,223 +282,228 @@ func setupEKSNetwork(ctx *pulumi.Context, network *Network) error {
 		      VpcId: !Ref VPC
 	*/
 	// Create a VPC
-	VPC, err := ec2.NewVpc(ctx, getStackNameRegional("VPC"), &ec2.VpcArgs{
+	VPC, err := ec2.NewVpc(ctx, deployment.name("VPC"), &ec2.VpcArgs{
 		CidrBlock:          pulumi.String(params.VpcBlock),
 		EnableDnsSupport:   pulumi.Bool(true),
 		EnableDnsHostnames: pulumi.Bool(true),
-		Tags: pulumi.StringMap{
-			"Name": pulumi.String(getStackNameRegional("VPC")),
-		},
-	})
+		Tags: deployment.tags(pulumi.StringMap{
+			"Name": pulumi.String(deployment.name("VPC")),
+		}),
+	}, opts...)
 	if err != nil {
 		return err
 	}
-	InternetGateway, err := ec2.NewInternetGateway(ctx, getStackNameRegional("IGW"), &ec2.InternetGatewayArgs{
-		Tags: pulumi.StringMap{
-			"Name": pulumi.String(getStackNameRegional("IGW")),
-		},
-	})
+	InternetGateway, err := ec2.NewInternetGateway(ctx, deployment.name("IGW"), &ec2.InternetGatewayArgs{
+		Tags: deployment.tags(pulumi.StringMap{
+			"Name": pulumi.String(deployment.name("IGW")),
+		}),
+	}, opts...)
 	if err != nil {
 		return err
 	}
-	VPCGatewayAttachment, err := ec2.NewInternetGatewayAttachment(ctx, getStackNameRegional("VPCGatewayAttachment"), &ec2.InternetGatewayAttachmentArgs{
+	VPCGatewayAttachment, err := ec2.NewInternetGatewayAttachment(ctx, deployment.name("VPCGatewayAttachment"), &ec2.InternetGatewayAttachmentArgs{
 		InternetGatewayId: InternetGateway.ID(),
 		VpcId:             VPC.ID(),
-	})
+	}, opts...)
 	if err != nil {
 		return err
 	}
-	PublicRouteTable, err := ec2.NewRouteTable(ctx, getStackNameRegional("PublicRouteTable"), &ec2.RouteTableArgs{
+	PublicRouteTable, err := ec2.NewRouteTable(ctx, deployment.name("PublicRouteTable"), &ec2.RouteTableArgs{
 		VpcId: VPC.ID(),
-		Tags: pulumi.StringMap{
+		Tags: deployment.tags(pulumi.StringMap{
 			"Name":    pulumi.String("Public Subnets"),
 			"Network": pulumi.String("Public"),
-		},
-	})
+		}),
+	}, opts...)
 	if err != nil {
 		return err
 	}
-	PrivateRouteTable01, err := ec2.NewRouteTable(ctx, getStackNameRegional("PrivateRouteTable01"), &ec2.RouteTableArgs{
+	PrivateRouteTable01, err := ec2.NewRouteTable(ctx, deployment.name("PrivateRouteTable01"), &ec2.RouteTableArgs{
 		VpcId: VPC.ID(),
-		Tags: pulumi.StringMap{
+		Tags: deployment.tags(pulumi.StringMap{
 			"Name":    pulumi.String("Private Subnet AZ1"),
 			"Network": pulumi.String("Private01"),
-		},
-	})
+		}),
+	}, opts...)
 	if err != nil {
 		return err
 	}
-	PrivateRouteTable02, err := ec2.NewRouteTable(ctx, getStackNameRegional("PrivateRouteTable02"), &ec2.RouteTableArgs{
+	PrivateRouteTable02, err := ec2.NewRouteTable(ctx, deployment.name("PrivateRouteTable02"), &ec2.RouteTableArgs{
 		VpcId: VPC.ID(),
-		Tags: pulumi.StringMap{
+		Tags: deployment.tags(pulumi.StringMap{
 			"Name":    pulumi.String("Private Subnet AZ2"),
 			"Network": pulumi.String("Private02"),
-		},
-	})
+		}),
+	}, opts...)
 	if err != nil {
 		return err
 	}
-	PublicSubnet01, err := ec2.NewSubnet(ctx, getStackNameRegional("PublicSubnet01"), &ec2.SubnetArgs{
+	zones := NetworkAvailabilityZones(deployment.Region)
+	PublicSubnet01, err := ec2.NewSubnet(ctx, deployment.name("PublicSubnet01"), &ec2.SubnetArgs{
 		MapPublicIpOnLaunch: pulumi.Bool(true),
-		AvailabilityZone:    pulumi.String(region + "a"),
+		AvailabilityZone:    pulumi.String(zones[0]),
 		CidrBlock:           pulumi.String(params.PublicSubnet01Block),
 		VpcId:               VPC.ID(),
-		Tags: pulumi.StringMap{
-			"Name":                   pulumi.String(getStackNameRegional("PublicSubnet01")),
+		Tags: deployment.tags(pulumi.StringMap{
+			"Name":                   pulumi.String(deployment.name("PublicSubnet01")),
 			"kubernetes.io/role/elb": pulumi.String("1"),
-		},
-	})
+		}),
+	}, opts...)
 	if err != nil {
 		return err
 	}
-	PublicSubnet02, err := ec2.NewSubnet(ctx, getStackNameRegional("PublicSubnet02"), &ec2.SubnetArgs{
+	PublicSubnet02, err := ec2.NewSubnet(ctx, deployment.name("PublicSubnet02"), &ec2.SubnetArgs{
 		MapPublicIpOnLaunch: pulumi.Bool(true),
-		AvailabilityZone:    pulumi.String(region + "b"),
+		AvailabilityZone:    pulumi.String(zones[1]),
 		CidrBlock:           pulumi.String(params.PublicSubnet02Block),
 		VpcId:               VPC.ID(),
-		Tags: pulumi.StringMap{
-			"Name":                   pulumi.String(getStackNameRegional("PublicSubnet02")),
+		Tags: deployment.tags(pulumi.StringMap{
+			"Name":                   pulumi.String(deployment.name("PublicSubnet02")),
 			"kubernetes.io/role/elb": pulumi.String("1"),
-		},
-	})
+		}),
+	}, opts...)
 	if err != nil {
 		return err
 	}
-	PrivateSubnet01, err := ec2.NewSubnet(ctx, getStackNameRegional("PrivateSubnet01"), &ec2.SubnetArgs{
-		AvailabilityZone: pulumi.String(region + "a"),
+	PrivateSubnet01, err := ec2.NewSubnet(ctx, deployment.name("PrivateSubnet01"), &ec2.SubnetArgs{
+		AvailabilityZone: pulumi.String(zones[0]),
 		CidrBlock:        pulumi.String(params.PrivateSubnet01Block),
 		VpcId:            VPC.ID(),
-		Tags: pulumi.StringMap{
-			"Name":                            pulumi.String(getStackNameRegional("PrivateSubnet01")),
+		Tags: deployment.tags(pulumi.StringMap{
+			"Name":                            pulumi.String(deployment.name("PrivateSubnet01")),
 			"kubernetes.io/role/internal-elb": pulumi.String("1"),
-		},
-	})
+		}),
+	}, opts...)
 	if err != nil {
 		return err
 	}
-	PrivateSubnet02, err := ec2.NewSubnet(ctx, getStackNameRegional("PrivateSubnet02"), &ec2.SubnetArgs{
-		AvailabilityZone: pulumi.String(region + "b"),
+	PrivateSubnet02, err := ec2.NewSubnet(ctx, deployment.name("PrivateSubnet02"), &ec2.SubnetArgs{
+		AvailabilityZone: pulumi.String(zones[1]),
 		CidrBlock:        pulumi.String(params.PrivateSubnet02Block),
 		VpcId:            VPC.ID(),
-		Tags: pulumi.StringMap{
-			"Name":                            pulumi.String(getStackNameRegional("PrivateSubnet02")),
+		Tags: deployment.tags(pulumi.StringMap{
+			"Name":                            pulumi.String(deployment.name("PrivateSubnet02")),
 			"kubernetes.io/role/internal-elb": pulumi.String("1"),
-		},
-	})
+		}),
+	}, opts...)
 	if err != nil {
 		return err
 	}
-	PublicRoute, err := ec2.NewRoute(ctx, getStackNameRegional("PublicRoute"), &ec2.RouteArgs{
+	PublicRoute, err := ec2.NewRoute(ctx, deployment.name("PublicRoute"), &ec2.RouteArgs{
 		RouteTableId:         PublicRouteTable.ID(),
 		DestinationCidrBlock: pulumi.String("0.0.0.0/0"),
 		GatewayId:            InternetGateway.ID(),
-	}, pulumi.DependsOn([]pulumi.Resource{VPCGatewayAttachment}))
+	}, childOptions(opts, pulumi.DependsOn([]pulumi.Resource{VPCGatewayAttachment}))...)
 	if err != nil {
 		return err
 	}
-	NatGatewayEIP1, err := ec2.NewEip(ctx, getStackNameRegional("NatGatewayEIP1"), &ec2.EipArgs{
+	NatGatewayEIP1, err := ec2.NewEip(ctx, deployment.name("NatGatewayEIP1"), &ec2.EipArgs{
 		Domain: pulumi.String("vpc"),
-	}, pulumi.DependsOn([]pulumi.Resource{VPCGatewayAttachment}))
+		Tags:   deployment.tags(nil),
+	}, childOptions(opts, pulumi.DependsOn([]pulumi.Resource{VPCGatewayAttachment}))...)
 	if err != nil {
 		return err
 	}
-	NatGatewayEIP2, err := ec2.NewEip(ctx, getStackNameRegional("NatGatewayEIP2"), &ec2.EipArgs{
+	NatGatewayEIP2, err := ec2.NewEip(ctx, deployment.name("NatGatewayEIP2"), &ec2.EipArgs{
 		Domain: pulumi.String("vpc"),
-	}, pulumi.DependsOn([]pulumi.Resource{VPCGatewayAttachment}))
+		Tags:   deployment.tags(nil),
+	}, childOptions(opts, pulumi.DependsOn([]pulumi.Resource{VPCGatewayAttachment}))...)
 	if err != nil {
 		return err
 	}
-	NatGateway01, err := ec2.NewNatGateway(ctx, getStackNameRegional("NatGateway01"), &ec2.NatGatewayArgs{
+	NatGateway01, err := ec2.NewNatGateway(ctx, deployment.name("NatGateway01"), &ec2.NatGatewayArgs{
 		AllocationId: NatGatewayEIP1.ID(),
 		SubnetId:     PublicSubnet01.ID(),
-		Tags: pulumi.StringMap{
-			"Name": pulumi.String(getStackNameRegional("NatGateway01")),
-		},
-	}, pulumi.DependsOn([]pulumi.Resource{VPCGatewayAttachment, PublicSubnet01, NatGatewayEIP1}))
+		Tags: deployment.tags(pulumi.StringMap{
+			"Name": pulumi.String(deployment.name("NatGateway01")),
+		}),
+	}, childOptions(opts, pulumi.DependsOn([]pulumi.Resource{VPCGatewayAttachment, PublicSubnet01, NatGatewayEIP1}))...)
 	if err != nil {
 		return err
 	}
-	NatGateway02, err := ec2.NewNatGateway(ctx, getStackNameRegional("NatGateway02"), &ec2.NatGatewayArgs{
+	NatGateway02, err := ec2.NewNatGateway(ctx, deployment.name("NatGateway02"), &ec2.NatGatewayArgs{
 		AllocationId: NatGatewayEIP2.ID(),
 		SubnetId:     PublicSubnet02.ID(),
-		Tags: pulumi.StringMap{
-			"Name": pulumi.String(getStackNameRegional("NatGateway02")),
-		},
-	}, pulumi.DependsOn([]pulumi.Resource{VPCGatewayAttachment, PublicSubnet02, NatGatewayEIP2}))
+		Tags: deployment.tags(pulumi.StringMap{
+			"Name": pulumi.String(deployment.name("NatGateway02")),
+		}),
+	}, childOptions(opts, pulumi.DependsOn([]pulumi.Resource{VPCGatewayAttachment, PublicSubnet02, NatGatewayEIP2}))...)
 	if err != nil {
 		return err
 	}
-	PrivateRoute01, err := ec2.NewRoute(ctx, getStackNameRegional("PrivateRoute01"), &ec2.RouteArgs{
+	PrivateRoute01, err := ec2.NewRoute(ctx, deployment.name("PrivateRoute01"), &ec2.RouteArgs{
 		RouteTableId:         PrivateRouteTable01.ID(),
 		DestinationCidrBlock: pulumi.String("0.0.0.0/0"),
 		NatGatewayId:         NatGateway01.ID(),
-	}, pulumi.DependsOn([]pulumi.Resource{VPCGatewayAttachment, NatGateway01}))
+	}, childOptions(opts, pulumi.DependsOn([]pulumi.Resource{VPCGatewayAttachment, NatGateway01}))...)
 	if err != nil {
 		return err
 	}
-	PrivateRoute02, err := ec2.NewRoute(ctx, getStackNameRegional("PrivateRoute02"), &ec2.RouteArgs{
+	PrivateRoute02, err := ec2.NewRoute(ctx, deployment.name("PrivateRoute02"), &ec2.RouteArgs{
 		RouteTableId:         PrivateRouteTable02.ID(),
 		DestinationCidrBlock: pulumi.String("0.0.0.0/0"),
 		NatGatewayId:         NatGateway02.ID(),
-	}, pulumi.DependsOn([]pulumi.Resource{VPCGatewayAttachment, NatGateway02}))
+	}, childOptions(opts, pulumi.DependsOn([]pulumi.Resource{VPCGatewayAttachment, NatGateway02}))...)
 	if err != nil {
 		return err
 	}
-	PublicRouteTableAssociation01, err := ec2.NewRouteTableAssociation(ctx, getStackNameRegional("PublicRouteTableAssociation01"), &ec2.RouteTableAssociationArgs{
+	PublicRouteTableAssociation01, err := ec2.NewRouteTableAssociation(ctx, deployment.name("PublicRouteTableAssociation01"), &ec2.RouteTableAssociationArgs{
 		SubnetId:     PublicSubnet01.ID(),
 		RouteTableId: PublicRouteTable.ID(),
-	})
+	}, opts...)
 	if err != nil {
 		return err
 	}
-	PublicRouteTableAssociation02, err := ec2.NewRouteTableAssociation(ctx, getStackNameRegional("PublicRouteTableAssociation02"), &ec2.RouteTableAssociationArgs{
+	PublicRouteTableAssociation02, err := ec2.NewRouteTableAssociation(ctx, deployment.name("PublicRouteTableAssociation02"), &ec2.RouteTableAssociationArgs{
 		SubnetId:     PublicSubnet02.ID(),
 		RouteTableId: PublicRouteTable.ID(),
-	})
+	}, opts...)
 	if err != nil {
 		return err
 	}
-	PrivateRouteTableAssociation01, err := ec2.NewRouteTableAssociation(ctx, getStackNameRegional("PrivateRouteTableAssociation01"), &ec2.RouteTableAssociationArgs{
+	PrivateRouteTableAssociation01, err := ec2.NewRouteTableAssociation(ctx, deployment.name("PrivateRouteTableAssociation01"), &ec2.RouteTableAssociationArgs{
 		SubnetId:     PrivateSubnet01.ID(),
 		RouteTableId: PrivateRouteTable01.ID(),
-	})
+	}, opts...)
 	if err != nil {
 		return err
 	}
-	PrivateRouteTableAssociation02, err := ec2.NewRouteTableAssociation(ctx, getStackNameRegional("PrivateRouteTableAssociation02"), &ec2.RouteTableAssociationArgs{
+	PrivateRouteTableAssociation02, err := ec2.NewRouteTableAssociation(ctx, deployment.name("PrivateRouteTableAssociation02"), &ec2.RouteTableAssociationArgs{
 		SubnetId:     PrivateSubnet02.ID(),
 		RouteTableId: PrivateRouteTable02.ID(),
-	})
+	}, opts...)
 	if err != nil {
 		return err
 	}
-	ctx.Export("VPC", VPC.ID())
-	ctx.Export("InternetGateway", InternetGateway.ID())
-	ctx.Export("VPCGatewayAttachment", VPCGatewayAttachment.ID())
-	ctx.Export("PublicRouteTable", PublicRouteTable.ID())
-	ctx.Export("PrivateRouteTable01", PrivateRouteTable01.ID())
-	ctx.Export("PrivateRouteTable02", PrivateRouteTable02.ID())
-	ctx.Export("PublicSubnet01", PublicSubnet01.ID())
-	ctx.Export("PublicSubnet02", PublicSubnet02.ID())
-	ctx.Export("PrivateSubnet01", PrivateSubnet01.ID())
-	ctx.Export("PrivateSubnet02", PrivateSubnet02.ID())
-	ctx.Export("NatGateway01", NatGateway01.ID())
-	ctx.Export("NatGateway02", NatGateway02.ID())
-	ctx.Export("NatGatewayEIP1", NatGatewayEIP1.ID())
-	ctx.Export("NatGatewayEIP2", NatGatewayEIP2.ID())
-	ctx.Export("PublicRoute", PublicRoute.ID())
-	ctx.Export("PrivateRoute01", PrivateRoute01.ID())
-	ctx.Export("PrivateRoute02", PrivateRoute02.ID())
-	ctx.Export("PublicRouteTableAssociation01", PublicRouteTableAssociation01.ID())
-	ctx.Export("PublicRouteTableAssociation02", PublicRouteTableAssociation02.ID())
-	ctx.Export("PrivateRouteTableAssociation01", PrivateRouteTableAssociation01.ID())
-	ctx.Export("PrivateRouteTableAssociation02", PrivateRouteTableAssociation02.ID())
+	network.ResourceIds = map[string]pulumi.IDOutput{
+		"VPC":                            VPC.ID(),
+		"InternetGateway":                InternetGateway.ID(),
+		"VPCGatewayAttachment":           VPCGatewayAttachment.ID(),
+		"PublicRouteTable":               PublicRouteTable.ID(),
+		"PrivateRouteTable01":            PrivateRouteTable01.ID(),
+		"PrivateRouteTable02":            PrivateRouteTable02.ID(),
+		"PublicSubnet01":                 PublicSubnet01.ID(),
+		"PublicSubnet02":                 PublicSubnet02.ID(),
+		"PrivateSubnet01":                PrivateSubnet01.ID(),
+		"PrivateSubnet02":                PrivateSubnet02.ID(),
+		"NatGateway01":                   NatGateway01.ID(),
+		"NatGateway02":                   NatGateway02.ID(),
+		"NatGatewayEIP1":                 NatGatewayEIP1.ID(),
+		"NatGatewayEIP2":                 NatGatewayEIP2.ID(),
+		"PublicRoute":                    PublicRoute.ID(),
+		"PrivateRoute01":                 PrivateRoute01.ID(),
+		"PrivateRoute02":                 PrivateRoute02.ID(),
+		"PublicRouteTableAssociation01":  PublicRouteTableAssociation01.ID(),
+		"PublicRouteTableAssociation02":  PublicRouteTableAssociation02.ID(),
+		"PrivateRouteTableAssociation01": PrivateRouteTableAssociation01.ID(),
+		"PrivateRouteTableAssociation02": PrivateRouteTableAssociation02.ID(),
+	}
 	network.PublicSubnets = []*ec2.Subnet{PublicSubnet01, PublicSubnet02}
 	network.PrivateSubnets = []*ec2.Subnet{PrivateSubnet01, PrivateSubnet02}
 	network.Vpc = VPC
 	return nil
 }
-func createWorkerSecurityGroup(ctx *pulumi.Context, vpc *ec2.Vpc) (*ec2.SecurityGroup, error) {
+func createWorkerSecurityGroup(ctx *pulumi.Context, deployment *Deployment, vpc *ec2.Vpc, opts ...pulumi.ResourceOption) (*ec2.SecurityGroup, error) {
 	ingressSecurityGroupArgs := ec2.SecurityGroupIngressArray{
 		// Allow all inbound traffic
 		&ec2.SecurityGroupIngressArgs{
@@ -541,14 +541,7 @@ func createWorkerSecurityGroup(ctx *pulumi.Context, vpc *ec2.Vpc) (*ec2.Security
 		//	Ipv6CidrBlocks: pulumi.StringArray{pulumi.String("::/0")},
 		//	Protocol:       pulumi.String("tcp"),
 		//},
-		&ec2.SecurityGroupIngressArgs{
-			CidrBlocks:     pulumi.StringArray{pulumi.String("0.0.0.0/0")},
-			Description:    pulumi.String("Allow RDP Port"),
-			FromPort:       pulumi.Int(3389),
-			ToPort:         pulumi.Int(3389),
-			Ipv6CidrBlocks: pulumi.StringArray{pulumi.String("::/0")},
-			Protocol:       pulumi.String("tcp"),
-		},
+		// RDP is not exposed, the workers are SSM managed and reachable with port forwarding sessions
 	}
 	egressSecurityGroupArgs := ec2.SecurityGroupEgressArray{
 		&ec2.SecurityGroupEgressArgs{
@@ -560,33 +553,40 @@ func createWorkerSecurityGroup(ctx *pulumi.Context, vpc *ec2.Vpc) (*ec2.Security
 			Ipv6CidrBlocks: pulumi.StringArray{pulumi.String("::/0")},
 		},
 	}
-	SecurityGroup, err := ec2.NewSecurityGroup(ctx, getStackNameRegional("WorkerSecurityGroup"), &ec2.SecurityGroupArgs{
-		Name:        pulumi.String(getStackNameRegional("WorkerSecurityGroup")),
+	SecurityGroup, err := ec2.NewSecurityGroup(ctx, deployment.name("WorkerSecurityGroup"), &ec2.SecurityGroupArgs{
+		Name:        pulumi.String(deployment.resourceName(KIND_SECURITY_GROUP, "WorkerSecurityGroup")),
 		Description: pulumi.String("Cluster communication with worker nodes"),
 		Ingress:     ingressSecurityGroupArgs,
 		Egress:      egressSecurityGroupArgs,
 		VpcId:       vpc.ID(),
-		Tags: pulumi.StringMap{
-			"Description": pulumi.String(getStackNameRegional("WorkerSecurityGroup")),
-		},
-	}, pulumi.DependsOn([]pulumi.Resource{vpc}))
+		Tags: deployment.tags(pulumi.StringMap{
+			"Description": pulumi.String(deployment.name("WorkerSecurityGroup")),
+		}),
+	}, childOptions(opts, pulumi.DependsOn([]pulumi.Resource{vpc}))...)
 	return SecurityGroup, err
 }
 
-func allowFromSecurityGroup(ctx *pulumi.Context, securityGroup *ec2.SecurityGroup, fromSecurityGroup *ec2.SecurityGroup, sgName, sourceName string) (pulumi.Output, error) {
+func allowFromSecurityGroup(ctx *pulumi.Context, deployment *Deployment, securityGroupId pulumi.IDOutput, fromSecurityGroupId pulumi.IDOutput, sgName, sourceName string, opts ...pulumi.ResourceOption) (pulumi.IDOutput, error) {
 
-	rule, err := ec2.NewSecurityGroupRule(ctx, getStackNameRegional("AllowFromSecurityGroup", sgName, sourceName), &ec2.SecurityGroupRuleArgs{
+	rule, err := ec2.NewSecurityGroupRule(ctx, deployment.name("AllowFromSecurityGroup", sgName, sourceName), &ec2.SecurityGroupRuleArgs{
 		Description:           pulumi.String("Allow communication from the worker nodes"),
 		FromPort:              pulumi.Int(0),
 		Protocol:              pulumi.String("-1"),
-		SecurityGroupId:       securityGroup.ID(),
-		SourceSecurityGroupId: fromSecurityGroup.ID(),
+		SecurityGroupId:       securityGroupId,
+		SourceSecurityGroupId: fromSecurityGroupId,
 		ToPort:                pulumi.Int(0),
 		Type:                  pulumi.String("ingress"),
-	}, pulumi.DependsOn([]pulumi.Resource{securityGroup, fromSecurityGroup}))
+	}, opts...)
 	if err != nil {
-		return nil, err
+		return pulumi.IDOutput{}, err
 	}
-	ctx.Export(getStackNameRegional("AllowFromSecurityGroup", sgName, sourceName), rule.ID())
 	return rule.ID(), nil
 }
+
+// securityGroupId resolves the ID of a security group that is only known as an output, e.g. of the EKS cluster.
+// The rules using it can then be created without waiting for the group inside an ApplyT.
+func securityGroupId(securityGroup ec2.SecurityGroupOutput) pulumi.IDOutput {
+	return securityGroup.ApplyT(func(sg *ec2.SecurityGroup) pulumi.IDOutput {
+		return sg.ID()
+	}).(pulumi.IDOutput)
+}
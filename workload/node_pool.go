@@ -0,0 +1,284 @@
+package workload
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
+	awsEKS "github.com/pulumi/pulumi-aws/sdk/v6/go/aws/eks"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+type GpuNodePoolArgs struct {
+	Deployment *Deployment
+	Network    *WorkloadNetwork
+	Cluster    *WorkloadCluster
+	// Pool is POOL_LINUX or POOL_WINDOWS
+	Pool            string
+	InstanceType    string
+	DesiredCapacity int
+	MinSize         int
+	MaxSize         int
+	MaxUnavailable  int
+	MetadataOptions MetadataOptions
+	// ImageId and WindowsPassword configure the Windows pool, which runs the XBeam marketplace AMI
+	ImageId         string
+	WindowsPassword pulumi.StringInput
+	// ReleaseVersion pins the Linux pool AMI release, nil keeps the current one
+	ReleaseVersion pulumi.StringPtrInput
+	// After holds the node group back until the given resources are updated, so upgrades roll one pool at a time
+	After []pulumi.Resource
+}
+
+// GpuNodePool is a managed node group of GPU workers tainted with workload=gpu.
+type GpuNodePool struct {
+	pulumi.ResourceState
+
+	LaunchTemplate *ec2.LaunchTemplate
+	NodeGroup      *awsEKS.NodeGroup
+}
+
+// Labels of the GPU nodes, the Windows nodes get them from their user data
+var gpuLabels = map[string]string{
+	"workload": "gpu",
+}
+
+// Taints keep everything but GPU workloads off the GPU nodes, the EKS effect mapped to the Kubernetes one
+var gpuTaintEffects = []struct{ eks, kubernetes string }{
+	{"NO_SCHEDULE", "NoSchedule"},
+	{"NO_EXECUTE", "NoExecute"},
+}
+
+var gpuTaints = func() awsEKS.NodeGroupTaintArray {
+	taints := awsEKS.NodeGroupTaintArray{}
+	for _, effect := range gpuTaintEffects {
+		taints = append(taints, &awsEKS.NodeGroupTaintArgs{
+			Effect: pulumi.String(effect.eks),
+			Key:    pulumi.String("workload"),
+			Value:  pulumi.String(gpuLabels["workload"]),
+		})
+	}
+	return taints
+}()
+
+// gpuTolerations let pods run on the tainted GPU nodes.
+func gpuTolerations() pulumi.Array {
+	tolerations := pulumi.Array{}
+	for _, effect := range gpuTaintEffects {
+		tolerations = append(tolerations, pulumi.Map{
+			"key":      pulumi.String("workload"),
+			"operator": pulumi.String("Equal"),
+			"value":    pulumi.String(gpuLabels["workload"]),
+			"effect":   pulumi.String(effect.kubernetes),
+		})
+	}
+	return tolerations
+}
+
+// gpuTolerationArray are the gpuTolerations of a typed pod spec.
+func gpuTolerationArray() corev1.TolerationArray {
+	tolerations := corev1.TolerationArray{}
+	for _, effect := range gpuTaintEffects {
+		tolerations = append(tolerations, corev1.TolerationArgs{
+			Key:      pulumi.String("workload"),
+			Operator: pulumi.String("Equal"),
+			Value:    pulumi.String(gpuLabels["workload"]),
+			Effect:   pulumi.String(effect.kubernetes),
+		})
+	}
+	return tolerations
+}
+
+// gpuNodeAffinity requires the GPU nodes of the operating system, linux or windows.
+func gpuNodeAffinity(os string) pulumi.Map {
+	return pulumi.Map{
+		"nodeAffinity": pulumi.Map{
+			"requiredDuringSchedulingIgnoredDuringExecution": pulumi.Map{
+				"nodeSelectorTerms": pulumi.Array{
+					pulumi.Map{
+						"matchExpressions": pulumi.Array{
+							pulumi.Map{
+								"key":      pulumi.String("workload"),
+								"operator": pulumi.String("In"),
+								"values":   pulumi.StringArray{pulumi.String(gpuLabels["workload"])},
+							},
+							pulumi.Map{
+								"key":      pulumi.String("kubernetes.io/os"),
+								"operator": pulumi.String("In"),
+								"values":   pulumi.StringArray{pulumi.String(os)},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func NewGpuNodePool(ctx *pulumi.Context, name string, args *GpuNodePoolArgs, opts ...pulumi.ResourceOption) (*GpuNodePool, error) {
+	if args == nil || args.Deployment == nil || args.Network == nil || args.Cluster == nil {
+		return nil, errors.New("GpuNodePool requires a Deployment, a Network and a Cluster")
+	}
+	if args.Pool != POOL_LINUX && args.Pool != POOL_WINDOWS {
+		return nil, fmt.Errorf("GpuNodePool pool must be %s or %s", POOL_LINUX, POOL_WINDOWS)
+	}
+	component := &GpuNodePool{}
+	err := ctx.RegisterComponentResource(TYPE_GPU_NODE_POOL, name, component, opts...)
+	if err != nil {
+		return nil, err
+	}
+	childOpts := componentChildOptions(component)
+	if args.Pool == POOL_LINUX {
+		err = component.createLinux(ctx, args, childOpts)
+	} else {
+		err = component.createWindows(ctx, args, childOpts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = ctx.RegisterResourceOutputs(component, pulumi.Map{
+		"launchTemplateId": component.LaunchTemplate.ID(),
+		"nodeGroup":        component.NodeGroup.ID(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return component, nil
+}
+
+func (p *GpuNodePool) createLinux(ctx *pulumi.Context, args *GpuNodePoolArgs, opts []pulumi.ResourceOption) error {
+	deployment := args.Deployment
+	cluster := args.Cluster
+	workerSecurityGroup := args.Network.WorkerSecurityGroup
+	var err error
+	p.LaunchTemplate, err = createLaunchTemplate(ctx, deployment.name("LinuxLaunchTemplate", "WorkloadCluster"), &ec2.LaunchTemplateArgs{
+		BlockDeviceMappings: ec2.LaunchTemplateBlockDeviceMappingArray{
+			&ec2.LaunchTemplateBlockDeviceMappingArgs{
+				DeviceName: pulumi.String("/dev/sda1"),
+				Ebs: &ec2.LaunchTemplateBlockDeviceMappingEbsArgs{
+					VolumeSize: pulumi.Int(100),
+					Encrypted:  pulumi.String("true"),
+					KmsKeyId:   cluster.KeyArn,
+				},
+			},
+		},
+		VpcSecurityGroupIds: pulumi.StringArray{
+			workerSecurityGroup.ID(),
+		},
+		Name: pulumi.String(deployment.resourceName(KIND_LAUNCH_TEMPLATE, "LinuxLaunchTemplate", "WorkloadCluster")),
+		TagSpecifications: ec2.LaunchTemplateTagSpecificationArray{
+			&ec2.LaunchTemplateTagSpecificationArgs{
+				ResourceType: pulumi.String("instance"),
+				Tags: deployment.tags(pulumi.StringMap{
+					"Name": pulumi.String(deployment.name("LinuxLaunchTemplate", "WorkloadCluster")),
+				}),
+			},
+		},
+		Tags: deployment.tags(nil),
+	}, args.MetadataOptions, childOptions(opts, pulumi.DependsOn([]pulumi.Resource{workerSecurityGroup, cluster.Cluster}))...)
+	if err != nil {
+		return err
+	}
+	dependencies := append([]pulumi.Resource{cluster.Cluster, cluster.LinuxWorkerRole, p.LaunchTemplate}, args.After...)
+	p.NodeGroup, err = awsEKS.NewNodeGroup(ctx, deployment.name("LinuxNodeGroup", "WorkloadCluster"), &awsEKS.NodeGroupArgs{
+		NodeGroupName:  pulumi.String(deployment.resourceName(KIND_NODE_GROUP, "LinuxNodeGroup", "WorkloadCluster")),
+		ClusterName:    cluster.Cluster.EksCluster.Name(),
+		NodeRoleArn:    cluster.LinuxWorkerRole.Arn,
+		Version:        pulumi.String(cluster.Version),
+		ReleaseVersion: args.ReleaseVersion,
+		UpdateConfig: &awsEKS.NodeGroupUpdateConfigArgs{
+			MaxUnavailable: pulumi.Int(args.MaxUnavailable),
+		},
+		ScalingConfig: &awsEKS.NodeGroupScalingConfigArgs{
+			DesiredSize: pulumi.Int(args.DesiredCapacity),
+			MaxSize:     pulumi.Int(args.MaxSize),
+			MinSize:     pulumi.Int(args.MinSize),
+		},
+		AmiType:   pulumi.String("AL2_x86_64_GPU"),
+		SubnetIds: args.Network.getPublicSubnetIds(),
+		InstanceTypes: pulumi.StringArray{
+			pulumi.String(args.InstanceType),
+		},
+		LaunchTemplate: &awsEKS.NodeGroupLaunchTemplateArgs{
+			Id:      p.LaunchTemplate.ID(),
+			Version: pulumi.String("$Latest"),
+		},
+		Taints: gpuTaints,
+		Labels: pulumi.ToStringMap(gpuLabels),
+		Tags: deployment.tags(pulumi.StringMap{
+			"Name": pulumi.String(deployment.name("LinuxNodeGroup", "WorkloadCluster")),
+		}),
+	}, childOptions(opts, pulumi.DependsOn(dependencies))...)
+	return err
+}
+
+func (p *GpuNodePool) createWindows(ctx *pulumi.Context, args *GpuNodePoolArgs, opts []pulumi.ResourceOption) error {
+	if args.ImageId == "" || args.WindowsPassword == nil {
+		return errors.New("the windows GpuNodePool requires an ImageId and a WindowsPassword")
+	}
+	deployment := args.Deployment
+	cluster := args.Cluster
+	workerSecurityGroup := args.Network.WorkerSecurityGroup
+	var err error
+	p.LaunchTemplate, err = createLaunchTemplate(ctx, deployment.name("WindowsLaunchTemplate", "WorkloadCluster"), &ec2.LaunchTemplateArgs{
+		Name:         pulumi.String(deployment.resourceName(KIND_LAUNCH_TEMPLATE, "WindowsLaunchTemplate", "WorkloadCluster")),
+		ImageId:      pulumi.String(args.ImageId),
+		InstanceType: pulumi.String(args.InstanceType),
+		VpcSecurityGroupIds: pulumi.StringArray{
+			workerSecurityGroup.ID(),
+		},
+		BlockDeviceMappings: ec2.LaunchTemplateBlockDeviceMappingArray{
+			&ec2.LaunchTemplateBlockDeviceMappingArgs{
+				DeviceName: pulumi.String("/dev/sda1"),
+				Ebs: &ec2.LaunchTemplateBlockDeviceMappingEbsArgs{
+					VolumeSize:          pulumi.Int(150),
+					VolumeType:          pulumi.String("gp3"),
+					DeleteOnTermination: pulumi.String("true"),
+					Encrypted:           pulumi.String("true"),
+					KmsKeyId:            cluster.KeyArn,
+				},
+			},
+		},
+		UserData: getWindowsUserData(cluster.Cluster, cluster.KubeDns.Spec.ClusterIP(), args.WindowsPassword, gpuLabels),
+
+		TagSpecifications: ec2.LaunchTemplateTagSpecificationArray{
+			&ec2.LaunchTemplateTagSpecificationArgs{
+				ResourceType: pulumi.String("instance"),
+				Tags: deployment.tags(pulumi.StringMap{
+					"Name": pulumi.String(deployment.name("WindowsLaunchTemplate", "WorkloadCluster")),
+				}),
+			},
+		},
+		Tags: deployment.tags(nil),
+	}, args.MetadataOptions, childOptions(opts, pulumi.DependsOn([]pulumi.Resource{workerSecurityGroup, cluster.Cluster, cluster.KubeDns, cluster.SystemNodeGroup}))...)
+	if err != nil {
+		return err
+	}
+	dependencies := append([]pulumi.Resource{cluster.Cluster, cluster.WindowsWorkerRole, p.LaunchTemplate, cluster.SystemNodeGroup}, args.After...)
+	// EKS takes the Kubernetes version from the custom image, so the node group has no Version. The image of a new
+	// version makes a new launch template version, which rolls the nodes.
+	p.NodeGroup, err = awsEKS.NewNodeGroup(ctx, deployment.name("WindowsNodeGroup", "WorkloadCluster"), &awsEKS.NodeGroupArgs{
+		NodeGroupName: pulumi.String(deployment.resourceName(KIND_NODE_GROUP, "WindowsNodeGroup", "WorkloadCluster")),
+		ClusterName:   cluster.Cluster.EksCluster.Name(),
+		NodeRoleArn:   cluster.WindowsWorkerRole.Arn,
+		UpdateConfig: &awsEKS.NodeGroupUpdateConfigArgs{
+			MaxUnavailable: pulumi.Int(args.MaxUnavailable),
+		},
+		ScalingConfig: &awsEKS.NodeGroupScalingConfigArgs{
+			DesiredSize: pulumi.Int(args.DesiredCapacity),
+			MaxSize:     pulumi.Int(args.MaxSize),
+			MinSize:     pulumi.Int(args.MinSize),
+		},
+		SubnetIds: args.Network.getPublicSubnetIds(),
+		LaunchTemplate: &awsEKS.NodeGroupLaunchTemplateArgs{
+			Id:      p.LaunchTemplate.ID(),
+			Version: p.LaunchTemplate.LatestVersion.ApplyT(strconv.Itoa).(pulumi.StringOutput),
+		},
+		Taints: gpuTaints,
+		Labels: pulumi.ToStringMap(gpuLabels),
+		Tags:   deployment.tags(nil),
+	}, childOptions(opts, pulumi.DependsOn(dependencies))...)
+	return err
+}
@@ -0,0 +1,314 @@
+package workload
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/helm/v3"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	storagev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/storage/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const (
+	KUBE_PROMETHEUS_STACK_CHART_VERSION = "57.2.0"
+	LOKI_CHART_VERSION                  = "5.47.2"
+	PROMTAIL_CHART_VERSION              = "6.15.5"
+
+	// OBSERVABILITY_NAMESPACE is the Kubernetes namespace of Prometheus, Grafana and Loki
+	OBSERVABILITY_NAMESPACE = "monitoring"
+	// GP3_STORAGE_CLASS provisions encrypted gp3 EBS volumes through the EBS CSI driver
+	GP3_STORAGE_CLASS = "gp3"
+)
+
+// lokiUrl is the Loki gateway of the stack, which Promtail and Grafana talk to
+var lokiUrl = "http://loki-gateway." + OBSERVABILITY_NAMESPACE + ".svc.cluster.local"
+
+var retention = regexp.MustCompile(`^[0-9]+(s|m|h|d|w|y)$`)
+
+// ObservabilityConfig is the cluster:observability config object, empty fields take the defaults.
+type ObservabilityConfig struct {
+	PrometheusRetention string `json:"prometheusRetention"`
+	PrometheusStorage   string `json:"prometheusStorage"`
+	LokiRetention       string `json:"lokiRetention"`
+	LokiStorage         string `json:"lokiStorage"`
+	GrafanaStorage      string `json:"grafanaStorage"`
+}
+
+var defaultObservabilityConfig = ObservabilityConfig{
+	PrometheusRetention: "15d",
+	PrometheusStorage:   "50Gi",
+	LokiRetention:       "168h",
+	LokiStorage:         "50Gi",
+	GrafanaStorage:      "10Gi",
+}
+
+// ParseObservabilityConfig decodes a cluster:observability JSON object, nil when the stack is off.
+func ParseObservabilityConfig(raw string) (*ObservabilityConfig, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	config := defaultObservabilityConfig
+	decoder := json.NewDecoder(strings.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		return nil, fmt.Errorf("invalid cluster:observability: %w", err)
+	}
+	for _, field := range []struct {
+		name, value string
+		pattern     *regexp.Regexp
+	}{
+		{"prometheusRetention", config.PrometheusRetention, retention},
+		{"prometheusStorage", config.PrometheusStorage, quantity},
+		{"lokiRetention", config.LokiRetention, retention},
+		{"lokiStorage", config.LokiStorage, quantity},
+		{"grafanaStorage", config.GrafanaStorage, quantity},
+	} {
+		if !field.pattern.MatchString(field.value) {
+			return nil, fmt.Errorf("invalid %s %q in cluster:observability", field.name, field.value)
+		}
+	}
+	return &config, nil
+}
+
+// LoadObservabilityConfig reads cluster:observability.
+func LoadObservabilityConfig(ctx *pulumi.Context) (*ObservabilityConfig, error) {
+	raw, _ := ctx.GetConfig("cluster:observability")
+	return ParseObservabilityConfig(raw)
+}
+
+type ObservabilityArgs struct {
+	Cluster *WorkloadCluster
+	Config  ObservabilityConfig
+	// GrafanaAdminPassword is the password of the Grafana admin user
+	GrafanaAdminPassword pulumi.StringInput
+}
+
+// Observability runs kube-prometheus-stack and Loki on the system pool, with their data on gp3 volumes. Prometheus
+// scrapes every ServiceMonitor of the cluster and the node exporters of the Linux and Windows nodes, Promtail ships
+// the pod logs of the Linux nodes to Loki.
+type Observability struct {
+	pulumi.ResourceState
+
+	Namespace       *corev1.Namespace
+	StorageClass    *storagev1.StorageClass
+	PrometheusStack *helm.Release
+	Loki            *helm.Release
+	Promtail        *helm.Release
+	// GrafanaAdminPassword is a secret
+	GrafanaAdminPassword pulumi.StringOutput
+}
+
+func systemNodeSelector() pulumi.StringMap {
+	return pulumi.StringMap{"type": pulumi.String("system")}
+}
+
+// gp3Volume is the persistent volume claim spec of the charts.
+func gp3Volume(size string) pulumi.Map {
+	return pulumi.Map{
+		"storageClassName": pulumi.String(GP3_STORAGE_CLASS),
+		"accessModes":      pulumi.StringArray{pulumi.String("ReadWriteOnce")},
+		"resources": pulumi.Map{
+			"requests": pulumi.StringMap{"storage": pulumi.String(size)},
+		},
+	}
+}
+
+func NewObservability(ctx *pulumi.Context, name string, args *ObservabilityArgs, opts ...pulumi.ResourceOption) (*Observability, error) {
+	if args == nil || args.Cluster == nil || args.GrafanaAdminPassword == nil {
+		return nil, errors.New("Observability requires a Cluster and a GrafanaAdminPassword")
+	}
+	cluster := args.Cluster
+	config := args.Config
+	component := &Observability{}
+	err := ctx.RegisterComponentResource(TYPE_OBSERVABILITY, name, component, opts...)
+	if err != nil {
+		return nil, err
+	}
+	k8sOpts := []pulumi.ResourceOption{pulumi.Parent(component), pulumi.Provider(cluster.Provider)}
+	component.Namespace, err = corev1.NewNamespace(ctx, name, &corev1.NamespaceArgs{
+		Metadata: metav1.ObjectMetaArgs{Name: pulumi.String(OBSERVABILITY_NAMESPACE)},
+	}, k8sOpts...)
+	if err != nil {
+		return nil, err
+	}
+	// Volumes are only provisioned once the EBS CSI driver runs
+	storageDependencies := []pulumi.Resource{cluster.Cluster}
+	if addon, ok := cluster.Addons["aws-ebs-csi-driver"]; ok {
+		storageDependencies = append(storageDependencies, addon)
+	}
+	component.StorageClass, err = storagev1.NewStorageClass(ctx, name+"-"+GP3_STORAGE_CLASS, &storagev1.StorageClassArgs{
+		Metadata:             metav1.ObjectMetaArgs{Name: pulumi.String(GP3_STORAGE_CLASS)},
+		Provisioner:          pulumi.String("ebs.csi.aws.com"),
+		Parameters:           pulumi.StringMap{"type": pulumi.String("gp3"), "encrypted": pulumi.String("true")},
+		VolumeBindingMode:    pulumi.String("WaitForFirstConsumer"),
+		AllowVolumeExpansion: pulumi.Bool(true),
+	}, childOptions(k8sOpts, pulumi.DependsOn(storageDependencies))...)
+	if err != nil {
+		return nil, err
+	}
+	component.GrafanaAdminPassword = pulumi.ToSecret(args.GrafanaAdminPassword.ToStringOutput()).(pulumi.StringOutput)
+	adminSecret, err := corev1.NewSecret(ctx, name+"-grafana-admin", &corev1.SecretArgs{
+		Metadata: metav1.ObjectMetaArgs{
+			Name:      pulumi.String("grafana-admin"),
+			Namespace: pulumi.String(OBSERVABILITY_NAMESPACE),
+		},
+		StringData: pulumi.StringMap{
+			"admin-user":     pulumi.String("admin"),
+			"admin-password": component.GrafanaAdminPassword,
+		},
+	}, childOptions(k8sOpts, pulumi.DependsOn([]pulumi.Resource{component.Namespace}))...)
+	if err != nil {
+		return nil, err
+	}
+	releaseOpts := childOptions(k8sOpts, pulumi.DependsOn([]pulumi.Resource{component.Namespace, component.StorageClass}))
+	component.PrometheusStack, err = helm.NewRelease(ctx, name+"-kube-prometheus-stack", &helm.ReleaseArgs{
+		Namespace: pulumi.String(OBSERVABILITY_NAMESPACE),
+		Name:      pulumi.String("kube-prometheus-stack"),
+		RepositoryOpts: helm.RepositoryOptsArgs{
+			Repo: pulumi.String("https://prometheus-community.github.io/helm-charts"),
+		},
+		Chart:   pulumi.String("kube-prometheus-stack"),
+		Version: pulumi.String(KUBE_PROMETHEUS_STACK_CHART_VERSION),
+		Values: pulumi.Map{
+			"prometheusOperator": pulumi.Map{
+				"nodeSelector": systemNodeSelector(),
+				"admissionWebhooks": pulumi.Map{
+					"patch": pulumi.Map{"nodeSelector": systemNodeSelector()},
+				},
+			},
+			"prometheus": pulumi.Map{
+				"prometheusSpec": pulumi.Map{
+					"nodeSelector": systemNodeSelector(),
+					"retention":    pulumi.String(config.PrometheusRetention),
+					// Scrape the monitors of every release, not only the ones labelled for this chart
+					"serviceMonitorSelectorNilUsesHelmValues": pulumi.Bool(false),
+					"podMonitorSelectorNilUsesHelmValues":     pulumi.Bool(false),
+					"storageSpec": pulumi.Map{
+						"volumeClaimTemplate": pulumi.Map{"spec": gp3Volume(config.PrometheusStorage)},
+					},
+				},
+			},
+			"alertmanager": pulumi.Map{
+				"alertmanagerSpec": pulumi.Map{"nodeSelector": systemNodeSelector()},
+			},
+			"grafana": pulumi.Map{
+				"nodeSelector": systemNodeSelector(),
+				"admin": pulumi.Map{
+					"existingSecret": adminSecret.Metadata.Name(),
+					"userKey":        pulumi.String("admin-user"),
+					"passwordKey":    pulumi.String("admin-password"),
+				},
+				"persistence": pulumi.Map{
+					"enabled":          pulumi.Bool(true),
+					"storageClassName": pulumi.String(GP3_STORAGE_CLASS),
+					"size":             pulumi.String(config.GrafanaStorage),
+				},
+				"additionalDataSources": pulumi.Array{
+					pulumi.Map{
+						"name":   pulumi.String("Loki"),
+						"type":   pulumi.String("loki"),
+						"access": pulumi.String("proxy"),
+						"url":    pulumi.String(lokiUrl),
+					},
+				},
+			},
+			"kube-state-metrics": pulumi.Map{
+				"nodeSelector": systemNodeSelector(),
+			},
+			"prometheus-node-exporter": pulumi.Map{
+				"nodeSelector": pulumi.StringMap{"kubernetes.io/os": pulumi.String("linux")},
+				"tolerations":  gpuTolerations(),
+			},
+			"windowsMonitoring": pulumi.Map{
+				"enabled": pulumi.Bool(true),
+			},
+			"prometheus-windows-exporter": pulumi.Map{
+				"tolerations": gpuTolerations(),
+				"prometheus": pulumi.Map{
+					"monitor": pulumi.Map{"enabled": pulumi.Bool(true)},
+				},
+			},
+		},
+	}, childOptions(releaseOpts, pulumi.DependsOn([]pulumi.Resource{adminSecret}))...)
+	if err != nil {
+		return nil, err
+	}
+	component.Loki, err = helm.NewRelease(ctx, name+"-loki", &helm.ReleaseArgs{
+		Namespace: pulumi.String(OBSERVABILITY_NAMESPACE),
+		Name:      pulumi.String("loki"),
+		RepositoryOpts: helm.RepositoryOptsArgs{
+			Repo: pulumi.String("https://grafana.github.io/helm-charts"),
+		},
+		Chart:   pulumi.String("loki"),
+		Version: pulumi.String(LOKI_CHART_VERSION),
+		Values: pulumi.Map{
+			"loki": pulumi.Map{
+				"auth_enabled":  pulumi.Bool(false),
+				"commonConfig":  pulumi.Map{"replication_factor": pulumi.Int(1)},
+				"storage":       pulumi.Map{"type": pulumi.String("filesystem")},
+				"limits_config": pulumi.Map{"retention_period": pulumi.String(config.LokiRetention)},
+				// The compactor deletes the logs older than the retention period
+				"compactor": pulumi.Map{
+					"retention_enabled": pulumi.Bool(true),
+					"shared_store":      pulumi.String("filesystem"),
+				},
+			},
+			"singleBinary": pulumi.Map{
+				"replicas":     pulumi.Int(1),
+				"nodeSelector": systemNodeSelector(),
+				"persistence": pulumi.Map{
+					"storageClass": pulumi.String(GP3_STORAGE_CLASS),
+					"size":         pulumi.String(config.LokiStorage),
+				},
+			},
+			"gateway": pulumi.Map{
+				"nodeSelector": systemNodeSelector(),
+			},
+			"monitoring": pulumi.Map{
+				"selfMonitoring": pulumi.Map{
+					"enabled":      pulumi.Bool(false),
+					"grafanaAgent": pulumi.Map{"installOperator": pulumi.Bool(false)},
+				},
+				"lokiCanary": pulumi.Map{"enabled": pulumi.Bool(false)},
+			},
+			"test": pulumi.Map{"enabled": pulumi.Bool(false)},
+		},
+	}, releaseOpts...)
+	if err != nil {
+		return nil, err
+	}
+	component.Promtail, err = helm.NewRelease(ctx, name+"-promtail", &helm.ReleaseArgs{
+		Namespace: pulumi.String(OBSERVABILITY_NAMESPACE),
+		Name:      pulumi.String("promtail"),
+		RepositoryOpts: helm.RepositoryOptsArgs{
+			Repo: pulumi.String("https://grafana.github.io/helm-charts"),
+		},
+		Chart:   pulumi.String("promtail"),
+		Version: pulumi.String(PROMTAIL_CHART_VERSION),
+		Values: pulumi.Map{
+			"nodeSelector": pulumi.StringMap{"kubernetes.io/os": pulumi.String("linux")},
+			"tolerations":  gpuTolerations(),
+			"config": pulumi.Map{
+				"clients": pulumi.Array{
+					pulumi.Map{"url": pulumi.String(lokiUrl + "/loki/api/v1/push")},
+				},
+			},
+		},
+	}, childOptions(k8sOpts, pulumi.DependsOn([]pulumi.Resource{component.Loki}))...)
+	if err != nil {
+		return nil, err
+	}
+	err = ctx.RegisterResourceOutputs(component, pulumi.Map{
+		"namespace":            pulumi.String(OBSERVABILITY_NAMESPACE),
+		"grafanaAdminPassword": component.GrafanaAdminPassword,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return component, nil
+}
@@ -0,0 +1,32 @@
+package workload
+
+import "testing"
+
+func TestParseObservabilityConfig(t *testing.T) {
+	config, err := ParseObservabilityConfig("")
+	if err != nil || config != nil {
+		t.Fatalf("expected no stack without config, got %v %v", config, err)
+	}
+	config, err = ParseObservabilityConfig(`{}`)
+	if err != nil || *config != defaultObservabilityConfig {
+		t.Fatalf("expected the defaults, got %v %v", config, err)
+	}
+	config, err = ParseObservabilityConfig(`{"prometheusRetention":"30d","lokiStorage":"200Gi"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.PrometheusRetention != "30d" || config.LokiStorage != "200Gi" || config.LokiRetention != defaultObservabilityConfig.LokiRetention {
+		t.Errorf("expected the set fields over the defaults, got %+v", config)
+	}
+	for _, raw := range []string{
+		`{"prometheusRetention":"30 days"}`,
+		`{"lokiRetention":"-1h"}`,
+		`{"grafanaStorage":"ten"}`,
+		`{"retention":"30d"}`,
+		`[]`,
+	} {
+		if _, err := ParseObservabilityConfig(raw); err == nil {
+			t.Errorf("%s: expected an error", raw)
+		}
+	}
+}
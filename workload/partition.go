@@ -0,0 +1,64 @@
+package workload
+
+import (
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// Partition describes the AWS partition (aws, aws-cn, aws-us-gov) the stack is deployed into.
+type Partition struct {
+	Name      string
+	DnsSuffix string
+}
+
+func LookupPartition(ctx *pulumi.Context) (*Partition, error) {
+	result, err := aws.GetPartition(ctx, &aws.GetPartitionArgs{})
+	if err != nil {
+		return nil, err
+	}
+	return &Partition{
+		Name:      result.Partition,
+		DnsSuffix: result.DnsSuffix,
+	}, nil
+}
+
+// servicePrincipal returns the IAM service principal for the service in this partition.
+// EC2 is the only principal used here that follows the partition DNS suffix
+// (ec2.amazonaws.com.cn in China); EKS keeps eks.amazonaws.com everywhere.
+func (p *Partition) servicePrincipal(service string) string {
+	if service == "ec2" && p.DnsSuffix != "" {
+		return service + "." + p.DnsSuffix
+	}
+	return service + ".amazonaws.com"
+}
+
+// regionalServicePrincipal returns principals such as logs.us-east-1.amazonaws.com
+// that some services use in key and trust policies.
+func (p *Partition) regionalServicePrincipal(service string, region string) string {
+	dnsSuffix := p.DnsSuffix
+	if dnsSuffix == "" {
+		dnsSuffix = "amazonaws.com"
+	}
+	return service + "." + region + "." + dnsSuffix
+}
+
+func (p *Partition) managedPolicyArns(names ...string) []string {
+	return managedPolicyArns(p.Name, names...)
+}
+
+func (p *Partition) iamUserArn(accountId string, username string) string {
+	return iamUserArn(p.Name, accountId, username)
+}
+
+func (p *Partition) accountRootArn(accountId string) string {
+	return "arn:" + p.Name + ":iam::" + accountId + ":root"
+}
+
+func (p *Partition) autoscalingServiceLinkedRoleArn(accountId string) string {
+	return "arn:" + p.Name + ":iam::" + accountId + ":role/aws-service-role/autoscaling.amazonaws.com/AWSServiceRoleForAutoScaling"
+}
+
+// managedNodeGroupAsgArn matches the Auto Scaling groups EKS creates for managed node groups, which are named eks-*.
+func (p *Partition) managedNodeGroupAsgArn(region string, accountId string) string {
+	return "arn:" + p.Name + ":autoscaling:" + region + ":" + accountId + ":autoScalingGroup:*:autoScalingGroupName/eks-*"
+}
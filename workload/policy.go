@@ -0,0 +1,110 @@
+package workload
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+const (
+	POLICY_VERSION = "2012-10-17"
+
+	PARTITION_AWS        = "aws"
+	PARTITION_AWS_CN     = "aws-cn"
+	PARTITION_AWS_US_GOV = "aws-us-gov"
+)
+
+// PolicyDocument is the subset of the IAM policy grammar used by this stack.
+type PolicyDocument struct {
+	Version   string            `json:"Version"`
+	Statement []PolicyStatement `json:"Statement"`
+}
+
+type PolicyStatement struct {
+	Sid       string           `json:"Sid,omitempty"`
+	Effect    string           `json:"Effect"`
+	Principal *PolicyPrincipal `json:"Principal,omitempty"`
+	Action    []string         `json:"Action"`
+	Resource  []string         `json:"Resource,omitempty"`
+	Condition PolicyCondition  `json:"Condition,omitempty"`
+}
+
+type PolicyPrincipal struct {
+	AWS       []string `json:"AWS,omitempty"`
+	Service   []string `json:"Service,omitempty"`
+	Federated []string `json:"Federated,omitempty"`
+}
+
+// PolicyCondition maps a condition operator (e.g. StringEquals) to its key/value pairs.
+type PolicyCondition map[string]map[string][]string
+
+func newPolicyDocument(statements ...PolicyStatement) PolicyDocument {
+	return PolicyDocument{
+		Version:   POLICY_VERSION,
+		Statement: statements,
+	}
+}
+
+func allowStatement(actions []string, resources ...string) PolicyStatement {
+	return PolicyStatement{
+		Effect:   "Allow",
+		Action:   actions,
+		Resource: resources,
+	}
+}
+
+func serviceTrustStatement(services ...string) PolicyStatement {
+	return PolicyStatement{
+		Effect:    "Allow",
+		Principal: &PolicyPrincipal{Service: services},
+		Action:    []string{"sts:AssumeRole"},
+	}
+}
+
+func federatedTrustStatement(providerArn string, condition PolicyCondition) PolicyStatement {
+	return PolicyStatement{
+		Effect:    "Allow",
+		Principal: &PolicyPrincipal{Federated: []string{providerArn}},
+		Action:    []string{"sts:AssumeRoleWithWebIdentity"},
+		Condition: condition,
+	}
+}
+
+func (d PolicyDocument) JSON() (string, error) {
+	bytes, err := json.Marshal(d)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+// managedPolicyArn returns the ARN of an AWS managed policy in the given partition.
+func managedPolicyArn(partition string, name string) string {
+	if partition == "" {
+		partition = PARTITION_AWS
+	}
+	return "arn:" + partition + ":iam::aws:policy/" + name
+}
+
+func iamUserArn(partition string, accountId string, username string) string {
+	if partition == "" {
+		partition = PARTITION_AWS
+	}
+	return "arn:" + partition + ":iam::" + accountId + ":user/" + username
+}
+
+// managedPolicyArns resolves the given managed policy names in the partition,
+// dropping duplicates and returning them in a stable order.
+func managedPolicyArns(partition string, names ...string) []string {
+	seen := map[string]bool{}
+	arns := []string{}
+	for _, name := range names {
+		arn := managedPolicyArn(partition, name)
+		if seen[arn] {
+			continue
+		}
+		seen[arn] = true
+		arns = append(arns, arn)
+	}
+	sort.Strings(arns)
+	return arns
+}
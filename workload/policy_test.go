@@ -0,0 +1,156 @@
+package workload
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestServiceTrustPolicyJSON(t *testing.T) {
+	got, err := newPolicyDocument(serviceTrustStatement("eks.amazonaws.com")).JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"Service":["eks.amazonaws.com"]},"Action":["sts:AssumeRole"]}]}`
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestFederatedTrustPolicyCondition(t *testing.T) {
+	doc := newPolicyDocument(federatedTrustStatement("arn:aws:iam::123456789012:oidc-provider/example", PolicyCondition{
+		"StringEquals": {
+			"example:sub": {"system:serviceaccount:kube-system:cluster-autoscaler"},
+		},
+	}))
+	got, err := doc.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded PolicyDocument
+	if err := json.Unmarshal([]byte(got), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, doc) {
+		t.Errorf("round trip mismatch: got %+v, want %+v", decoded, doc)
+	}
+	statement := decoded.Statement[0]
+	if statement.Action[0] != "sts:AssumeRoleWithWebIdentity" {
+		t.Errorf("unexpected action %v", statement.Action)
+	}
+	if statement.Principal.Service != nil || statement.Principal.AWS != nil {
+		t.Errorf("unexpected principal %+v", statement.Principal)
+	}
+}
+
+func TestAllowStatementResources(t *testing.T) {
+	got, err := newPolicyDocument(allowStatement([]string{"ec2:DescribeImages"}, "*")).JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["ec2:DescribeImages"],"Resource":["*"]}]}`
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestManagedPolicyArnsPartition(t *testing.T) {
+	tests := []struct {
+		partition string
+		want      string
+	}{
+		{"", "arn:aws:iam::aws:policy/AmazonEKSClusterPolicy"},
+		{PARTITION_AWS, "arn:aws:iam::aws:policy/AmazonEKSClusterPolicy"},
+		{PARTITION_AWS_CN, "arn:aws-cn:iam::aws:policy/AmazonEKSClusterPolicy"},
+		{PARTITION_AWS_US_GOV, "arn:aws-us-gov:iam::aws:policy/AmazonEKSClusterPolicy"},
+	}
+	for _, test := range tests {
+		if got := managedPolicyArn(test.partition, "AmazonEKSClusterPolicy"); got != test.want {
+			t.Errorf("managedPolicyArn(%q) = %s, want %s", test.partition, got, test.want)
+		}
+	}
+}
+
+func TestManagedPolicyArnsDeduplicates(t *testing.T) {
+	got := managedPolicyArns(PARTITION_AWS,
+		"AmazonEKSWorkerNodePolicy",
+		"AmazonEC2ContainerRegistryReadOnly",
+		"AmazonSSMManagedInstanceCore",
+		"AmazonEC2ContainerRegistryReadOnly",
+	)
+	want := []string{
+		"arn:aws:iam::aws:policy/AmazonEC2ContainerRegistryReadOnly",
+		"arn:aws:iam::aws:policy/AmazonEKSWorkerNodePolicy",
+		"arn:aws:iam::aws:policy/AmazonSSMManagedInstanceCore",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestIamUserArn(t *testing.T) {
+	if got := iamUserArn(PARTITION_AWS_US_GOV, "123456789012", "admin"); got != "arn:aws-us-gov:iam::123456789012:user/admin" {
+		t.Errorf("unexpected user arn %s", got)
+	}
+}
+
+func TestClusterAutoscalerPolicyDocument(t *testing.T) {
+	partition := &Partition{Name: PARTITION_AWS_US_GOV}
+	doc := clusterAutoscalerPolicyDocument(partition, "us-gov-west-1", "123456789012")
+	if len(doc.Statement) != 2 {
+		t.Fatalf("expected 2 statements, got %d", len(doc.Statement))
+	}
+	for _, statement := range doc.Statement {
+		if statement.Effect != "Allow" || statement.Principal != nil {
+			t.Errorf("unexpected statement %+v", statement)
+		}
+	}
+	want := "arn:aws-us-gov:autoscaling:us-gov-west-1:123456789012:autoScalingGroup:*:autoScalingGroupName/eks-*"
+	if got := doc.Statement[1].Resource; !reflect.DeepEqual(got, []string{want}) {
+		t.Errorf("expected the mutating actions on %s, got %v", want, got)
+	}
+}
+
+func TestPartitionServicePrincipal(t *testing.T) {
+	tests := []struct {
+		partition Partition
+		service   string
+		want      string
+	}{
+		{Partition{Name: PARTITION_AWS, DnsSuffix: "amazonaws.com"}, "ec2", "ec2.amazonaws.com"},
+		{Partition{Name: PARTITION_AWS_CN, DnsSuffix: "amazonaws.com.cn"}, "ec2", "ec2.amazonaws.com.cn"},
+		{Partition{Name: PARTITION_AWS_CN, DnsSuffix: "amazonaws.com.cn"}, "eks", "eks.amazonaws.com"},
+		{Partition{Name: PARTITION_AWS_US_GOV, DnsSuffix: "amazonaws.com"}, "ec2", "ec2.amazonaws.com"},
+	}
+	for _, test := range tests {
+		if got := test.partition.servicePrincipal(test.service); got != test.want {
+			t.Errorf("%s servicePrincipal(%q) = %s, want %s", test.partition.Name, test.service, got, test.want)
+		}
+	}
+}
+
+func indentedJSON(t *testing.T, doc PolicyDocument) string {
+	t.Helper()
+	bytes, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(bytes) + "\n"
+}
+
+func TestPolicyDocumentsGolden(t *testing.T) {
+	partition := &Partition{Name: PARTITION_AWS, DnsSuffix: "amazonaws.com"}
+	accountId := "123456789012"
+	roleArns := []string{"arn:aws:iam::123456789012:role/dev-eks-cluster", "arn:aws:iam::123456789012:role/dev-eks-worker"}
+	documents := map[string]PolicyDocument{
+		"cluster-autoscaler": clusterAutoscalerPolicyDocument(partition, "us-east-1", accountId),
+		"cluster-key":        clusterKeyPolicyDocument(partition, accountId, roleArns),
+		"logs-key":           logsKeyPolicyDocument(partition, "us-east-1", accountId, "/aws/eks/dev-eks/cluster"),
+		"service-account-trust": serviceAccountTrustPolicyDocument("arn:aws:iam::123456789012:oidc-provider/oidc.eks.us-east-1.amazonaws.com/id/0123",
+			"https://oidc.eks.us-east-1.amazonaws.com/id/0123", "kube-system", "ebs-csi-controller-sa"),
+		"worker-trust": newPolicyDocument(serviceTrustStatement(partition.servicePrincipal("ec2"))),
+	}
+	for name, doc := range documents {
+		assertGolden(t, "iam/"+name+".json", indentedJSON(t, doc))
+	}
+}
@@ -0,0 +1,95 @@
+package workload
+
+import (
+	"strings"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
+	"github.com/pulumi/pulumi-eks/sdk/v2/go/eks"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+func createClusterRole(ctx *pulumi.Context, deployment *Deployment, roleName string, opts ...pulumi.ResourceOption) (*iam.Role, error) {
+	assumeRolePolicy, err := newPolicyDocument(serviceTrustStatement(deployment.Partition.servicePrincipal("eks"))).JSON()
+	if err != nil {
+		return nil, err
+	}
+	clusterRole, err := iam.NewRole(ctx, roleName, &iam.RoleArgs{
+		Name:             pulumi.StringPtrFromPtr(deployment.Names.autoName(KIND_IAM_ROLE, roleName)),
+		AssumeRolePolicy: pulumi.String(assumeRolePolicy),
+		// Attach the AmazonEksCluster and AmazonEksVpcResourceController policies
+		ManagedPolicyArns: pulumi.ToStringArray(deployment.Partition.managedPolicyArns(
+			"AmazonEKSClusterPolicy",
+			"AmazonEKSVPCResourceController",
+		)),
+		Tags: deployment.tags(nil),
+	}, opts...)
+	return clusterRole, err
+}
+func createWorkerRole(ctx *pulumi.Context, deployment *Deployment, roleName string, opts ...pulumi.ResourceOption) (*iam.Role, error) {
+	assumeRolePolicy, err := newPolicyDocument(serviceTrustStatement(deployment.Partition.servicePrincipal("ec2"))).JSON()
+	if err != nil {
+		return nil, err
+	}
+	workerRole, err := iam.NewRole(ctx, roleName, &iam.RoleArgs{
+		Name:             pulumi.StringPtrFromPtr(deployment.Names.autoName(KIND_IAM_ROLE, roleName)),
+		AssumeRolePolicy: pulumi.String(assumeRolePolicy),
+		ManagedPolicyArns: pulumi.ToStringArray(deployment.Partition.managedPolicyArns(
+			"AmazonEKSWorkerNodePolicy",
+			"AmazonEKS_CNI_Policy",
+			"AmazonEC2ContainerRegistryReadOnly",
+			"AmazonSSMManagedInstanceCore",
+		)),
+		Tags: deployment.tags(nil),
+	}, opts...)
+	return workerRole, err
+}
+
+// createServiceAccountRole creates an IRSA role that only the given Kubernetes service account can assume.
+func createServiceAccountRole(ctx *pulumi.Context, deployment *Deployment, roleName string, cluster *eks.Cluster, namespace string, serviceAccount string, managedPolicyArns []string, opts ...pulumi.ResourceOption) (*iam.Role, error) {
+	oidcProvider := cluster.Core.OidcProvider()
+	assumeRolePolicy := pulumi.All(oidcProvider.Arn(), oidcProvider.Url()).ApplyT(func(args []interface{}) (string, error) {
+		return serviceAccountTrustPolicyDocument(args[0].(string), args[1].(string), namespace, serviceAccount).JSON()
+	}).(pulumi.StringOutput)
+	role, err := iam.NewRole(ctx, roleName, &iam.RoleArgs{
+		AssumeRolePolicy:  assumeRolePolicy,
+		Name:              pulumi.String(deployment.Names.name(KIND_IAM_ROLE, roleName)),
+		ManagedPolicyArns: pulumi.ToStringArray(managedPolicyArns),
+		Tags:              deployment.tags(nil),
+	}, opts...)
+	return role, err
+}
+
+// serviceAccountTrustPolicyDocument lets only the given Kubernetes service account assume a role through the OIDC
+// provider of the cluster.
+func serviceAccountTrustPolicyDocument(providerArn string, issuerUrl string, namespace string, serviceAccount string) PolicyDocument {
+	issuer := strings.TrimPrefix(issuerUrl, "https://")
+	return newPolicyDocument(federatedTrustStatement(providerArn, PolicyCondition{
+		"StringEquals": {
+			issuer + ":sub": {"system:serviceaccount:" + namespace + ":" + serviceAccount},
+			issuer + ":aud": {"sts.amazonaws.com"},
+		},
+	}))
+}
+
+// clusterAutoscalerPolicyDocument allows the autoscaler to discover every group, but to only scale the groups of
+// managed node groups.
+func clusterAutoscalerPolicyDocument(partition *Partition, region string, accountId string) PolicyDocument {
+	return newPolicyDocument(
+		allowStatement([]string{
+			"autoscaling:DescribeAutoScalingGroups",
+			"autoscaling:DescribeAutoScalingInstances",
+			"autoscaling:DescribeLaunchConfigurations",
+			"autoscaling:DescribeScalingActivities",
+			"autoscaling:DescribeTags",
+			"ec2:DescribeImages",
+			"ec2:DescribeInstanceTypes",
+			"ec2:DescribeLaunchTemplateVersions",
+			"ec2:GetInstanceTypesFromInstanceRequirements",
+			"eks:DescribeNodegroup",
+		}, "*"),
+		allowStatement([]string{
+			"autoscaling:SetDesiredCapacity",
+			"autoscaling:TerminateInstanceInAutoScalingGroup",
+		}, partition.managedNodeGroupAsgArn(region, accountId)),
+	)
+}
@@ -0,0 +1,105 @@
+package workload
+
+import (
+	"encoding/json"
+	"errors"
+
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	networkingv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/networking/v1"
+	rbacv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/rbac/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+type TenantArgs struct {
+	Deployment *Deployment
+	Cluster    *WorkloadCluster
+	Config     TenantConfig
+}
+
+// Tenant is the namespace of one team with its quota, default limits, network isolation and the access of its
+// IAM role. The cluster maps the role to the group of the tenant, see WorkloadClusterArgs.Tenants.
+type Tenant struct {
+	pulumi.ResourceState
+
+	Namespace     *corev1.Namespace
+	ResourceQuota *corev1.ResourceQuota
+	LimitRange    *corev1.LimitRange
+	NetworkPolicy *networkingv1.NetworkPolicy
+	Role          *rbacv1.Role
+	RoleBinding   *rbacv1.RoleBinding
+	// Kubeconfig assumes the role of the tenant and defaults to its namespace
+	Kubeconfig pulumi.StringOutput
+}
+
+func NewTenant(ctx *pulumi.Context, name string, args *TenantArgs, opts ...pulumi.ResourceOption) (*Tenant, error) {
+	if args == nil || args.Deployment == nil || args.Cluster == nil {
+		return nil, errors.New("Tenant requires a Deployment and a Cluster")
+	}
+	deployment := args.Deployment
+	cluster := args.Cluster
+	tenant := args.Config
+	if err := tenant.validate(); err != nil {
+		return nil, err
+	}
+	tenant.Limits.defaults()
+	component := &Tenant{}
+	err := ctx.RegisterComponentResource(TYPE_TENANT, name, component, opts...)
+	if err != nil {
+		return nil, err
+	}
+	k8sOpts := childOptions([]pulumi.ResourceOption{pulumi.Parent(component)}, pulumi.Provider(cluster.Provider))
+	component.Namespace, err = createTenantNamespace(ctx, name, tenant, k8sOpts...)
+	if err != nil {
+		return nil, err
+	}
+	namespacedOpts := childOptions(k8sOpts, pulumi.DependsOn([]pulumi.Resource{component.Namespace}))
+	component.ResourceQuota, err = createTenantResourceQuota(ctx, name, tenant, namespacedOpts...)
+	if err != nil {
+		return nil, err
+	}
+	component.LimitRange, err = createTenantLimitRange(ctx, name, tenant, namespacedOpts...)
+	if err != nil {
+		return nil, err
+	}
+	component.NetworkPolicy, err = createTenantNetworkPolicy(ctx, name, tenant, namespacedOpts...)
+	if err != nil {
+		return nil, err
+	}
+	component.Role, err = createTenantRole(ctx, name, tenant, namespacedOpts...)
+	if err != nil {
+		return nil, err
+	}
+	component.RoleBinding, err = createTenantRoleBinding(ctx, name, tenant, component.Role, namespacedOpts...)
+	if err != nil {
+		return nil, err
+	}
+	eksCluster := cluster.Cluster.EksCluster
+	component.Kubeconfig = pulumi.All(eksCluster.Name(), eksCluster.Endpoint(), eksCluster.CertificateAuthority().Data()).ApplyT(func(args []interface{}) (string, error) {
+		kubeconfig, err := NewKubeconfig(KubeconfigArgs{
+			ClusterName:          args[0].(string),
+			Endpoint:             args[1].(string),
+			CertificateAuthority: *args[2].(*string),
+			Region:               deployment.Region,
+			ContextName:          args[0].(string) + "-" + tenant.Name,
+			RoleArn:              tenant.RoleArn,
+			Namespace:            tenant.Name,
+			TunnelPort:           cluster.TunnelPort,
+		})
+		if err != nil {
+			return "", err
+		}
+		bytes, err := json.Marshal(kubeconfig)
+		if err != nil {
+			return "", errors.New("failed to marshal kubeconfig")
+		}
+		return string(bytes), nil
+	}).(pulumi.StringOutput)
+	err = ctx.RegisterResourceOutputs(component, pulumi.Map{
+		"namespace":  pulumi.String(tenant.Name),
+		"kubeconfig": pulumi.ToSecret(component.Kubeconfig),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return component, nil
+}
@@ -0,0 +1,43 @@
+$ErrorActionPreference = 'Stop'
+$smi = Join-Path $env:SystemRoot 'System32\nvidia-smi.exe'
+$metrics = [ordered]@{
+    'DCGM_FI_DEV_GPU_UTIL'       = @('utilization.gpu', 'GPU utilization in percent')
+    'DCGM_FI_DEV_FB_USED'        = @('memory.used', 'GPU memory used in MiB')
+    'DCGM_FI_DEV_ENC_UTIL'       = @('utilization.encoder', 'Encoder utilization in percent')
+    'nvidia_encoder_sessions'    = @('encoder.stats.sessionCount', 'Active encoder sessions')
+    'DCGM_FI_DEV_GPU_TEMP'       = @('temperature.gpu', 'GPU temperature in degrees Celsius')
+}
+$names = @($metrics.Keys)
+$fields = @('index', 'uuid', 'name') + ($names | ForEach-Object { $metrics[$_][0] })
+function Get-Metrics {
+    $lines = & $smi "--query-gpu=$($fields -join ',')" --format=csv,noheader,nounits
+    $output = [System.Text.StringBuilder]::new()
+    for ($i = 0; $i -lt $names.Count; $i++) {
+        $name = $names[$i]
+        [void]$output.AppendLine("# HELP $name $($metrics[$name][1])")
+        [void]$output.AppendLine("# TYPE $name gauge")
+        foreach ($line in $lines) {
+            $values = $line -split ',\s*'
+            # Readings the GPU or driver does not support are [N/A]
+            if ($values[3 + $i] -match '^[0-9.]+$') {
+                [void]$output.AppendLine("${name}{gpu=""$($values[0])"",UUID=""$($values[1])"",modelName=""$($values[2])"",Hostname=""$env:COMPUTERNAME""} $($values[3 + $i])")
+            }
+        }
+    }
+    $output.ToString()
+}
+$listener = [System.Net.HttpListener]::new()
+$listener.Prefixes.Add('http://+:9835/')
+$listener.Start()
+while ($listener.IsListening) {
+    $context = $listener.GetContext()
+    try {
+        $body = [System.Text.Encoding]::UTF8.GetBytes((Get-Metrics))
+        $context.Response.ContentType = 'text/plain; version=0.0.4'
+        $context.Response.OutputStream.Write($body, 0, $body.Length)
+    } catch {
+        $context.Response.StatusCode = 500
+    } finally {
+        $context.Response.Close()
+    }
+}
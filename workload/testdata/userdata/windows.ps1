@@ -0,0 +1,6 @@
+<powershell>
+net user Administrator 'it''s $ecret; "quoted"'
+[string]$EKSBootstrapScriptFile = "$env:ProgramFiles\Amazon\EKS\Start-EKSBootstrap.ps1"
+& $EKSBootstrapScriptFile -EKSClusterName 'dev-eks' -APIServerEndpoint 'https://0123456789ABCDEF.gr7.us-east-1.eks.amazonaws.com' -Base64ClusterCA 'LS0tLS1CRUdJTiBDRVJUSUZJQ0FURS0tLS0tCg==' -DNSClusterIP '172.20.0.10' -ContainerRuntime containerd -KubeletExtraArgs '--node-labels=os=windows,workload=gpu' 3>&1 4>&1 5>&1 6>&1
+</powershell>
+<persist>true</persist>
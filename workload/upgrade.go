@@ -0,0 +1,127 @@
+package workload
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	awsEKS "github.com/pulumi/pulumi-aws/sdk/v6/go/aws/eks"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ssm"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// UpgradePlan holds what was resolved before an in-place cluster upgrade is allowed to proceed.
+type UpgradePlan struct {
+	CurrentVersion       string
+	TargetVersion        string
+	SystemReleaseVersion string
+	LinuxReleaseVersion  string
+	// WindowsImageId is the XBeam Windows image built for the target version
+	WindowsImageId string
+}
+
+func parseKubernetesVersion(version string) (int, int, error) {
+	parts := strings.Split(version, ".")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid kubernetes version %q, expected <major>.<minor>", version)
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid kubernetes version %q: %w", version, err)
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid kubernetes version %q: %w", version, err)
+	}
+	return major, minor, nil
+}
+
+// validateUpgradeStep only allows staying on the current version or moving one minor version up,
+// which is all EKS supports for an in-place control plane upgrade.
+func validateUpgradeStep(current string, target string) error {
+	currentMajor, currentMinor, err := parseKubernetesVersion(current)
+	if err != nil {
+		return err
+	}
+	targetMajor, targetMinor, err := parseKubernetesVersion(target)
+	if err != nil {
+		return err
+	}
+	if currentMajor != targetMajor {
+		return fmt.Errorf("cannot upgrade from %s to %s: major version change", current, target)
+	}
+	if targetMinor < currentMinor {
+		return fmt.Errorf("cannot downgrade from %s to %s", current, target)
+	}
+	if targetMinor > currentMinor+1 {
+		return fmt.Errorf("cannot upgrade from %s to %s: upgrade one minor version at a time", current, target)
+	}
+	return nil
+}
+
+func lookupSSMParameter(ctx *pulumi.Context, name string) (string, error) {
+	parameter, err := ssm.LookupParameter(ctx, &ssm.LookupParameterArgs{
+		Name: name,
+	})
+	if err != nil {
+		return "", err
+	}
+	if parameter.Value == "" {
+		return "", errors.New("empty value for parameter " + name)
+	}
+	return parameter.Value, nil
+}
+
+// isNotFound tells whether a data source failed because the resource does not exist.
+func isNotFound(err error) bool {
+	message := err.Error()
+	return strings.Contains(message, "couldn't find resource") || strings.Contains(message, "ResourceNotFoundException")
+}
+
+// LookupClusterVersion returns the Kubernetes version of the running cluster, empty when it does not exist yet.
+func LookupClusterVersion(ctx *pulumi.Context, clusterName string) (string, error) {
+	cluster, err := awsEKS.LookupCluster(ctx, &awsEKS.LookupClusterArgs{
+		Name: clusterName,
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("cannot read the version of cluster %s: %w", clusterName, err)
+	}
+	return cluster.Version, nil
+}
+
+// PlanUpgrade validates the requested version against the running cluster and resolves the node images for the
+// target version: the EKS optimized AMI releases of the system and Linux pools and the XBeam image of the Windows
+// pool, the one of the target version when windowsAmiNamePattern matches it. It fails before any resource is touched.
+func PlanUpgrade(ctx *pulumi.Context, currentVersion string, targetVersion string, windowsAmiNamePattern string) (*UpgradePlan, error) {
+	err := validateUpgradeStep(currentVersion, targetVersion)
+	if err != nil {
+		return nil, err
+	}
+	plan := &UpgradePlan{
+		CurrentVersion: currentVersion,
+		TargetVersion:  targetVersion,
+	}
+	plan.SystemReleaseVersion, err = lookupSSMParameter(ctx, "/aws/service/eks/optimized-ami/"+targetVersion+"/amazon-linux-2/recommended/release_version")
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve system node AMI for kubernetes %s: %w", targetVersion, err)
+	}
+	plan.LinuxReleaseVersion, err = lookupSSMParameter(ctx, "/aws/service/eks/optimized-ami/"+targetVersion+"/amazon-linux-2-gpu/recommended/release_version")
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve linux GPU node AMI for kubernetes %s: %w", targetVersion, err)
+	}
+	// The Windows pool runs the XBeam marketplace AMI; make sure EKS ships Windows support for the target version
+	_, err = lookupSSMParameter(ctx, "/aws/service/ami-windows-latest/Windows_Server-2022-English-Core-EKS_Optimized-"+targetVersion+"/image_id")
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve windows node AMI for kubernetes %s: %w", targetVersion, err)
+	}
+	ami, err := LookupAMI(ctx, windowsAmiNamePattern, targetVersion)
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve windows node AMI for kubernetes %s: %w", targetVersion, err)
+	}
+	plan.WindowsImageId = ami.ImageId
+	return plan, nil
+}
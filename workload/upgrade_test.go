@@ -0,0 +1,48 @@
+package workload
+
+import "testing"
+
+func TestValidateUpgradeStep(t *testing.T) {
+	tests := []struct {
+		current string
+		target  string
+		ok      bool
+	}{
+		{"1.29", "1.29", true},
+		{"1.28", "1.29", true},
+		{"1.27", "1.29", false},
+		{"1.29", "1.28", false},
+		{"1.29", "2.0", false},
+		{"1.29", "1.30.1", false},
+		{"1.29", "latest", false},
+	}
+	for _, test := range tests {
+		err := validateUpgradeStep(test.current, test.target)
+		if test.ok && err != nil {
+			t.Errorf("%s -> %s: unexpected error %v", test.current, test.target, err)
+		}
+		if !test.ok && err == nil {
+			t.Errorf("%s -> %s: expected error", test.current, test.target)
+		}
+	}
+}
+
+func TestParseKubernetesVersion(t *testing.T) {
+	major, minor, err := parseKubernetesVersion("1.29")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if major != 1 || minor != 29 {
+		t.Errorf("got %d.%d, want 1.29", major, minor)
+	}
+}
+
+func TestWindowsAmiFilters(t *testing.T) {
+	if filters := WindowsAmiFilters("", "1.29"); len(filters) != 1 || filters[0].Name != "product-code" {
+		t.Errorf("expected only the product filter without a name pattern, got %+v", filters)
+	}
+	filters := WindowsAmiFilters("xbeam-windows-{version}-*", "1.29")
+	if len(filters) != 2 || filters[1].Name != "name" || filters[1].Values[0] != "xbeam-windows-1.29-*" {
+		t.Errorf("expected the name filter of 1.29, got %+v", filters)
+	}
+}
@@ -0,0 +1,122 @@
+package workload
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/pulumi/pulumi-eks/sdk/v2/go/eks"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// BootstrapArgs are the values the node bootstrap scripts join the cluster with.
+type BootstrapArgs struct {
+	ClusterName string
+	Endpoint    string
+	// CertificateAuthority is the base64 encoded CA data of the cluster, line breaks are removed
+	CertificateAuthority string
+	DnsClusterIP         string
+	Labels               map[string]string
+}
+
+// net user WIN_GPU_INSTANCE_USERNAME_PLACEHOLDER "WIN_GPU_INSTANCE_PASSWORD_PLACEHOLDER"
+// & "C:\Program Files\Amazon\EKS\Start-EKSBootstrap.ps1" -EKSClusterName "%s" -APIServerEndpoint "%s" -Base64ClusterCA "%s" -DNSClusterIP "%s" -ContainerRuntime "containerd" -KubeletExtraArgs "--node-labels=" 3>&1 4>&1 5>&1 6>&1
+// $drive_letter = "C"
+// $size = (Get-PartitionSupportedSize -DriveLetter $drive_letter)
+// Resize-Partition -DriveLetter $drive_letter -Size $size.SizeMax
+var windowsTemplate = template.Must(template.New("windows").Funcs(template.FuncMap{"quote": powershellQuote}).Parse(`<powershell>
+net user Administrator {{quote .Password}}
+[string]$EKSBootstrapScriptFile = "$env:ProgramFiles\Amazon\EKS\Start-EKSBootstrap.ps1"
+& $EKSBootstrapScriptFile -EKSClusterName {{quote .ClusterName}} -APIServerEndpoint {{quote .Endpoint}} -Base64ClusterCA {{quote .CertificateAuthority}} -DNSClusterIP {{quote .DnsClusterIP}} -ContainerRuntime containerd -KubeletExtraArgs {{quote .KubeletExtraArgs}} 3>&1 4>&1 5>&1 6>&1
+</powershell>
+<persist>true</persist>
+`))
+
+// powershellQuote returns value as a single quoted PowerShell string, in which only quotes are special.
+func powershellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// kubeletExtraArgs passes the labels to the kubelet sorted by key, so the rendered user data is stable.
+func (a BootstrapArgs) kubeletExtraArgs() string {
+	keys := make([]string, 0, len(a.Labels))
+	for key := range a.Labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	labels := make([]string, 0, len(keys))
+	for _, key := range keys {
+		labels = append(labels, key+"="+a.Labels[key])
+	}
+	return "--node-labels=" + strings.Join(labels, ",")
+}
+
+func (a BootstrapArgs) validate() error {
+	for _, field := range []struct{ name, value string }{
+		{"cluster name", a.ClusterName},
+		{"endpoint", a.Endpoint},
+		{"certificate authority", a.CertificateAuthority},
+		{"DNS cluster IP", a.DnsClusterIP},
+	} {
+		if field.value == "" {
+			return fmt.Errorf("the user data needs the %s", field.name)
+		}
+	}
+	return nil
+}
+
+func render(t *template.Template, data interface{}) (string, error) {
+	var userData strings.Builder
+	if err := t.Execute(&userData, data); err != nil {
+		return "", fmt.Errorf("rendering the %s user data: %w", t.Name(), err)
+	}
+	return userData.String(), nil
+}
+
+// renderWindowsUserData returns the PowerShell user data that sets the Administrator password and joins the node.
+func renderWindowsUserData(args BootstrapArgs, password string) (string, error) {
+	if err := args.validate(); err != nil {
+		return "", err
+	}
+	if password == "" {
+		return "", fmt.Errorf("the Windows user data needs the Administrator password")
+	}
+	return render(windowsTemplate, struct {
+		BootstrapArgs
+		Password         string
+		KubeletExtraArgs string
+	}{args, password, args.kubeletExtraArgs()})
+}
+
+// bootstrapArgs resolves the bootstrap values of the cluster, the output holds a BootstrapArgs.
+func bootstrapArgs(cluster *eks.Cluster, clusterIP pulumi.Output, labels map[string]string) pulumi.Output {
+	certificateAuthorityData := cluster.EksCluster.CertificateAuthority().Data()
+	return pulumi.All(cluster.EksCluster.Name(), cluster.EksCluster.Endpoint(), certificateAuthorityData, clusterIP).ApplyT(func(args []interface{}) BootstrapArgs {
+		certificate := *args[2].(*string)
+		certificate = strings.ReplaceAll(certificate, "\n", "")
+		certificate = strings.ReplaceAll(certificate, "\r", "")
+		return BootstrapArgs{
+			ClusterName:          args[0].(string),
+			Endpoint:             args[1].(string),
+			CertificateAuthority: certificate,
+			DnsClusterIP:         *args[3].(*string),
+			Labels:               labels,
+		}
+	})
+}
+
+// getWindowsUserData renders the user data of the Windows pool. It holds the Administrator password, so it is never
+// logged and stays a secret as long as windowsPassword is one.
+func getWindowsUserData(cluster *eks.Cluster, clusterIP pulumi.Output, windowsPassword pulumi.StringInput, labels map[string]string) pulumi.StringPtrInput {
+	combined := pulumi.All(bootstrapArgs(cluster, clusterIP, labels), windowsPassword).ApplyT(func(args []interface{}) (*string, error) {
+		userData, err := renderWindowsUserData(args[0].(BootstrapArgs), args[1].(string))
+		if err != nil {
+			return nil, err
+		}
+		userData = base64.StdEncoding.EncodeToString([]byte(userData))
+		return &userData, nil
+	})
+	return combined.(pulumi.StringPtrOutput)
+}
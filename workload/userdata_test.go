@@ -0,0 +1,74 @@
+package workload
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testBootstrapArgs() BootstrapArgs {
+	return BootstrapArgs{
+		ClusterName:          "dev-eks",
+		Endpoint:             "https://0123456789ABCDEF.gr7.us-east-1.eks.amazonaws.com",
+		CertificateAuthority: "LS0tLS1CRUdJTiBDRVJUSUZJQ0FURS0tLS0tCg==",
+		DnsClusterIP:         "172.20.0.10",
+		Labels:               map[string]string{"workload": "gpu", "os": "windows"},
+	}
+}
+
+func TestRenderWindowsUserData(t *testing.T) {
+	userData, err := renderWindowsUserData(testBootstrapArgs(), `it's $ecret; "quoted"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "userdata/windows.ps1", userData)
+	checkPowerShell(t, userData)
+}
+
+func TestRenderUserDataRequiresValues(t *testing.T) {
+	args := testBootstrapArgs()
+	args.DnsClusterIP = ""
+	if _, err := renderWindowsUserData(args, "Passw0rd!"); err == nil || !strings.Contains(err.Error(), "DNS cluster IP") {
+		t.Errorf("expected a missing DNS cluster IP to fail, got %v", err)
+	}
+	if _, err := renderWindowsUserData(testBootstrapArgs(), ""); err == nil {
+		t.Error("expected a missing password to fail")
+	}
+}
+
+func TestKubeletExtraArgs(t *testing.T) {
+	if got := (BootstrapArgs{}).kubeletExtraArgs(); got != "--node-labels=" {
+		t.Errorf("unexpected args without labels %q", got)
+	}
+	if got := testBootstrapArgs().kubeletExtraArgs(); got != "--node-labels=os=windows,workload=gpu" {
+		t.Errorf("expected the labels sorted by key, got %q", got)
+	}
+}
+
+// writeScript writes the script to a temporary file for the syntax checkers.
+func writeScript(t *testing.T, name string, script string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(script), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// checkPowerShell parses the script between the <powershell> tags with pwsh, when it is installed.
+func checkPowerShell(t *testing.T, userData string) {
+	t.Helper()
+	pwsh, err := exec.LookPath("pwsh")
+	if err != nil {
+		t.Log("pwsh is not installed, skipping the PowerShell syntax check")
+		return
+	}
+	script := userData[strings.Index(userData, "<powershell>")+len("<powershell>") : strings.Index(userData, "</powershell>")]
+	path := writeScript(t, "userdata.ps1", script)
+	parse := `$errors = $null; [System.Management.Automation.Language.Parser]::ParseFile($args[0], [ref]$null, [ref]$errors) | Out-Null; ` +
+		`$errors | ForEach-Object { Write-Output $_.ToString() }; exit $errors.Count`
+	if output, err := exec.Command(pwsh, "-NoProfile", "-NonInteractive", "-Command", parse, path).CombinedOutput(); err != nil {
+		t.Errorf("PowerShell syntax errors: %v\n%s", err, output)
+	}
+}
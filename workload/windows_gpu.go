@@ -0,0 +1,188 @@
+package workload
+
+import (
+	"errors"
+
+	appsv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apps/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const (
+	// DEFAULT_WINDOWS_DEVICE_PLUGIN_IMAGE advertises the GPUs of a Windows node as directx.microsoft.com/display
+	DEFAULT_WINDOWS_DEVICE_PLUGIN_IMAGE = "index.docker.io/tensorworks/wddm-device-plugin:0.0.1"
+	// HOST_PROCESS_IMAGE is the base image of containers that run as processes of the Windows host
+	HOST_PROCESS_IMAGE = "mcr.microsoft.com/oss/kubernetes/windows-host-process-containers-base-image:v1.0.0"
+	// WINDOWS_GPU_VALIDATION_PERIOD is the number of seconds between two validations of a node
+	WINDOWS_GPU_VALIDATION_PERIOD = 300
+)
+
+// windowsGpuValidationScript fails unless an NVIDIA adapter is working and nvidia-smi reports a WDDM driver, the
+// driver model DirectX needs. The output of a failed run is the message of the readiness probe event.
+var windowsGpuValidationScript = `$ErrorActionPreference = 'Stop'
+$adapter = Get-CimInstance Win32_VideoController | Where-Object { $_.Name -like 'NVIDIA*' } | Select-Object -First 1
+if (-not $adapter) {
+    Write-Output "FAIL ${env:COMPUTERNAME}: no NVIDIA display adapter"
+    exit 1
+}
+if ($adapter.Status -ne 'OK') {
+    Write-Output "FAIL ${env:COMPUTERNAME}: $($adapter.Name) is $($adapter.Status)"
+    exit 1
+}
+$smi = Join-Path $env:SystemRoot 'System32\nvidia-smi.exe'
+if (-not (Test-Path $smi)) {
+    Write-Output "FAIL ${env:COMPUTERNAME}: nvidia-smi is missing, the NVIDIA driver is not installed"
+    exit 1
+}
+$gpu = & $smi --query-gpu=name,driver_version,driver_model.current --format=csv,noheader
+if ($LASTEXITCODE -ne 0) {
+    Write-Output "FAIL ${env:COMPUTERNAME}: nvidia-smi exited with $LASTEXITCODE"
+    exit 1
+}
+if ($gpu -notmatch 'WDDM') {
+    Write-Output "FAIL ${env:COMPUTERNAME}: $gpu, DirectX needs the WDDM driver model"
+    exit 1
+}
+Write-Output "PASS ${env:COMPUTERNAME}: $gpu"
+`
+
+// LoadWindowsDevicePluginImage reads worker:windowsDevicePluginImage.
+func LoadWindowsDevicePluginImage(ctx *pulumi.Context) string {
+	if image, ok := ctx.GetConfig("worker:windowsDevicePluginImage"); ok && image != "" {
+		return image
+	}
+	return DEFAULT_WINDOWS_DEVICE_PLUGIN_IMAGE
+}
+
+type WindowsGpuSupportArgs struct {
+	Cluster *WorkloadCluster
+	Pool    *GpuNodePool
+	// DevicePluginImage is DEFAULT_WINDOWS_DEVICE_PLUGIN_IMAGE when empty
+	DevicePluginImage string
+}
+
+// WindowsGpuSupport advertises the GPUs of the Windows pool to Kubernetes and validates the NVIDIA driver of its
+// nodes. The validation DaemonSet has a pod on every node of the pool, the ready ones passed.
+type WindowsGpuSupport struct {
+	pulumi.ResourceState
+
+	DevicePlugin *appsv1.DaemonSet
+	Validation   *appsv1.DaemonSet
+}
+
+// hostProcessSecurityContext runs a container as a SYSTEM process of the Windows host, with its network.
+func hostProcessSecurityContext() corev1.PodSecurityContextArgs {
+	return corev1.PodSecurityContextArgs{
+		WindowsOptions: corev1.WindowsSecurityContextOptionsArgs{
+			HostProcess:   pulumi.Bool(true),
+			RunAsUserName: pulumi.String(`NT AUTHORITY\SYSTEM`),
+		},
+	}
+}
+
+func windowsGpuNodeSelector() pulumi.StringMap {
+	return pulumi.StringMap{
+		"kubernetes.io/os": pulumi.String("windows"),
+		"workload":         pulumi.String(gpuLabels["workload"]),
+	}
+}
+
+func NewWindowsGpuSupport(ctx *pulumi.Context, name string, args *WindowsGpuSupportArgs, opts ...pulumi.ResourceOption) (*WindowsGpuSupport, error) {
+	if args == nil || args.Cluster == nil || args.Pool == nil {
+		return nil, errors.New("WindowsGpuSupport requires a Cluster and a Pool")
+	}
+	image := args.DevicePluginImage
+	if image == "" {
+		image = DEFAULT_WINDOWS_DEVICE_PLUGIN_IMAGE
+	}
+	component := &WindowsGpuSupport{}
+	err := ctx.RegisterComponentResource(TYPE_WINDOWS_GPU_SUPPORT, name, component, opts...)
+	if err != nil {
+		return nil, err
+	}
+	k8sOpts := []pulumi.ResourceOption{pulumi.Parent(component), pulumi.Provider(args.Cluster.Provider)}
+	labels := pulumi.StringMap{"app.kubernetes.io/name": pulumi.String("windows-device-plugin")}
+	component.DevicePlugin, err = appsv1.NewDaemonSet(ctx, name+"-device-plugin", &appsv1.DaemonSetArgs{
+		Metadata: metav1.ObjectMetaArgs{
+			Name:      pulumi.String("windows-device-plugin"),
+			Namespace: pulumi.String("kube-system"),
+			Labels:    labels,
+		},
+		Spec: appsv1.DaemonSetSpecArgs{
+			Selector: metav1.LabelSelectorArgs{MatchLabels: labels},
+			Template: corev1.PodTemplateSpecArgs{
+				Metadata: metav1.ObjectMetaArgs{Labels: labels},
+				Spec: corev1.PodSpecArgs{
+					NodeSelector:      windowsGpuNodeSelector(),
+					Tolerations:       gpuTolerationArray(),
+					HostNetwork:       pulumi.Bool(true),
+					SecurityContext:   hostProcessSecurityContext(),
+					PriorityClassName: pulumi.String("system-node-critical"),
+					Containers: corev1.ContainerArray{
+						corev1.ContainerArgs{
+							Name:  pulumi.String("device-plugin"),
+							Image: pulumi.String(image),
+						},
+					},
+				},
+			},
+		},
+	}, childOptions(k8sOpts, pulumi.DependsOn([]pulumi.Resource{args.Pool.NodeGroup}))...)
+	if err != nil {
+		return nil, err
+	}
+	component.Validation, err = createWindowsGpuValidation(ctx, name+"-validation", childOptions(k8sOpts, pulumi.DependsOn([]pulumi.Resource{args.Pool.NodeGroup}))...)
+	if err != nil {
+		return nil, err
+	}
+	err = ctx.RegisterResourceOutputs(component, pulumi.Map{
+		"devicePluginImage": pulumi.String(image),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return component, nil
+}
+
+// createWindowsGpuValidation runs a validation pod on every Windows GPU node, including the ones the autoscaler
+// adds later. The pod only sleeps, its readiness probe runs the validation script: a node without a working driver
+// keeps an unready pod, with the reason in the probe failure events.
+func createWindowsGpuValidation(ctx *pulumi.Context, name string, opts ...pulumi.ResourceOption) (*appsv1.DaemonSet, error) {
+	labels := pulumi.StringMap{"app.kubernetes.io/name": pulumi.String("windows-gpu-validation")}
+	powershell := func(script string) pulumi.StringArray {
+		return pulumi.StringArray{pulumi.String("powershell.exe"), pulumi.String("-NoProfile"), pulumi.String("-NonInteractive"), pulumi.String("-Command"), pulumi.String(script)}
+	}
+	return appsv1.NewDaemonSet(ctx, name, &appsv1.DaemonSetArgs{
+		Metadata: metav1.ObjectMetaArgs{
+			Name:      pulumi.String("windows-gpu-validation"),
+			Namespace: pulumi.String("kube-system"),
+			Labels:    labels,
+		},
+		Spec: appsv1.DaemonSetSpecArgs{
+			Selector: metav1.LabelSelectorArgs{MatchLabels: labels},
+			Template: corev1.PodTemplateSpecArgs{
+				Metadata: metav1.ObjectMetaArgs{Labels: labels},
+				Spec: corev1.PodSpecArgs{
+					NodeSelector:    windowsGpuNodeSelector(),
+					Tolerations:     gpuTolerationArray(),
+					HostNetwork:     pulumi.Bool(true),
+					SecurityContext: hostProcessSecurityContext(),
+					Containers: corev1.ContainerArray{
+						corev1.ContainerArgs{
+							Name:    pulumi.String("validate"),
+							Image:   pulumi.String(HOST_PROCESS_IMAGE),
+							Command: powershell("while ($true) { Start-Sleep -Seconds 3600 }"),
+							ReadinessProbe: corev1.ProbeArgs{
+								Exec:             corev1.ExecActionArgs{Command: powershell(windowsGpuValidationScript)},
+								PeriodSeconds:    pulumi.Int(WINDOWS_GPU_VALIDATION_PERIOD),
+								TimeoutSeconds:   pulumi.Int(60),
+								FailureThreshold: pulumi.Int(1),
+							},
+						},
+					},
+				},
+			},
+		},
+	}, opts...)
+}
@@ -0,0 +1,7 @@
+package workload
+
+import "testing"
+
+func TestWindowsGpuValidationScript(t *testing.T) {
+	checkPowerShell(t, "<powershell>"+windowsGpuValidationScript+"</powershell>")
+}